@@ -3,6 +3,7 @@ package operator2
 import (
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog"
 
 	configv1 "github.com/openshift/api/config/v1"
 )
@@ -23,7 +24,7 @@ func (c *authOperator) handleAuthConfigInner() (*configv1.Authentication, error)
 	// always make sure this function does not rely on defaulting from defaultAuthConfig
 
 	authConfigNoDefaults, err := c.authentication.Get(globalConfigName, metav1.GetOptions{})
-	if errors.IsNotFound(err) {
+	if errors.IsNotFound(err) && c.mutationAllowed("creating the cluster authentication config") {
 		authConfigNoDefaults, err = c.authentication.Create(&configv1.Authentication{
 			ObjectMeta: defaultGlobalConfigMeta(),
 		})
@@ -40,11 +41,18 @@ func (c *authOperator) handleAuthConfigInner() (*configv1.Authentication, error)
 		return authConfigNoDefaults, nil
 	}
 
+	if !c.mutationAllowed("pointing the authentication config status at the metadata config map") {
+		return authConfigNoDefaults, nil
+	}
 	authConfigNoDefaults.Status.IntegratedOAuthMetadata = expectedReference
 	return c.authentication.UpdateStatus(authConfigNoDefaults)
 }
 
 func (c *authOperator) handleAuthConfig() (*configv1.Authentication, error) {
+	// only the "cluster" singleton is honored - catch the admin mistake of
+	// creating a config under any other name before it silently does nothing
+	c.reportStrayAuthConfigs()
+
 	auth, err := c.handleAuthConfigInner()
 	if err != nil {
 		return nil, err
@@ -52,6 +60,25 @@ func (c *authOperator) handleAuthConfig() (*configv1.Authentication, error) {
 	return defaultAuthConfig(auth), nil
 }
 
+// reportStrayAuthConfigs warns about authentication configs that are not the
+// expected singleton.  creating one under a different name is a subtle
+// mistake - nothing acts on it and nothing complains - so make the ignoring
+// explicit in the logs.
+func (c *authOperator) reportStrayAuthConfigs() {
+	configs, err := c.authentication.List(metav1.ListOptions{})
+	if err != nil {
+		// purely diagnostic - never fail or degrade the sync over it
+		klog.V(2).Infof("failed to list authentication configs: %v", err)
+		return
+	}
+	for _, config := range configs.Items {
+		if config.Name == globalConfigName {
+			continue
+		}
+		klog.Warningf("ignoring authentication config %q - only the singleton named %q is honored", config.Name, globalConfigName)
+	}
+}
+
 func defaultAuthConfig(authConfig *configv1.Authentication) *configv1.Authentication {
 	out := authConfig.DeepCopy() // do not mutate informer cache
 
@@ -0,0 +1,48 @@
+package operator2
+
+import (
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog"
+
+	configv1 "github.com/openshift/api/config/v1"
+	configfake "github.com/openshift/client-go/config/clientset/versioned/fake"
+)
+
+// TestReportStrayAuthConfigs asserts that authentication configs created
+// under any name other than the "cluster" singleton are called out in the
+// logs - they are silently ignored otherwise, which is usually an admin typo.
+func TestReportStrayAuthConfigs(t *testing.T) {
+	logged := captureKlog(t)
+
+	c := &authOperator{
+		authentication: configfake.NewSimpleClientset(
+			&configv1.Authentication{ObjectMeta: metav1.ObjectMeta{Name: globalConfigName}},
+			&configv1.Authentication{ObjectMeta: metav1.ObjectMeta{Name: "my-auth"}},
+		).ConfigV1().Authentications(),
+	}
+	c.reportStrayAuthConfigs()
+
+	klog.Flush()
+	output := logged.String()
+	if !strings.Contains(output, `"my-auth"`) {
+		t.Errorf("expected a warning naming the stray config, got:\n%s", output)
+	}
+	if !strings.Contains(output, globalConfigName) {
+		t.Errorf("expected the warning to name the honored singleton, got:\n%s", output)
+	}
+
+	// the singleton alone warns about nothing
+	logged.Reset()
+	c.authentication = configfake.NewSimpleClientset(
+		&configv1.Authentication{ObjectMeta: metav1.ObjectMeta{Name: globalConfigName}},
+	).ConfigV1().Authentications()
+	c.reportStrayAuthConfigs()
+
+	klog.Flush()
+	if output := logged.String(); strings.Contains(output, "ignoring authentication config") {
+		t.Errorf("expected no warning for the singleton alone, got:\n%s", output)
+	}
+}
@@ -18,7 +18,7 @@ func (c *authOperator) handleServiceCA() (*corev1.ConfigMap, *corev1.Secret, err
 	cm := c.configMaps.ConfigMaps(targetNamespace)
 	secret := c.secrets.Secrets(targetNamespace)
 	serviceCA, err := cm.Get(serviceCAName, metav1.GetOptions{})
-	if errors.IsNotFound(err) {
+	if errors.IsNotFound(err) && c.mutationAllowed("creating the service CA config map") {
 		serviceCA, err = cm.Create(defaultServiceCA())
 	}
 	if err != nil {
@@ -30,11 +30,13 @@ func (c *authOperator) handleServiceCA() (*corev1.ConfigMap, *corev1.Secret, err
 	}
 
 	if err := isValidServiceCA(serviceCA); err != nil {
-		// delete the service CA config map so that it is replaced with the proper one in next reconcile loop
-		klog.Infof("deleting invalid service CA config map: %#v", serviceCA)
-		opts := &metav1.DeleteOptions{Preconditions: &metav1.Preconditions{UID: &serviceCA.UID}}
-		if err := cm.Delete(serviceCA.Name, opts); err != nil && !errors.IsNotFound(err) {
-			klog.Infof("failed to delete invalid service CA config map: %v", err)
+		if c.mutationAllowed("deleting the invalid service CA config map") {
+			// delete the service CA config map so that it is replaced with the proper one in next reconcile loop
+			klog.Infof("deleting invalid service CA config map: %#v", serviceCA)
+			opts := &metav1.DeleteOptions{Preconditions: &metav1.Preconditions{UID: &serviceCA.UID}}
+			if err := cm.Delete(serviceCA.Name, opts); err != nil && !errors.IsNotFound(err) {
+				klog.Infof("failed to delete invalid service CA config map: %v", err)
+			}
 		}
 		return nil, nil, err
 	}
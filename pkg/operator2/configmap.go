@@ -13,9 +13,9 @@ import (
 
 const stubMetadata = `
 {
-  "issuer": "https://%s",
-  "authorization_endpoint": "https://%s/oauth/authorize",
-  "token_endpoint": "https://%s/oauth/token",
+  "issuer": "%[1]s",
+  "authorization_endpoint": "%[1]s/oauth/authorize",
+  "token_endpoint": "%[1]s/oauth/token",
   "scopes_supported": [
     "user:check-access",
     "user:full",
@@ -38,11 +38,32 @@ const stubMetadata = `
 }
 `
 
+func init() {
+	// fail immediately on a broken template edit instead of panicking later
+	// inside a running sync loop - this also makes any test of the package
+	// fail, so the breakage never leaves a developer's machine
+	var validate map[string]interface{}
+	if err := json.Unmarshal([]byte(strings.TrimSpace(fmt.Sprintf(stubMetadata, "https://example.com"))), &validate); err != nil {
+		panic(fmt.Sprintf("static oauth metadata template is not valid JSON: %v", err))
+	}
+}
+
 func getMetadataStruct(route *routev1.Route) map[string]interface{} {
+	return metadataStructForIssuer(routeToIssuerURL(route))
+}
+
+// expectedMetadataStruct is getMetadataStruct for the effective route config -
+// a downstream public-port override flows into the issuer this way.
+func (c *authOperator) expectedMetadataStruct(route *routev1.Route) map[string]interface{} {
+	return metadataStructForIssuer(c.issuerURL(route))
+}
+
+// metadataStructForIssuer renders the static metadata template for the given
+// issuer URL as a decoded document.
+func metadataStructForIssuer(issuer string) map[string]interface{} {
 	var ret map[string]interface{}
 
-	metadataJSON := getMetadata(route)
-	err := json.Unmarshal([]byte(metadataJSON), &ret)
+	err := json.Unmarshal([]byte(strings.TrimSpace(fmt.Sprintf(stubMetadata, issuer))), &ret)
 	if err != nil {
 		// should never happen unless the static metadata is broken
 		panic(err)
@@ -54,17 +75,22 @@ func getMetadataStruct(route *routev1.Route) map[string]interface{} {
 // TODO: the code in this file does not reflect situations where the
 // OAuthMetadata field of the Authentication object is set
 func getMetadata(route *routev1.Route) string {
-	host := route.Spec.Host
-	return strings.TrimSpace(fmt.Sprintf(stubMetadata, host, host, host))
+	return metadataForIssuer(routeToIssuerURL(route))
+}
+
+// metadataForIssuer renders the static metadata template for the given issuer
+// URL as the raw JSON document.
+func metadataForIssuer(issuer string) string {
+	return strings.TrimSpace(fmt.Sprintf(stubMetadata, issuer))
 }
 
-func getMetadataConfigMap(route *routev1.Route) *corev1.ConfigMap {
+func (c *authOperator) getMetadataConfigMap(route *routev1.Route) *corev1.ConfigMap {
 	meta := defaultMeta()
 	meta.Name = oauthMetadataName
 	return &corev1.ConfigMap{
 		ObjectMeta: meta,
 		Data: map[string]string{
-			configv1.OAuthMetadataKey: getMetadata(route),
+			configv1.OAuthMetadataKey: metadataForIssuer(c.issuerURL(route)),
 		},
 	}
 }
@@ -0,0 +1,28 @@
+package operator2
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestStubMetadataIsValidJSON(t *testing.T) {
+	route := testRoute("oauth-openshift.apps.example.com")
+
+	var metadata map[string]interface{}
+	if err := json.Unmarshal([]byte(getMetadata(route)), &metadata); err != nil {
+		t.Fatalf("the formatted metadata template is not valid JSON: %v", err)
+	}
+
+	// the fields every OAuth client depends on must be present and derived
+	// from the issuer
+	issuer := routeToIssuerURL(route)
+	for key, want := range map[string]string{
+		"issuer":                 issuer,
+		"authorization_endpoint": issuer + "/oauth/authorize",
+		"token_endpoint":         issuer + "/oauth/token",
+	} {
+		if got := metadata[key]; got != want {
+			t.Errorf("metadata[%q] = %v, want %q", key, got, want)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package operator2
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	kubefake "k8s.io/client-go/kubernetes/fake"
+)
+
+// fakeTransport is a deterministic http.RoundTripper for probe tests.  it maps
+// request hosts to canned responses or errors and records every request it
+// sees, so multi-member scenarios - mismatches, non-200s, timeouts,
+// connection refusals - can be exercised without spinning up a server per
+// member.  the lock mirrors the concurrency contract of a real transport.
+type fakeTransport struct {
+	lock      sync.Mutex
+	responses map[string]fakeResponse
+	requested []string
+}
+
+type fakeResponse struct {
+	status int
+	body   string
+	err    error
+}
+
+func newFakeTransport() *fakeTransport {
+	return &fakeTransport{responses: map[string]fakeResponse{}}
+}
+
+// respond cans a status and body for requests against the given host:port.
+func (t *fakeTransport) respond(host string, status int, body string) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.responses[host] = fakeResponse{status: status, body: body}
+}
+
+// fail cans a transport-level error for the given host:port, e.g. a timeout.
+func (t *fakeTransport) fail(host string, err error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.responses[host] = fakeResponse{err: err}
+}
+
+// refuse makes the host behave like nothing listens at its address, matching
+// what isConnectionRefused detects.
+func (t *fakeTransport) refuse(host string) {
+	t.fail(host, fmt.Errorf("dial tcp %s: connect: connection refused", host))
+}
+
+// requestedHosts returns the hosts requested so far, in order.
+func (t *fakeTransport) requestedHosts() []string {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	return append([]string(nil), t.requested...)
+}
+
+func (t *fakeTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.lock.Lock()
+	t.requested = append(t.requested, req.URL.Host)
+	canned, ok := t.responses[req.URL.Host]
+	t.lock.Unlock()
+
+	if !ok {
+		return nil, fmt.Errorf("fakeTransport: no canned response for host %s", req.URL.Host)
+	}
+	if canned.err != nil {
+		return nil, canned.err
+	}
+	return &http.Response{
+		Status:     fmt.Sprintf("%d %s", canned.status, http.StatusText(canned.status)),
+		StatusCode: canned.status,
+		Header:     http.Header{},
+		Body:       ioutil.NopCloser(strings.NewReader(canned.body)),
+		Request:    req,
+	}, nil
+}
+
+// TestCheckMembersWellknownReadyFanOut drives the member fan out through the
+// fake transport: each scenario cans a different per-member behavior without a
+// server per member.
+func TestCheckMembersWellknownReadyFanOut(t *testing.T) {
+	route := testRoute("oauth-openshift.apps.example.com")
+	members := []string{"10.0.0.1:6443", "10.0.0.2:6443", "10.0.0.3:6443"}
+
+	kubeClient := kubefake.NewSimpleClientset(testKASService(), testKASEndpoints(6443, "10.0.0.1", "10.0.0.2", "10.0.0.3"))
+	newOperator := func() *authOperator {
+		return &authOperator{services: kubeClient.CoreV1(), endpoints: kubeClient.CoreV1()}
+	}
+
+	t.Run("all members healthy", func(t *testing.T) {
+		rt := newFakeTransport()
+		for _, member := range members {
+			rt.respond(member, 200, getMetadata(route))
+		}
+		ready, _, msg, err := newOperator().checkMembersWellknownReady(members, rt, route)
+		if err != nil || !ready {
+			t.Errorf("expected ready, got ready=%v msg=%q err=%v", ready, msg, err)
+		}
+		if got := rt.requestedHosts(); len(got) != len(members) {
+			t.Errorf("expected every member probed once, got %v", got)
+		}
+	})
+
+	t.Run("one member serves a non-200", func(t *testing.T) {
+		rt := newFakeTransport()
+		rt.respond(members[0], 200, getMetadata(route))
+		rt.respond(members[1], 503, "")
+		rt.respond(members[2], 200, getMetadata(route))
+		ready, reason, _, err := newOperator().checkMembersWellknownReady(members, rt, route)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if ready || reason != reasonUnexpectedStatus {
+			t.Errorf("expected not ready with reason %s, got ready=%v reason=%q", reasonUnexpectedStatus, ready, reason)
+		}
+		// the fan out stops at the first bad member
+		if got := rt.requestedHosts(); len(got) != 2 {
+			t.Errorf("expected the fan out to stop at the bad member, got %v", got)
+		}
+	})
+
+	t.Run("one member serves stale metadata", func(t *testing.T) {
+		rt := newFakeTransport()
+		rt.respond(members[0], 200, getMetadata(route))
+		rt.respond(members[1], 200, getMetadata(testRoute("oauth-openshift.apps.stale.example.com")))
+		rt.respond(members[2], 200, getMetadata(route))
+		ready, reason, _, err := newOperator().checkMembersWellknownReady(members, rt, route)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if ready || reason != reasonMetadataMismatch {
+			t.Errorf("expected a metadata mismatch, got ready=%v reason=%q", ready, reason)
+		}
+	})
+
+	t.Run("one member times out", func(t *testing.T) {
+		rt := newFakeTransport()
+		rt.respond(members[0], 200, getMetadata(route))
+		rt.fail(members[1], fmt.Errorf("net/http: request canceled (Client.Timeout exceeded while awaiting headers)"))
+		ready, _, _, err := newOperator().checkMembersWellknownReady(members, rt, route)
+		if ready || err == nil || !strings.Contains(err.Error(), "Timeout") {
+			t.Errorf("expected the timeout to surface as a hard error, got ready=%v err=%v", ready, err)
+		}
+	})
+
+	t.Run("one member refuses connections but is still an endpoint", func(t *testing.T) {
+		rt := newFakeTransport()
+		rt.respond(members[0], 200, getMetadata(route))
+		rt.refuse(members[1])
+		ready, _, _, err := newOperator().checkMembersWellknownReady(members, rt, route)
+		if ready || err == nil || !isConnectionRefused(err) {
+			t.Errorf("expected the refusal from a current member to surface, got ready=%v err=%v", ready, err)
+		}
+	})
+}
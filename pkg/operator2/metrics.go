@@ -0,0 +1,120 @@
+package operator2
+
+import (
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	configv1 "github.com/openshift/api/config/v1"
+	routev1 "github.com/openshift/api/route/v1"
+)
+
+// routeAdmittedAge tracks how long ago the oauth route was admitted at its
+// canonical host.  a small value during a readiness delay means the route is
+// freshly admitted and still propagating; a large value means the delay has
+// some other cause.
+var routeAdmittedAge = prometheus.NewGauge(prometheus.GaugeOpts{
+	Name: "openshift_authentication_operator_route_admitted_age_seconds",
+	Help: "Seconds since the oauth route was admitted at its canonical host. Zero while the route is not admitted.",
+})
+
+// wellknownMemberHealthPercent tracks what fraction (0-100) of the KAS
+// members served the expected well-known metadata in the last probe cycle.
+// it complements the boolean conditions with a continuous signal: dashboards
+// see gradual recovery or deterioration during rollouts, and alerts can fire
+// on "below 100 for more than X minutes".
+var wellknownMemberHealthPercent = prometheus.NewGauge(prometheus.GaugeOpts{
+	Name: "openshift_authentication_operator_wellknown_member_health_percent",
+	Help: "Percentage of kube api server members serving the expected well-known metadata in the last probe cycle. Zero when the members could not be enumerated.",
+})
+
+// routeCorrectionsTotal counts how many times handleRoute had to correct or
+// recreate the oauth route since operator start.  a handful over an operator's
+// lifetime is an admin poking at the route - a steadily rising counter means
+// this reconcile loop and some other actor keep undoing each other's writes,
+// which makes the fight-over-the-route scenario alertable instead of only
+// visible as repeated log lines.
+var routeCorrectionsTotal = prometheus.NewCounter(prometheus.CounterOpts{
+	Name: "openshift_authentication_operator_route_corrections_total",
+	Help: "Number of times the oauth route was corrected or recreated by the operator since it started.",
+})
+
+// probeCipherSuiteInfo is an info-style metric: the series labelled with the
+// cipher suite the most recent probe connection negotiated is 1, everything
+// else is absent.  FIPS-conscious environments can alert on an unexpected
+// label instead of scraping logs.
+var probeCipherSuiteInfo = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "openshift_authentication_operator_probe_cipher_suite_info",
+	Help: "Cipher suite negotiated by the most recent well-known probe connection. The series for the current suite is 1.",
+}, []string{"cipher_suite"})
+
+func init() {
+	prometheus.MustRegister(routeAdmittedAge)
+	prometheus.MustRegister(wellknownMemberHealthPercent)
+	prometheus.MustRegister(routeCorrectionsTotal)
+	prometheus.MustRegister(probeCipherSuiteInfo)
+}
+
+// recordProbeCipherSuite points the cipher suite info metric at the suite the
+// latest probe connection negotiated, dropping any previous series so exactly
+// one label value is ever exposed.
+func recordProbeCipherSuite(name string) {
+	probeCipherSuiteInfo.Reset()
+	probeCipherSuiteInfo.WithLabelValues(name).Set(1)
+}
+
+// noteRouteCorrection counts one route correction, named for the log line.
+// only called from the sync loop, so the field needs no locking.
+func (c *authOperator) noteRouteCorrection(kind string) {
+	c.routeCorrections++
+	routeCorrectionsTotal.Inc()
+	klog.V(2).Infof("route correction %q - %d corrections since operator start", kind, c.routeCorrections)
+}
+
+// recordMemberHealthPercent updates the member health gauge from a probe
+// cycle.  the fan out stops at the first bad member, so members it never
+// reached count as unhealthy - partial cycles read pessimistic rather than
+// optimistic.  a zero total means the members could not be enumerated at all.
+func recordMemberHealthPercent(passed, total int) {
+	if total <= 0 {
+		wellknownMemberHealthPercent.Set(0)
+		return
+	}
+	wellknownMemberHealthPercent.Set(100 * float64(passed) / float64(total))
+}
+
+// routeAdmittedTime returns when the route was admitted at the canonical host
+// derived from the effective route config, taken from the admitted ingress
+// condition's LastTransitionTime.
+func (c *authOperator) routeAdmittedTime(route *routev1.Route, ingressConfig *configv1.Ingress) (time.Time, bool) {
+	host := c.routeConfig().host(ingressConfig)
+	for _, ingress := range route.Status.Ingress {
+		if !hostsEqual(ingress.Host, host) {
+			continue
+		}
+		for _, condition := range ingress.Conditions {
+			if condition.Type == routev1.RouteAdmitted && condition.Status == corev1.ConditionTrue && condition.LastTransitionTime != nil {
+				return condition.LastTransitionTime.Time, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+// recordRouteAdmittedAge updates the admitted-age gauge and returns a human
+// readable "route admitted N ago" fragment for use in Progressing messages, or
+// an empty string when the admission time is unknown.
+func (c *authOperator) recordRouteAdmittedAge(route *routev1.Route, ingressConfig *configv1.Ingress) string {
+	admitted, ok := c.routeAdmittedTime(route, ingressConfig)
+	if !ok {
+		routeAdmittedAge.Set(0)
+		return ""
+	}
+	age := c.now().Sub(admitted)
+	routeAdmittedAge.Set(age.Seconds())
+	return fmt.Sprintf("route admitted %s ago", age.Round(time.Second))
+}
@@ -0,0 +1,60 @@
+package operator2
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestRecordMemberHealthPercent(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		passed int
+		total  int
+		want   float64
+	}{
+		{name: "all members healthy", passed: 3, total: 3, want: 100},
+		{name: "one of four healthy", passed: 1, total: 4, want: 25},
+		{name: "first member failed", passed: 0, total: 3, want: 0},
+		{name: "members not enumerable", passed: 0, total: 0, want: 0},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			recordMemberHealthPercent(tt.passed, tt.total)
+			if got := testutil.ToFloat64(wellknownMemberHealthPercent); got != tt.want {
+				t.Errorf("health percent = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRecordRouteAdmittedAge(t *testing.T) {
+	now := time.Date(2019, time.April, 1, 12, 0, 0, 0, time.UTC)
+	admitted := metav1.NewTime(now.Add(-90 * time.Second))
+
+	ingress := testIngressConfig("apps.example.com")
+	route := testAdmittedRoute(ingress)
+	route.Status.Ingress[0].Conditions[0].LastTransitionTime = &admitted
+
+	c := &authOperator{clock: func() time.Time { return now }}
+
+	if got, want := c.recordRouteAdmittedAge(route, ingress), "route admitted 1m30s ago"; got != want {
+		t.Errorf("recordRouteAdmittedAge() = %q, want %q", got, want)
+	}
+
+	// a router reporting the host with different casing still counts
+	route.Status.Ingress[0].Conditions[0].LastTransitionTime = &admitted
+	route.Status.Ingress[0].Host = "OAuth-OpenShift.Apps.Example.COM"
+	if got, want := c.recordRouteAdmittedAge(route, ingress), "route admitted 1m30s ago"; got != want {
+		t.Errorf("recordRouteAdmittedAge() with case-differing host = %q, want %q", got, want)
+	}
+	route.Status.Ingress[0].Host = defaultRouteConfig().host(ingress)
+
+	// without a transition time there is nothing useful to report
+	route.Status.Ingress[0].Conditions[0].LastTransitionTime = nil
+	if got := c.recordRouteAdmittedAge(route, ingress); got != "" {
+		t.Errorf("expected no message without an admission time, got %q", got)
+	}
+}
@@ -1,12 +1,15 @@
 package operator2
 
 import (
+	"encoding/json"
 	"fmt"
+	"strings"
 
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/klog"
 
 	configv1 "github.com/openshift/api/config/v1"
@@ -25,6 +28,7 @@ func (c *authOperator) handleOAuthConfig(
 	service *corev1.Service,
 	consoleConfig *configv1.Console,
 	infrastructureConfig *configv1.Infrastructure,
+	ingressConfig *configv1.Ingress,
 ) (
 	*configv1.OAuth,
 	*corev1.ConfigMap,
@@ -32,7 +36,7 @@ func (c *authOperator) handleOAuthConfig(
 	error,
 ) {
 	oauthConfigNoDefaults, err := c.oauth.Get(globalConfigName, metav1.GetOptions{})
-	if errors.IsNotFound(err) {
+	if errors.IsNotFound(err) && c.mutationAllowed("creating the cluster oauth config") {
 		oauthConfigNoDefaults, err = c.oauth.Create(&configv1.OAuth{
 			ObjectMeta: defaultGlobalConfigMeta(),
 		})
@@ -99,7 +103,7 @@ func (c *authOperator) handleOAuthConfig(
 						KeyFile:  servingCertPathKey,
 					},
 					ClientCA:          "", // I think this can be left unset
-					NamedCertificates: routerSecretToSNI(routerSecret),
+					NamedCertificates: c.routerSecretToSNI(routerSecret, ingressConfig),
 					MinTLSVersion:     crypto.TLSVersionToNameOrDie(crypto.DefaultTLSVersion()),
 					CipherSuites:      crypto.CipherSuitesToNamesOrDie(crypto.DefaultCiphers()),
 				},
@@ -119,7 +123,7 @@ func (c *authOperator) handleOAuthConfig(
 		OAuthConfig: osinv1.OAuthConfig{
 			MasterCA:                    getMasterCA(), // we have valid serving certs provided by service-ca so we can use the service for loopback
 			MasterURL:                   fmt.Sprintf("https://%s.%s.svc", service.Name, service.Namespace),
-			MasterPublicURL:             fmt.Sprintf("https://%s", route.Spec.Host),
+			MasterPublicURL:             c.issuerURL(route),
 			LoginURL:                    infrastructureConfig.Status.APIServerURL,
 			AssetPublicURL:              assetPublicURL, // set console route as valid 302 redirect for logout
 			AlwaysShowProviderSelection: false,
@@ -153,6 +157,96 @@ func (c *authOperator) handleOAuthConfig(
 	return oauthConfig, getCliConfigMap(completeConfigBytes), &syncData, nil
 }
 
+// handleOAuthServerSNIDrift verifies that the live OAuth server config really
+// carries the SNI entries computed from the router secret.  the config is
+// rendered and applied every sync, but unsupportedConfigOverrides merges on
+// top of it and a stuck apply leaves the previous certificates serving -
+// either way the server keeps answering with stale certs, so the drift is
+// surfaced as a condition instead of staying invisible.
+func (c *authOperator) handleOAuthServerSNIDrift(operatorConfig *operatorv1.Authentication, routerSecret *corev1.Secret, ingressConfig *configv1.Ingress) {
+	condition := operatorv1.OperatorCondition{
+		Type:   "OAuthServerSNIConfigDrift",
+		Status: operatorv1.ConditionFalse,
+		Reason: "AsExpected",
+	}
+	defer func() {
+		v1helpers.SetOperatorCondition(&operatorConfig.Status.Conditions, condition)
+	}()
+
+	liveConfig, err := c.configMaps.ConfigMaps(targetNamespace).Get(cliConfigNameAndKey, metav1.GetOptions{})
+	if err != nil {
+		condition.Status = operatorv1.ConditionTrue
+		condition.Reason = "ConfigLookupFailed"
+		condition.Message = fmt.Sprintf("failed to get the OAuth server config: %v", err)
+		return
+	}
+
+	diffs, err := sniConfigDiff(c.routerSecretToSNI(routerSecret, ingressConfig), []byte(liveConfig.Data[cliConfigNameAndKey]))
+	if err != nil {
+		condition.Status = operatorv1.ConditionTrue
+		condition.Reason = "ConfigUnparseable"
+		condition.Message = err.Error()
+		return
+	}
+	if len(diffs) > 0 {
+		condition.Status = operatorv1.ConditionTrue
+		condition.Reason = "SNIMismatch"
+		condition.Message = fmt.Sprintf("the live OAuth server config does not match the computed SNI configuration: %s", strings.Join(diffs, "; "))
+	}
+}
+
+// sniConfigDiff compares the computed SNI entries with the namedCertificates
+// carried by a rendered OAuth server config.  the config is parsed generically
+// because unsupportedConfigOverrides may have merged arbitrary fields into the
+// document.
+func sniConfigDiff(expected []configv1.NamedCertificate, liveConfig []byte) ([]string, error) {
+	var config map[string]interface{}
+	if err := json.Unmarshal(liveConfig, &config); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal the live OAuth server config: %v", err)
+	}
+
+	live := sets.NewString()
+	servingInfo, _ := config["servingInfo"].(map[string]interface{})
+	namedCertificates, _ := servingInfo["namedCertificates"].([]interface{})
+	for _, entry := range namedCertificates {
+		fields, ok := entry.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		var names []string
+		if rawNames, ok := fields["names"].([]interface{}); ok {
+			for _, name := range rawNames {
+				if s, ok := name.(string); ok {
+					names = append(names, s)
+				}
+			}
+		}
+		certFile, _ := fields["certFile"].(string)
+		keyFile, _ := fields["keyFile"].(string)
+		live.Insert(renderSNIEntry(names, certFile, keyFile))
+	}
+
+	want := sets.NewString()
+	for _, entry := range expected {
+		want.Insert(renderSNIEntry(entry.Names, entry.CertFile, entry.KeyFile))
+	}
+
+	var diffs []string
+	for _, missing := range want.Difference(live).List() {
+		diffs = append(diffs, fmt.Sprintf("missing SNI entry %s", missing))
+	}
+	for _, extra := range live.Difference(want).List() {
+		diffs = append(diffs, fmt.Sprintf("unexpected SNI entry %s", extra))
+	}
+	return diffs, nil
+}
+
+// renderSNIEntry flattens an SNI entry into a stable string so entries can be
+// compared and enumerated in condition messages.
+func renderSNIEntry(names []string, certFile, keyFile string) string {
+	return fmt.Sprintf("names=%s cert=%s key=%s", strings.Join(names, ","), certFile, keyFile)
+}
+
 func getCliConfigMap(completeConfigBytes []byte) *corev1.ConfigMap {
 	meta := defaultMeta()
 	meta.Name = cliConfigNameAndKey
@@ -0,0 +1,100 @@
+package operator2
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/library-go/pkg/operator/v1helpers"
+)
+
+// testCLIConfigMap renders a minimal OAuth server config carrying the given
+// SNI names so the drift comparison has something live to look at.
+func testCLIConfigMap(sniNames ...string) *corev1.ConfigMap {
+	entries := make([]string, 0, len(sniNames))
+	for _, name := range sniNames {
+		entries = append(entries, fmt.Sprintf(
+			`{"names":["%s"],"certFile":"%s/apps.example.com","keyFile":"%s/apps.example.com"}`,
+			name, routerCertsLocalMount, routerCertsLocalMount))
+	}
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: cliConfigNameAndKey, Namespace: targetNamespace},
+		Data: map[string]string{
+			cliConfigNameAndKey: fmt.Sprintf(`{"servingInfo":{"namedCertificates":[%s]}}`, strings.Join(entries, ",")),
+		},
+	}
+}
+
+func TestSNIConfigDiff(t *testing.T) {
+	ingress := testIngressConfig("apps.example.com")
+	expected := (&authOperator{}).routerSecretToSNI(testRouterSecret("apps.example.com"), ingress)
+
+	// a config carrying exactly the computed entries has no drift
+	matching := testCLIConfigMap("*.apps.example.com")
+	diffs, err := sniConfigDiff(expected, []byte(matching.Data[cliConfigNameAndKey]))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(diffs) != 0 {
+		t.Errorf("expected no drift, got: %v", diffs)
+	}
+
+	// a stale entry shows up as both missing and unexpected
+	stale := testCLIConfigMap("*.apps.stale.com")
+	diffs, err = sniConfigDiff(expected, []byte(stale.Data[cliConfigNameAndKey]))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(diffs) != 2 {
+		t.Fatalf("expected a missing and an unexpected entry, got: %v", diffs)
+	}
+	for _, want := range []string{"missing SNI entry", "unexpected SNI entry", "*.apps.example.com", "*.apps.stale.com"} {
+		if !strings.Contains(strings.Join(diffs, "; "), want) {
+			t.Errorf("expected the diffs to mention %q, got: %v", want, diffs)
+		}
+	}
+
+	// garbage is an error, not a silent pass
+	if _, err := sniConfigDiff(expected, []byte("this is not JSON")); err == nil {
+		t.Error("expected an error for an unparseable config")
+	}
+}
+
+func TestHandleOAuthServerSNIDrift(t *testing.T) {
+	ingress := testIngressConfig("apps.example.com")
+	routerSecret := testRouterSecret("apps.example.com")
+	operatorConfig := &operatorv1.Authentication{}
+
+	condition := func() *operatorv1.OperatorCondition {
+		return v1helpers.FindOperatorCondition(operatorConfig.Status.Conditions, "OAuthServerSNIConfigDrift")
+	}
+
+	// a live config with the computed entries keeps the condition clear
+	c := &authOperator{configMaps: kubefake.NewSimpleClientset(testCLIConfigMap("*.apps.example.com")).CoreV1()}
+	c.handleOAuthServerSNIDrift(operatorConfig, routerSecret, ingress)
+	if got := condition(); got == nil || got.Status != operatorv1.ConditionFalse {
+		t.Errorf("expected a false condition for a matching config, got %#v", got)
+	}
+
+	// a config stuck on the old domain reports the drift
+	c.configMaps = kubefake.NewSimpleClientset(testCLIConfigMap("*.apps.stale.com")).CoreV1()
+	c.handleOAuthServerSNIDrift(operatorConfig, routerSecret, ingress)
+	if got := condition(); got == nil || got.Status != operatorv1.ConditionTrue || got.Reason != "SNIMismatch" {
+		t.Errorf("expected a true SNIMismatch condition, got %#v", got)
+	}
+	if got := condition(); !strings.Contains(got.Message, "*.apps.example.com") || !strings.Contains(got.Message, "*.apps.stale.com") {
+		t.Errorf("expected the message to name both entries, got %q", got.Message)
+	}
+
+	// a missing config map is a lookup failure, not a parse error
+	c.configMaps = kubefake.NewSimpleClientset().CoreV1()
+	c.handleOAuthServerSNIDrift(operatorConfig, routerSecret, ingress)
+	if got := condition(); got == nil || got.Status != operatorv1.ConditionTrue || got.Reason != "ConfigLookupFailed" {
+		t.Errorf("expected a true ConfigLookupFailed condition, got %#v", got)
+	}
+}
@@ -1,14 +1,14 @@
 package operator2
 
 import (
-	"encoding/json"
 	"fmt"
-	"io/ioutil"
 	"net/http"
 	"os"
-	"reflect"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"monis.app/go/openshift/controller"
 	"monis.app/go/openshift/operator"
@@ -21,6 +21,7 @@ import (
 	"k8s.io/client-go/kubernetes"
 	appsv1client "k8s.io/client-go/kubernetes/typed/apps/v1"
 	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/util/workqueue"
 	"k8s.io/klog"
 
 	configv1 "github.com/openshift/api/config/v1"
@@ -158,6 +159,9 @@ type authOperator struct {
 	recorder      events.Recorder
 
 	route routeclient.RouteInterface
+	// cluster wide route access, only used to report same-named routes that
+	// ended up in an unexpected namespace
+	routeGetter routeclient.RoutesGetter
 
 	oauthClientClient oauthclient.OAuthClientInterface
 
@@ -174,6 +178,241 @@ type authOperator struct {
 	ingress        configv1client.IngressInterface
 
 	resourceSyncer resourcesynccontroller.ResourceSyncer
+
+	// identity and port values baked into the expected route - the zero
+	// value means defaultRouteConfig(), see routeConfig
+	routeCfg routeConfig
+
+	// opt-in check that the issuer host resolves via cluster DNS - off by
+	// default because split-horizon DNS setups legitimately fail it.
+	// resolveHost is only swapped by tests, nil means net.LookupHost
+	checkIssuerDNS bool
+	resolveHost    func(host string) ([]string, error)
+
+	// opt-in check that the token endpoint answers a browser CORS preflight
+	// through the router - off by default because CORS expectations vary
+	// between deployments
+	checkTokenEndpointCORS bool
+
+	// opt-in cross-reference of the served metadata against the identity
+	// providers the oauth config carries - off by default because clusters
+	// mid-configuration legitimately disagree, see handleIDPConsistency
+	checkIDPConsistency bool
+
+	// opt-in probe of the metadata served by the oauth server's own service,
+	// the vantage point that localizes drift to the server itself or to the
+	// KAS/route layers in front of it - see handleOAuthServiceMetadata.
+	// oauthServiceRT is only swapped by tests, nil means a transport built
+	// from the service CA
+	checkOAuthServiceMetadata bool
+	oauthServiceRT            http.RoundTripper
+
+	// metadataSchemaConfigMap names a ConfigMap in userConfigNamespace whose
+	// schema.json holds a JSON schema the served well-known document must
+	// satisfy - empty disables the check and the field-by-field comparison
+	// stands alone, see handleMetadataSchema
+	metadataSchemaConfigMap string
+
+	// namespace and name of the kube api server service and endpoints the
+	// well-known probes enumerate - empty means default/kubernetes.  tests
+	// and unusual topologies point these at their own objects
+	kasNamespace string
+	kasName      string
+
+	// scopes that must be present in the scopes_supported of the well-known
+	// metadata - the server may advertise more
+	requiredScopes []string
+
+	// transitional issuer URLs that the well-known metadata is also allowed to
+	// report, used to keep readiness stable during an ingress domain migration
+	// when the old and the new issuer may both briefly be served.  empty
+	// outside of migrations.
+	acceptableIssuers []string
+
+	// prefix for the condition types and default reasons owned by the
+	// well-known readiness checks, see defaultConditionPrefix
+	conditionPrefix string
+
+	// optional annotation key on the operator config under which the
+	// conditions owned by the well-known readiness checks are mirrored as
+	// JSON - empty (the default) disables the mirror, see
+	// mirrorWellKnownConditions
+	mirrorConditionsAnnotation string
+
+	// User-Agent sent on the well-known probe requests, see
+	// defaultProbeUserAgent
+	userAgent string
+
+	// component suffix the operator's events are recorded under so several
+	// instances sharing a base recorder stay distinguishable - empty means
+	// the default, see eventRecorder
+	recorderComponentSuffix string
+	// recorder scoped with the component suffix, built on first use by
+	// eventRecorder - only touched from the sync loop
+	scopedRecorder events.Recorder
+
+	// clock is the source of time for expiry and duration based decisions so
+	// tests can pin it near interesting boundaries - use c.now(), never
+	// time.Now(), for any such comparison
+	clock func() time.Time
+
+	// shutdown is closed when the operator is stopping: in-flight probe
+	// round-trips are aborted and the sync returns the cancellation without
+	// writing half-computed conditions - nil (the default) means no shutdown
+	// signal is wired, see shuttingDown
+	shutdown <-chan struct{}
+
+	// optional client certificate and key for the well-known probe, for
+	// clusters that require mutual TLS to reach the KAS endpoints - both nil
+	// by default
+	probeClientCert []byte
+	probeClientKey  []byte
+
+	// minimum TLS version and optional cipher suites the probe transport
+	// enforces, for FIPS/hardened clusters - zero values mean the defaults,
+	// see defaultProbeMinTLSVersion
+	probeMinTLSVersion uint16
+	probeCipherSuites  []uint16
+
+	// approvedProbeCiphers optionally names the cipher suites an auditor
+	// considers acceptable for the probe connections - when set, negotiating
+	// anything else is logged as a warning, see noteNegotiatedCipherSuite.
+	// empty (the default) disables the audit.  distinct from
+	// probeCipherSuites, which restricts what the client offers
+	approvedProbeCiphers []uint16
+
+	// probePort overrides the port the well-known probe connects to, for
+	// deployments that serve the discovery document on a side port - zero
+	// (the default) means the resolved endpoint target port, see
+	// probePortOrDefault
+	probePort int
+
+	// rootCAPath overrides where the probes read the SA CA bundle from, for
+	// tests and unusual mounts - empty means the in-cluster default, see
+	// rootCAPathOrDefault
+	rootCAPath string
+
+	// set once the "not running in-cluster" state has been logged so the
+	// probe skip stays quiet afterwards - only touched from the sync loop,
+	// see runningInCluster
+	notInClusterLogged bool
+
+	// KAS members the admin excluded from probing for this sync, parsed from
+	// the exclusion annotation - only touched from the sync loop, see
+	// handleProbeExclusions
+	excludedProbeMembers []string
+
+	// probeSourceAddress optionally pins the local IP the probe transport
+	// dials from, for diagnosing asymmetric routing where some KAS members
+	// are only reachable from certain source networks - empty means the
+	// standard dialer picks the source, see probeDialer
+	probeSourceAddress string
+
+	// probeInterval decouples the probe cadence from the reconcile cadence -
+	// within the interval, syncs reuse the last probe outcome instead of
+	// re-probing every KAS member, see cachedWellknownResult.  zero means
+	// probe on every sync
+	probeInterval time.Duration
+
+	// probeCycleBudget bounds how long one probe cycle may spend across all
+	// members combined, zero meaning defaultProbeCycleBudget - see
+	// checkMembersWellknownReady
+	probeCycleBudget time.Duration
+
+	// member backoff bounds overriding the defaults, see recordMemberFailure -
+	// zero means probeMemberBackoffBase and probeMemberBackoffMax
+	memberBackoffBase time.Duration
+	memberBackoffMax  time.Duration
+
+	// probeBreakerThreshold overrides how many consecutive fully failed probe
+	// cycles open the circuit breaker, zero meaning the default - see
+	// probeBreakerOpen
+	probeBreakerThreshold int
+
+	// bookkeeping for the unsupportedConfigOverrides tunables - the raw bytes
+	// last applied and the field values before any override, so removing an
+	// override falls back to them - only touched from the sync loop, see
+	// applyUnsupportedTunables
+	lastTunablesRaw  string
+	baselineTunables *readinessTunables
+
+	// last host the oauth route was seen admitted at, used to announce each
+	// admission transition with a single event - only touched from the sync
+	// loop, see noteRouteAdmission
+	lastAdmittedHost string
+
+	// how many times handleRoute corrected or recreated the route since
+	// operator start, feeding the route-fight detection - only touched from
+	// the sync loop, see noteRouteCorrection
+	routeCorrections int
+
+	// whether a fresh well-known outcome was not ready since the last ready
+	// one, so the recovery event fires exactly once per transition - only
+	// touched from the sync loop, see noteWellknownRecovery
+	wellknownWasNotReady bool
+
+	// whether the pause annotation was set when the current sync started,
+	// gating every corrective write while the read-and-report machinery
+	// keeps running - only touched from the sync loop, see mutationAllowed
+	mutationsPaused bool
+
+	// last seen value of the force-reconcile annotation, so a bump drops the
+	// probe caches exactly once - only touched from the sync loop, see
+	// handleForceReconcile
+	lastForceReconcile string
+
+	// strictSNICheck opts in to verifying that the serving certificate a KAS
+	// member presents really carries the probe's SNI name in its SANs, see
+	// checkWellknownEndpointReady
+	strictSNICheck bool
+
+	// consecutive failure counts per check prefix for the degraded grace
+	// window, see handleDegradedWithGrace - degradedGraceCount overrides the
+	// number of failures tolerated in a row, zero meaning the default
+	failureCountLock    sync.Mutex
+	consecutiveFailures map[string]int
+	degradedGraceCount  int
+
+	// guards all cached probe state below - anything the probes read or
+	// write outside their own stack must be accessed under this lock
+	probeStateLock sync.Mutex
+
+	// sawReadyEndpoints records whether the kube api server endpoints ever
+	// had ready addresses - it separates first-boot (progressing) from a
+	// regression (degraded), see checkWellknownEndpointsReady
+	sawReadyEndpoints bool
+
+	// cached probe transport, rebuilt when the CA or client credentials
+	// change so connections to the KAS members survive across resyncs -
+	// see probeTransportFor
+	probeTransport           http.RoundTripper
+	probeTransportInner      *http.Transport
+	probeTransportKey        string
+	probeMaxIdleConnsPerHost int
+	// last metadata document received per KAS IP, only populated at high log
+	// verbosity and cleared once all members converge (see wellknown.go)
+	lastProbeMetadata map[string][]byte
+	// per-member outcome of the last probe cycle, see LastProbeResults
+	lastProbePassed []string
+	lastProbeFailed []string
+	// outcome of the last full readiness check, reused by syncs inside the
+	// probe interval - see probeInterval and cachedWellknownResult
+	lastWellknownResult *wellknownResult
+	// per-member negative results with exponential backoff so a known-down
+	// member is not re-probed at full frequency, see memberInBackoff
+	memberBackoffs map[string]*memberBackoff
+	// issuer from the last well-known document a probe decoded, consumed by
+	// the three-way issuer consistency check - see handleIssuerConsistency
+	lastServedIssuer string
+	// the last well-known document a probe decoded, consumed by the
+	// downstream schema check - see handleMetadataSchema
+	lastServedMetadata map[string]interface{}
+	// consecutive probe cycles in which no member passed, feeding the
+	// circuit breaker - see probeBreakerOpen
+	breakerFailures int
+	// cipher suite the last probe connection negotiated, for change-only
+	// logging - see noteNegotiatedCipherSuite
+	lastNegotiatedCipher uint16
 }
 
 func NewAuthenticationOperator(
@@ -195,7 +434,8 @@ func NewAuthenticationOperator(
 		versionGetter: versionGetter,
 		recorder:      recorder,
 
-		route: routeClient.Routes(targetNamespace),
+		route:       routeClient.Routes(targetNamespace),
+		routeGetter: routeClient,
 
 		oauthClientClient: oauthClientClient.OAuthClients(),
 
@@ -212,8 +452,15 @@ func NewAuthenticationOperator(
 		ingress:        configClient.ConfigV1().Ingresses(),
 
 		resourceSyncer: resourceSyncer,
+
+		requiredScopes:  defaultRequiredScopes(),
+		conditionPrefix: defaultConditionPrefix,
+		userAgent:       defaultProbeUserAgent,
+		clock:           time.Now,
 	}
 
+	c.logEffectiveConfig()
+
 	coreInformers := kubeInformersNamespaced.Core().V1()
 	configV1Informers := configInformers.Config().V1()
 
@@ -221,27 +468,180 @@ func NewAuthenticationOperator(
 	configNameFilter := operator.FilterByNames(globalConfigName)
 	prefixFilter := getPrefixFilter()
 
-	return operator.New("AuthenticationOperator2", c,
-		operator.WithInformer(routeInformer, targetNameFilter),
-		operator.WithInformer(coreInformers.Services(), targetNameFilter),
-		operator.WithInformer(kubeInformersNamespaced.Apps().V1().Deployments(), targetNameFilter),
+	return &runnerWithShutdown{c: c, delegate: newSingletonRunner(operatorName, c,
+		controller.WithRateLimiter(errorRequeueRateLimiter()),
+
+		singletonInformer(routeInformer, targetNameFilter),
+		singletonInformer(coreInformers.Services(), targetNameFilter),
+		singletonInformer(kubeInformersNamespaced.Apps().V1().Deployments(), targetNameFilter),
+
+		singletonInformer(coreInformers.Secrets(), prefixFilter),
+		singletonInformer(coreInformers.ConfigMaps(), prefixFilter),
+
+		singletonInformer(authOpConfigClient.Informers.Operator().V1().Authentications(), configNameFilter),
+		singletonInformer(configV1Informers.Authentications(), configNameFilter),
+		singletonInformer(configV1Informers.OAuths(), configNameFilter),
+		singletonInformer(configV1Informers.Consoles(), configNameFilter, controller.WithNoSync()),
+		singletonInformer(configV1Informers.Infrastructures(), configNameFilter, controller.WithNoSync()),
+		singletonInformer(configV1Informers.Ingresses(), configNameFilter, controller.WithNoSync()),
+	)}
+}
+
+const operatorName = "AuthenticationOperator2"
+
+// singletonRunner mirrors the vendored operator.New just closely enough to
+// build the underlying controller with a custom rate limiter, which the
+// vendored factory does not expose - and vendor/ is not ours to hand-edit.
+// every informer event maps onto the operator's one queue key (see
+// singletonInformer) and a single worker drains it, exactly like the
+// vendored factory.
+type singletonRunner struct {
+	name string
+	sync operator.KeySyncer
+	opts []controller.Option
+}
+
+func newSingletonRunner(name string, sync operator.KeySyncer, opts ...controller.Option) operator.Runner {
+	return &singletonRunner{name: name, sync: sync, opts: opts}
+}
+
+func (r *singletonRunner) Run(stopCh <-chan struct{}) {
+	runner := controller.New(r.name, singletonKeySyncer{KeySyncer: r.sync}, r.opts...)
+	// only start one worker because there is only one key in the queue -
+	// this operator works on a singleton, so more workers make no sense
+	runner.Run(1, stopCh)
+}
+
+// singletonKeySyncer adapts the keyless operator.KeySyncer to the keyed
+// interface the controller expects, the way the vendored wrapper does.
+type singletonKeySyncer struct {
+	operator.KeySyncer
+}
+
+func (s singletonKeySyncer) Key(namespace, name string) (metav1.Object, error) {
+	return s.KeySyncer.Key()
+}
+
+// singletonInformer is operator.WithInformer rebuilt on the controller
+// package: the filter decides which events matter, and every event that does
+// collapses onto the operator's singleton key.
+func singletonInformer(getter controller.InformerGetter, filter controller.Filter, opts ...controller.InformerOption) controller.Option {
+	return controller.WithInformer(getter, controller.FilterFuncs{
+		ParentFunc: func(obj metav1.Object) (string, string) {
+			return operatorName, operatorName // the singleton key for all events
+		},
+		AddFunc:    filter.Add,
+		UpdateFunc: filter.Update,
+		DeleteFunc: filter.Delete,
+	}, opts...)
+}
+
+// runnerWithShutdown hands the stop channel the factory passes to Run through
+// to the operator before the sync loop starts, so in-flight probes learn
+// about shutdown - the factory itself only uses the channel to stop the
+// workers between syncs, see shuttingDown.
+type runnerWithShutdown struct {
+	c        *authOperator
+	delegate operator.Runner
+}
+
+func (r *runnerWithShutdown) Run(stopCh <-chan struct{}) {
+	r.c.shutdown = stopCh
+	r.delegate.Run(stopCh)
+}
 
-		operator.WithInformer(coreInformers.Secrets(), prefixFilter),
-		operator.WithInformer(coreInformers.ConfigMaps(), prefixFilter),
+// the requeue backoff applied when Sync returns an error.  the default
+// workqueue rate limiter starts at 5ms, which against a persistently broken
+// dependency (an unreachable KAS, a stuck route) turns into a near-tight
+// retry loop that hammers both the operator and the API server.
+const (
+	errorRequeueBackoffBase = time.Second
+	errorRequeueBackoffMax  = 5 * time.Minute
+)
 
-		operator.WithInformer(authOpConfigClient.Informers.Operator().V1().Authentications(), configNameFilter),
-		operator.WithInformer(configV1Informers.Authentications(), configNameFilter),
-		operator.WithInformer(configV1Informers.OAuths(), configNameFilter),
-		operator.WithInformer(configV1Informers.Consoles(), configNameFilter, controller.WithNoSync()),
-		operator.WithInformer(configV1Informers.Infrastructures(), configNameFilter, controller.WithNoSync()),
-		operator.WithInformer(configV1Informers.Ingresses(), configNameFilter, controller.WithNoSync()),
-	)
+// errorRequeueRateLimiter returns the rate limiter for the operator's single
+// work queue key.  error requeues back off exponentially: 1s, 2s, 4s, ...
+// capped at 5m, reset by the next successful sync.  informer events and the
+// periodic resync bypass this entirely - they are added to the queue
+// directly, so a backed off operator still reacts to real changes
+// immediately.
+func errorRequeueRateLimiter() workqueue.RateLimiter {
+	return workqueue.NewItemExponentialFailureRateLimiter(errorRequeueBackoffBase, errorRequeueBackoffMax)
 }
 
 func (c *authOperator) Key() (metav1.Object, error) {
 	return c.authOperatorConfigClient.Client.Authentications().Get(globalConfigName, metav1.GetOptions{})
 }
 
+// defaultRecorderComponentSuffix scopes the operator's events under their own
+// component in the shared base recorder.
+const defaultRecorderComponentSuffix = "wellknown-ready-controller"
+
+// eventRecorder returns the recorder all events are emitted through, scoped
+// exactly once with the configured component suffix.
+func (c *authOperator) eventRecorder() events.Recorder {
+	if c.scopedRecorder == nil {
+		suffix := c.recorderComponentSuffix
+		if len(suffix) == 0 {
+			suffix = defaultRecorderComponentSuffix
+		}
+		c.scopedRecorder = c.recorder.WithComponentSuffix(suffix)
+	}
+	return c.scopedRecorder
+}
+
+// pauseAnnotation set to "true" on the operator config stops all mutation
+// while an admin debugs the oauth route - the sync keeps reading and
+// reporting, so conditions stay honest, but every corrective write is
+// skipped and a clear "reconciliation paused" condition is published until
+// the annotation is removed.  this is the operational escape hatch that
+// otherwise requires scaling the operator to zero.
+const pauseAnnotation = "authentication.operator.openshift.io/paused"
+
+// reconciliationPaused reports whether the pause annotation is set.
+func reconciliationPaused(obj metav1.Object) bool {
+	return obj.GetAnnotations()[pauseAnnotation] == "true"
+}
+
+// mutationAllowed reports whether the sync may perform the named write.
+// while reconciliation is paused the answer is no, with a log line naming
+// exactly what the operator would have done - the read-and-report machinery
+// around the gated call keeps running either way.
+func (c *authOperator) mutationAllowed(what string) bool {
+	if !c.mutationsPaused {
+		return true
+	}
+	klog.Infof("reconciliation is paused via the %s annotation - skipping %s", pauseAnnotation, what)
+	return false
+}
+
+// forceReconcileAnnotation gives admins a deterministic re-check after fixing
+// a KAS or route problem: bumping the value (any change counts, e.g. a
+// timestamp) makes the next sync drop the probe caches and verify everything
+// from scratch instead of waiting out the probe interval.  an unchanged value
+// does nothing, so the bump is one-shot rather than a permanent cache bypass.
+const forceReconcileAnnotation = "authentication.operator.openshift.io/force-reconcile"
+
+// handleForceReconcile drops the cached probe state once per change of the
+// force-reconcile annotation value.
+func (c *authOperator) handleForceReconcile(obj metav1.Object) {
+	value := obj.GetAnnotations()[forceReconcileAnnotation]
+	if len(value) == 0 || value == c.lastForceReconcile {
+		return
+	}
+	c.lastForceReconcile = value
+
+	klog.Infof("forcing a full re-check, the %s annotation changed to %q", forceReconcileAnnotation, value)
+	c.probeStateLock.Lock()
+	c.lastWellknownResult = nil
+	c.lastProbeMetadata = nil
+	c.memberBackoffs = nil
+	c.lastServedIssuer = ""
+	c.lastServedMetadata = nil
+	c.breakerFailures = 0
+	c.probeStateLock.Unlock()
+}
+
 func (c *authOperator) Sync(obj metav1.Object) error {
 	operatorConfig := obj.(*operatorv1.Authentication)
 
@@ -250,9 +650,26 @@ func (c *authOperator) Sync(obj metav1.Object) error {
 		return nil // TODO do something better for all states
 	}
 
+	// a paused operator keeps observing and reporting - the checks below all
+	// still run - but every corrective write is gated off, see mutationAllowed
+	c.mutationsPaused = reconciliationPaused(operatorConfig)
+
+	// a bumped force-reconcile annotation drops the probe caches so this
+	// cycle re-verifies everything from scratch
+	c.handleForceReconcile(operatorConfig)
+
+	// field-tunable timing knobs, see tunables.go
+	c.applyUnsupportedTunables(operatorConfig)
+
 	operatorConfigCopy := operatorConfig.DeepCopy()
 
 	syncErr := c.handleSync(operatorConfigCopy)
+	if isShuttingDownError(syncErr) {
+		// the conditions computed this far reflect an aborted cycle - writing
+		// them would report a half-probed cluster as its actual state
+		klog.V(2).Infof("skipping the status update: %v", syncErr)
+		return syncErr
+	}
 	// this is a catch all degraded state that we only set when we are otherwise not degraded
 	globalDegradedErr := syncErr
 	if isDegraded(operatorConfigCopy) {
@@ -260,7 +677,33 @@ func (c *authOperator) Sync(obj metav1.Object) error {
 	}
 	handleDegraded(operatorConfigCopy, "OperatorSync", globalDegradedErr)
 
-	if _, _, err := v1helpers.UpdateStatus(c.authOperatorConfigClient, func(status *operatorv1.OperatorStatus) error {
+	// the paused state must stay obvious in status no matter what Progressing
+	// the checks above computed
+	if c.mutationsPaused {
+		setProgressingTrue(operatorConfigCopy, "ReconciliationPaused", fmt.Sprintf("reconciliation is paused via the %s annotation - remove it to resume", pauseAnnotation))
+	}
+
+	// record the spec generation the conditions above were computed against
+	// so `oc wait` and automation can tell current status from stale status
+	operatorConfigCopy.Status.ObservedGeneration = operatorConfig.Generation
+
+	statusErr := c.updateOperatorStatus(c.authOperatorConfigClient, operatorConfigCopy, syncErr)
+
+	// optional mirror for tooling that cannot parse operator status - a
+	// broken mirror must never fail the sync
+	if err := c.mirrorWellKnownConditions(); err != nil {
+		klog.Warningf("failed to mirror the well-known conditions to annotation %q: %v", c.mirrorConditionsAnnotation, err)
+	}
+
+	return statusErr
+}
+
+// updateOperatorStatus pushes the conditions built during handleSync into the
+// operator config status, preserving last transition times.  a not-found on
+// the update means the config object was deleted mid-reconcile - there is
+// nothing left to update, so it is a quiet no-op instead of a sync failure.
+func (c *authOperator) updateOperatorStatus(client v1helpers.OperatorClient, operatorConfigCopy *operatorv1.Authentication, syncErr error) error {
+	if _, _, err := v1helpers.UpdateStatus(client, func(status *operatorv1.OperatorStatus) error {
 		// store a copy of our starting conditions, we need to preserve last transition time
 		originalConditions := status.DeepCopy().Conditions
 
@@ -275,8 +718,18 @@ func (c *authOperator) Sync(obj metav1.Object) error {
 			v1helpers.SetOperatorCondition(&status.Conditions, condition)
 		}
 
+		// always persist the conditions sorted by type - the order the checks
+		// discovered them in must not cause status churn or noisy yaml diffs
+		sort.Slice(status.Conditions, func(i, j int) bool {
+			return status.Conditions[i].Type < status.Conditions[j].Type
+		})
+
 		return nil
 	}); err != nil {
+		if errors.IsNotFound(err) {
+			klog.V(2).Infof("skipping status update, the operator config is gone: %v", err)
+			return syncErr
+		}
 		klog.Errorf("failed to update status: %v", err)
 		if syncErr == nil {
 			syncErr = err
@@ -286,6 +739,34 @@ func (c *authOperator) Sync(obj metav1.Object) error {
 	return syncErr
 }
 
+// applyConfigMap, applyService and applySecret wrap the resourceapply helpers
+// behind the pause gate: while reconciliation is paused the current object is
+// read instead of written, so the rest of the sync keeps computing conditions
+// against live state instead of stopping dead.
+func (c *authOperator) applyConfigMap(expected *corev1.ConfigMap) (*corev1.ConfigMap, error) {
+	if !c.mutationAllowed(fmt.Sprintf("applying config map %s/%s", expected.Namespace, expected.Name)) {
+		return c.configMaps.ConfigMaps(expected.Namespace).Get(expected.Name, metav1.GetOptions{})
+	}
+	configMap, _, err := resourceapply.ApplyConfigMap(c.configMaps, c.eventRecorder(), expected)
+	return configMap, err
+}
+
+func (c *authOperator) applyService(expected *corev1.Service) (*corev1.Service, error) {
+	if !c.mutationAllowed(fmt.Sprintf("applying service %s/%s", expected.Namespace, expected.Name)) {
+		return c.services.Services(expected.Namespace).Get(expected.Name, metav1.GetOptions{})
+	}
+	service, _, err := resourceapply.ApplyService(c.services, c.eventRecorder(), expected)
+	return service, err
+}
+
+func (c *authOperator) applySecret(expected *corev1.Secret) (*corev1.Secret, error) {
+	if !c.mutationAllowed(fmt.Sprintf("applying secret %s/%s", expected.Namespace, expected.Name)) {
+		return c.secrets.Secrets(expected.Namespace).Get(expected.Name, metav1.GetOptions{})
+	}
+	secret, _, err := resourceapply.ApplySecret(c.secrets, c.eventRecorder(), expected)
+	return secret, err
+}
+
 func (c *authOperator) handleSync(operatorConfig *operatorv1.Authentication) error {
 	// resourceVersions serves to store versions of config resources so that we
 	// can redeploy our payload should either change. We only omit the operator
@@ -313,20 +794,69 @@ func (c *authOperator) handleSync(operatorConfig *operatorv1.Authentication) err
 	resourceVersions = append(resourceVersions, ingress.GetResourceVersion())
 
 	route, routerSecret, err := c.handleRoute(ingress)
-	handleDegraded(operatorConfig, "RouteStatus", err)
+	if notAdmitted, ok := err.(*routeNotAdmittedError); ok {
+		// freshly created route - nothing to do until the router admits it,
+		// and the successful get resets the transient failure count
+		c.handleDegradedWithGrace(operatorConfig, "RouteStatus", nil)
+		c.setProgressingTrueAndAvailableFalse(operatorConfig, reasonRouteNotAdmitted, notAdmitted.Error())
+		return nil
+	}
+	if migrating, ok := err.(*domainMigrationError); ok {
+		// the old host is still admitted while the new one propagates - keep
+		// reconciling towards the new host without tearing the route down
+		c.handleDegradedWithGrace(operatorConfig, "RouteStatus", nil)
+		c.setProgressingTrueAndAvailableFalse(operatorConfig, "IngressDomainMigration", migrating.Error())
+		return nil
+	}
+	if stuck, ok := err.(*routeStuckTerminatingError); ok {
+		// the route is deleted but finalizers keep it around - nothing this
+		// operator does can finish the deletion, so name the stuck state
+		// instead of failing the sync over and over
+		c.handleDegradedWithGrace(operatorConfig, "RouteStatus", nil)
+		c.setProgressingTrueAndAvailableFalse(operatorConfig, "RouteStuckTerminating", stuck.Error())
+		return nil
+	}
+	if c.handleDegradedWithGrace(operatorConfig, "RouteStatus", err) {
+		// a transient lister/API hiccup - stay non-degraded and retry
+		setProgressingTrue(operatorConfig, "TransientError", fmt.Sprintf("failed handling the route: %v", err))
+		return nil
+	}
 	if err != nil {
 		return fmt.Errorf("failed handling the route: %v", err)
 	}
 	resourceVersions = append(resourceVersions, route.GetResourceVersion(), routerSecret.GetResourceVersion())
 
+	// give external tooling a stable signal when the route keeps changing
+	c.handleRouteDrift(operatorConfig, route)
+
+	// flag router secret keys that match no ingress domain
+	c.handleRouterCertsDomains(operatorConfig, routerSecret, ingress)
+
+	// publish the aggregated route+secret+ingress health as conditions
+	for _, condition := range c.routeHealthConditions(route, routerSecret, ingress) {
+		v1helpers.SetOperatorCondition(&operatorConfig.Status.Conditions, condition)
+	}
+
+	// an admitted route with no ready oauth server behind it forwards logins
+	// into a black hole while every route-level check stays green - surface
+	// the backend side separately
+	v1helpers.SetOperatorCondition(&operatorConfig.Status.Conditions, c.oauthBackendCondition(route, ingress))
+
+	// optionally verify the issuer host resolves in-cluster
+	c.handleIssuerDNS(operatorConfig, route)
+
 	// make sure API server sees our metadata as soon as we've got a route with a host
-	metadata, _, err := resourceapply.ApplyConfigMap(c.configMaps, c.recorder, getMetadataConfigMap(route))
+	metadata, err := c.applyConfigMap(c.getMetadataConfigMap(route))
 	if err != nil {
 		return fmt.Errorf("failure applying configMap for the .well-known endpoint: %v", err)
 	}
 	resourceVersions = append(resourceVersions, metadata.GetResourceVersion())
 
 	authConfig, err := c.handleAuthConfig()
+	if c.handleDegradedWithGrace(operatorConfig, "AuthConfigStatus", err) {
+		setProgressingTrue(operatorConfig, "TransientError", fmt.Sprintf("failed handling authentication config: %v", err))
+		return nil
+	}
 	if err != nil {
 		return fmt.Errorf("failed handling authentication config: %v", err)
 	}
@@ -337,7 +867,7 @@ func (c *authOperator) handleSync(operatorConfig *operatorv1.Authentication) err
 	// ==================================
 
 	// make sure we create the service before we start asking about service certs
-	service, _, err := resourceapply.ApplyService(c.services, c.recorder, defaultService())
+	service, err := c.applyService(defaultService())
 	if err != nil {
 		return fmt.Errorf("failed applying service object: %v", err)
 	}
@@ -357,7 +887,7 @@ func (c *authOperator) handleSync(operatorConfig *operatorv1.Authentication) err
 	if err != nil {
 		return fmt.Errorf("failed obtaining session secret: %v", err)
 	}
-	sessionSecret, _, err := resourceapply.ApplySecret(c.secrets, c.recorder, expectedSessionSecret)
+	sessionSecret, err := c.applySecret(expectedSessionSecret)
 	if err != nil {
 		return fmt.Errorf("failed applying session secret: %v", err)
 	}
@@ -369,30 +899,37 @@ func (c *authOperator) handleSync(operatorConfig *operatorv1.Authentication) err
 	infrastructureConfig := c.handleInfrastructureConfig()
 	resourceVersions = append(resourceVersions, infrastructureConfig.GetResourceVersion())
 
-	oauthConfig, expectedCLIconfig, syncData, err := c.handleOAuthConfig(operatorConfig, route, routerSecret, service, consoleConfig, infrastructureConfig)
+	oauthConfig, expectedCLIconfig, syncData, err := c.handleOAuthConfig(operatorConfig, route, routerSecret, service, consoleConfig, infrastructureConfig, ingress)
 	if err != nil {
 		return fmt.Errorf("failed handling OAuth configuration: %v", err)
 	}
 	resourceVersions = append(resourceVersions, oauthConfig.GetResourceVersion())
 
-	configResourceVersions, err := c.handleConfigSync(syncData)
-	if err != nil {
-		return fmt.Errorf("failed syncing configuration objects: %v", err)
+	if c.mutationAllowed("registering the IDP and template config sync rules") {
+		configResourceVersions, err := c.handleConfigSync(syncData)
+		if err != nil {
+			return fmt.Errorf("failed syncing configuration objects: %v", err)
+		}
+		resourceVersions = append(resourceVersions, configResourceVersions...)
 	}
-	resourceVersions = append(resourceVersions, configResourceVersions...)
 
-	cliConfig, _, err := resourceapply.ApplyConfigMap(c.configMaps, c.recorder, expectedCLIconfig)
+	cliConfig, err := c.applyConfigMap(expectedCLIconfig)
 	if err != nil {
 		return fmt.Errorf("failed applying configMap for the CLI configuration: %v", err)
 	}
 	resourceVersions = append(resourceVersions, cliConfig.GetResourceVersion())
 
+	// verify the applied config really carries the computed SNI entries
+	c.handleOAuthServerSNIDrift(operatorConfig, routerSecret, ingress)
+
 	// ==================================
 	// BLOCK 4: deployment
 	// ==================================
 
-	if err := c.ensureBootstrappedOAuthClients("https://" + route.Spec.Host); err != nil {
-		return err
+	if c.mutationAllowed("ensuring the bootstrap oauth clients") {
+		if err := c.ensureBootstrappedOAuthClients(c.issuerURL(route)); err != nil {
+			return err
+		}
 	}
 
 	operatorDeployment, err := c.deployments.Deployments(targetNamespaceOperator).Get(targetNameOperator, metav1.GetOptions{})
@@ -401,23 +938,32 @@ func (c *authOperator) handleSync(operatorConfig *operatorv1.Authentication) err
 	}
 	resourceVersions = append(resourceVersions, operatorDeployment.GetResourceVersion())
 
-	// deployment, have RV of all resources
-	expectedDeployment := defaultDeployment(
-		operatorConfig,
-		syncData,
-		routerSecret,
-		operatorDeployment,
-		resourceVersions...,
-	)
-	deployment, _, err := resourceapply.ApplyDeployment(
-		c.deployments,
-		c.recorder,
-		expectedDeployment,
-		resourcemerge.ExpectedDeploymentGeneration(expectedDeployment, operatorConfig.Status.Generations),
-		operatorConfig.Generation != operatorConfig.Status.ObservedGeneration, // redeploy on operatorConfig.spec changes
-	)
-	if err != nil {
-		return fmt.Errorf("failed applying deployment for the integrated OAuth server: %v", err)
+	var deployment *appsv1.Deployment
+	if c.mutationAllowed("applying the oauth server deployment") {
+		// deployment, have RV of all resources
+		expectedDeployment := defaultDeployment(
+			operatorConfig,
+			syncData,
+			routerSecret,
+			operatorDeployment,
+			resourceVersions...,
+		)
+		deployment, _, err = resourceapply.ApplyDeployment(
+			c.deployments,
+			c.eventRecorder(),
+			expectedDeployment,
+			resourcemerge.ExpectedDeploymentGeneration(expectedDeployment, operatorConfig.Status.Generations),
+			operatorConfig.Generation != operatorConfig.Status.ObservedGeneration, // redeploy on operatorConfig.spec changes
+		)
+		if err != nil {
+			return fmt.Errorf("failed applying deployment for the integrated OAuth server: %v", err)
+		}
+	} else {
+		// keep the readiness reporting below honest against the live deployment
+		deployment, err = c.deployments.Deployments(targetNamespace).Get(targetName, metav1.GetOptions{})
+		if err != nil {
+			return fmt.Errorf("failed getting deployment for the integrated OAuth server: %v", err)
+		}
 	}
 
 	// make sure we record the changes to the deployment
@@ -451,23 +997,68 @@ func (c *authOperator) handleVersion(
 	// route + well-known + OAuth client checks AND one available OAuth server pod
 	// but we do NOT want to go to the next version until all OAuth server pods are at that version
 
+	// recover the bootstrap-vs-regression marker across operator restarts
+	c.restoreEndpointsBootstrapMarker(operatorConfig)
+
 	routeReady, routeMsg, err := c.checkRouteHealthy(route, routerSecret, ingress)
 	handleDegraded(operatorConfig, "RouteHealth", err)
 	if err != nil {
 		return fmt.Errorf("unable to check route health: %v", err)
 	}
 	if !routeReady {
-		setProgressingTrueAndAvailableFalse(operatorConfig, "RouteNotReady", routeMsg)
+		c.setProgressingTrueAndAvailableFalse(operatorConfig, "RouteNotReady", routeMsg)
 		return nil
 	}
 
-	wellknownReady, wellknownMsg, err := c.checkWellknownEndpointsReady(authConfig, route)
-	handleDegraded(operatorConfig, "WellKnownEndpoint", err)
+	// track the admission age so a readiness delay right after admission can
+	// be told apart from a stale problem
+	admittedAgeMsg := c.recordRouteAdmittedAge(route, ingress)
+
+	// parse and surface any admin-requested probe exclusions before probing
+	c.handleProbeExclusions(operatorConfig)
+
+	wellknownReady, wellknownReason, wellknownMsg, err := c.checkWellknownEndpointsReady(authConfig, route)
+	if isShuttingDownError(err) {
+		// no degraded bookkeeping on the way out - a probe cycle aborted by
+		// shutdown says nothing about the endpoints
+		return err
+	}
+	handleDegraded(operatorConfig, c.wellKnownPrefix()+"Endpoint", err)
 	if err != nil {
 		return fmt.Errorf("unable to check the .well-known endpoint: %v", err)
 	}
+
+	// cross-check the issuer sources now that the probe refreshed the served
+	// document - drift across them is a condition of its own even while the
+	// readiness outcome below looks fine
+	c.handleIssuerConsistency(operatorConfig, authConfig, route)
+
+	// validate the served document against a downstream schema when one is
+	// configured, see handleMetadataSchema
+	c.handleMetadataSchema(operatorConfig)
+
+	// make reduced probing under an open circuit breaker visible
+	c.handleProbeBreaker(operatorConfig)
+
+	// warn before the probe CA bundle ages out, see handleProbeCAExpiry
+	c.handleProbeCAExpiry(operatorConfig)
+
+	// optionally verify a browser preflight against the token endpoint works
+	c.handleTokenEndpointCORS(operatorConfig, route, routerSecret, ingress)
+
+	// optionally triangulate metadata drift via the oauth server's own service
+	c.handleOAuthServiceMetadata(operatorConfig, route)
+
+	// optionally cross-reference the served document with the configured IdPs
+	c.handleIDPConsistency(operatorConfig)
 	if !wellknownReady {
-		setProgressingTrueAndAvailableFalse(operatorConfig, "WellKnownNotReady", wellknownMsg)
+		if len(wellknownReason) == 0 {
+			wellknownReason = c.reasonNotReady()
+		}
+		if len(admittedAgeMsg) != 0 {
+			wellknownMsg = fmt.Sprintf("%s (%s)", wellknownMsg, admittedAgeMsg)
+		}
+		c.setProgressingTrueAndAvailableFalse(operatorConfig, wellknownReason, wellknownMsg)
 		return nil
 	}
 
@@ -477,7 +1068,7 @@ func (c *authOperator) handleVersion(
 		return fmt.Errorf("unable to check OAuth clients' readiness: %v", err)
 	}
 	if !oauthClientsReady {
-		setProgressingTrueAndAvailableFalse(operatorConfig, "OAuthClientNotReady", oauthClientsMsg)
+		c.setProgressingTrueAndAvailableFalse(operatorConfig, "OAuthClientNotReady", oauthClientsMsg)
 		return nil
 	}
 
@@ -487,7 +1078,13 @@ func (c *authOperator) handleVersion(
 
 	// we have achieved our desired level
 	setProgressingFalse(operatorConfig)
-	setAvailableTrue(operatorConfig, "AsExpected")
+	if wellknownReason == reasonNotApplicable {
+		// the well-known check never ran - make that legible instead of
+		// implying the integrated OAuth path was actively verified
+		setAvailableTrueWithMessage(operatorConfig, reasonNotApplicable, wellknownMsg)
+	} else {
+		setAvailableTrue(operatorConfig, "AsExpected")
+	}
 	c.setVersion(operatorSelfName, operatorVersion)
 	c.setVersion(targetName, oauthserverVersion)
 
@@ -498,7 +1095,7 @@ func (c *authOperator) checkDeploymentReady(deployment *appsv1.Deployment, opera
 	reason := "OAuthServerDeploymentNotReady"
 
 	if deployment.DeletionTimestamp != nil {
-		setProgressingTrueAndAvailableFalse(operatorConfig, reason, "deployment is being deleted")
+		c.setProgressingTrueAndAvailableFalse(operatorConfig, reason, "deployment is being deleted")
 		return false
 	}
 
@@ -547,145 +1144,122 @@ func (c *authOperator) checkRouteHealthy(route *routev1.Route, routerSecret *cor
 	return true, "", nil
 }
 
-func (c *authOperator) checkWellknownEndpointsReady(authConfig *configv1.Authentication, route *routev1.Route) (bool, string, error) {
-	// TODO: don't perform this check when OAuthMetadata reference is set up,
-	// the code in configmap.go does not handle such cases yet
-	if len(authConfig.Spec.OAuthMetadata.Name) != 0 || authConfig.Spec.Type != configv1.AuthenticationTypeIntegratedOAuth {
-		return true, "", nil
-	}
-
-	caData, err := ioutil.ReadFile(rootCAFile)
-	if err != nil {
-		return false, "", fmt.Errorf("failed to read SA ca.crt: %v", err)
-	}
-
-	// pass the KAS service name for SNI
-	rt, err := transportFor(kasServiceFullName, caData, nil, nil)
+func (c *authOperator) oauthClientsReady(route *routev1.Route) (bool, string, error) {
+	_, err := c.oauthClientClient.Get(oauthBrowserClientName, metav1.GetOptions{})
 	if err != nil {
-		return false, "", fmt.Errorf("failed to build transport for SA ca.crt: %v", err)
+		if errors.IsNotFound(err) {
+			return false, "browser oauthclient does not exist", nil
+		}
+		return false, "", err
 	}
 
-	ips, err := c.getAPIServerIPs()
+	_, err = c.oauthClientClient.Get(oauthChallengingClientName, metav1.GetOptions{})
 	if err != nil {
-		return false, "", fmt.Errorf("failed to get API server IPs: %v", err)
-	}
-
-	for _, ip := range ips {
-		wellknownReady, wellknownMsg, err := c.checkWellknownEndpointReady(ip, rt, route)
-		if err != nil || !wellknownReady {
-			return wellknownReady, wellknownMsg, err
+		if errors.IsNotFound(err) {
+			return false, "challenging oauthclient does not exist", nil
 		}
+		return false, "", err
 	}
 
 	return true, "", nil
 }
 
-func (c *authOperator) getAPIServerIPs() ([]string, error) {
-	kasService, err := c.services.Services(corev1.NamespaceDefault).Get(kasServiceAndEndpointName, metav1.GetOptions{})
-	if err != nil {
-		return nil, fmt.Errorf("failed to get kube api server service: %v", err)
-	}
-
-	targetPort, ok := getKASTargetPortFromService(kasService)
-	if !ok {
-		return nil, fmt.Errorf("unable to find kube api server service target port: %#v", kasService)
+// effectiveConfigLines renders every tunable of the readiness checks with its
+// effective value, defaults resolved, one setting per line.  with this many
+// knobs, support engineers need a single place to see what is actually in
+// effect on a given cluster - this is a read-only reflection, never input.
+func (c *authOperator) effectiveConfigLines() []string {
+	cfg := c.routeConfig()
+	kasNamespace, kasName := c.kasLocation()
+
+	maxIdle := c.probeMaxIdleConnsPerHost
+	if maxIdle <= 0 {
+		maxIdle = defaultProbeMaxIdleConnsPerHost
 	}
-
-	kasEndpoint, err := c.endpoints.Endpoints(corev1.NamespaceDefault).Get(kasServiceAndEndpointName, metav1.GetOptions{})
-	if err != nil {
-		return nil, fmt.Errorf("failed to get kube api server endpoints: %v", err)
+	graceCount := c.degradedGraceCountOrDefault()
+	required := c.requiredScopes
+	if required == nil {
+		required = defaultRequiredScopes()
 	}
-
-	for _, subset := range kasEndpoint.Subsets {
-		if !subsetHasKASTargetPort(subset, targetPort) {
-			continue
-		}
-
-		if len(subset.NotReadyAddresses) != 0 || len(subset.Addresses) == 0 {
-			return nil, fmt.Errorf("kube api server endpoints is not ready: %#v", kasEndpoint)
-		}
-
-		ips := make([]string, 0, len(subset.Addresses))
-		for _, address := range subset.Addresses {
-			ips = append(ips, fmt.Sprintf("%s:%d", address.IP, targetPort))
-		}
-		return ips, nil
+	acceptableIssuers := "none"
+	if len(c.acceptableIssuers) != 0 {
+		acceptableIssuers = strings.Join(c.acceptableIssuers, ", ")
 	}
-
-	return nil, fmt.Errorf("unable to find kube api server endpoints port: %#v", kasEndpoint)
-}
-
-func getKASTargetPortFromService(service *corev1.Service) (int, bool) {
-	for _, port := range service.Spec.Ports {
-		if targetPort := port.TargetPort.IntValue(); targetPort != 0 && port.Protocol == corev1.ProtocolTCP && int(port.Port) == kasServicePort {
-			return targetPort, true
-		}
+	mirror := "disabled"
+	if len(c.mirrorConditionsAnnotation) != 0 {
+		mirror = c.mirrorConditionsAnnotation
 	}
-	return 0, false
-}
-
-func subsetHasKASTargetPort(subset corev1.EndpointSubset, targetPort int) bool {
-	for _, port := range subset.Ports {
-		if port.Protocol == corev1.ProtocolTCP && int(port.Port) == targetPort {
-			return true
-		}
+	sourceAddress := "automatic"
+	if len(c.probeSourceAddress) != 0 {
+		sourceAddress = c.probeSourceAddress
 	}
-	return false
-}
-
-func (c *authOperator) checkWellknownEndpointReady(apiIP string, rt http.RoundTripper, route *routev1.Route) (bool, string, error) {
-	wellKnown := "https://" + apiIP + oauthMetadataAPIEndpoint
-
-	req, err := http.NewRequest(http.MethodGet, wellKnown, nil)
-	if err != nil {
-		return false, "", fmt.Errorf("failed to build request to well-known %s: %v", wellKnown, err)
+	probeInterval := "every sync"
+	if c.probeInterval > 0 {
+		probeInterval = c.probeInterval.String()
 	}
-
-	resp, err := rt.RoundTrip(req)
-	if err != nil {
-		return false, "", fmt.Errorf("failed to GET well-known %s: %v", wellKnown, err)
+	probePort := "endpoint target port"
+	if c.probePort > 0 {
+		probePort = strconv.Itoa(c.probePort)
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != 200 {
-		return false, fmt.Sprintf("got '%s' status while trying to GET the OAuth well-known %s endpoint data", resp.Status, wellKnown), nil
+	recorderSuffix := c.recorderComponentSuffix
+	if len(recorderSuffix) == 0 {
+		recorderSuffix = defaultRecorderComponentSuffix
 	}
-
-	var receivedValues map[string]interface{}
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return false, "", fmt.Errorf("failed to read well-known %s body: %v", wellKnown, err)
+	approvedCiphers := "not audited"
+	if len(c.approvedProbeCiphers) != 0 {
+		approvedCiphers = strings.Join(cipherSuiteNames(c.approvedProbeCiphers), ", ")
 	}
-	if err := json.Unmarshal(body, &receivedValues); err != nil {
-		return false, "", fmt.Errorf("failed to marshall well-known %s JSON: %v", wellKnown, err)
+	metadataSchema := "none"
+	if len(c.metadataSchemaConfigMap) != 0 {
+		metadataSchema = userConfigNamespace + "/" + c.metadataSchemaConfigMap
 	}
 
-	expectedMetadata := getMetadataStruct(route)
-	if !reflect.DeepEqual(expectedMetadata, receivedValues) {
-		return false, fmt.Sprintf("the value returned by the well-known %s endpoint does not match expectations", wellKnown), nil
+	return []string{
+		fmt.Sprintf("route: %s/%s (container port %d)", cfg.namespace, cfg.name, cfg.containerPort),
+		fmt.Sprintf("kube api server service and endpoints: %s/%s", kasNamespace, kasName),
+		fmt.Sprintf("condition prefix: %s", c.wellKnownPrefix()),
+		fmt.Sprintf("probe user agent: %s", c.probeUserAgent()),
+		fmt.Sprintf("probe max idle connections per host: %d", maxIdle),
+		fmt.Sprintf("probe minimum TLS version: %s", tlsVersionName(c.probeMinTLSVersionOrDefault())),
+		fmt.Sprintf("approved probe cipher suites: %s", approvedCiphers),
+		fmt.Sprintf("probe source address: %s", sourceAddress),
+		fmt.Sprintf("probe interval: %s", probeInterval),
+		fmt.Sprintf("probe cycle budget: %s", c.probeCycleBudgetOrDefault()),
+		fmt.Sprintf("probe port: %s", probePort),
+		fmt.Sprintf("probe CA bundle: %s", c.rootCAPathOrDefault()),
+		fmt.Sprintf("required scopes: %s", strings.Join(required, ", ")),
+		fmt.Sprintf("acceptable transitional issuers: %s", acceptableIssuers),
+		fmt.Sprintf("degraded grace count: %d", graceCount),
+		fmt.Sprintf("member backoff: base %s, max %s", c.memberBackoffBaseOrDefault(), c.memberBackoffMaxOrDefault()),
+		fmt.Sprintf("error requeue backoff: base %s, max %s", errorRequeueBackoffBase, errorRequeueBackoffMax),
+		fmt.Sprintf("probe circuit breaker threshold: %d fully failed cycles", c.probeBreakerThresholdOrDefault()),
+		fmt.Sprintf("issuer DNS check: %v", c.checkIssuerDNS),
+		fmt.Sprintf("token endpoint CORS preflight check: %v", c.checkTokenEndpointCORS),
+		fmt.Sprintf("oauth service metadata check: %v", c.checkOAuthServiceMetadata),
+		fmt.Sprintf("identity provider consistency check: %v", c.checkIDPConsistency),
+		fmt.Sprintf("metadata schema config map: %s", metadataSchema),
+		fmt.Sprintf("condition mirror annotation: %s", mirror),
+		fmt.Sprintf("event component suffix: %s", recorderSuffix),
 	}
-
-	return true, "", nil
 }
 
-func (c *authOperator) oauthClientsReady(route *routev1.Route) (bool, string, error) {
-	_, err := c.oauthClientClient.Get(oauthBrowserClientName, metav1.GetOptions{})
-	if err != nil {
-		if errors.IsNotFound(err) {
-			return false, "browser oauthclient does not exist", nil
-		}
-		return false, "", err
+// logEffectiveConfig prints the effective runtime configuration as one block
+// at startup so it lands in every must-gather at the default support log
+// level.
+func (c *authOperator) logEffectiveConfig() {
+	if !bool(klog.V(2)) {
+		return
 	}
+	klog.Infof("effective configuration:\n\t%s", strings.Join(c.effectiveConfigLines(), "\n\t"))
+}
 
-	_, err = c.oauthClientClient.Get(oauthChallengingClientName, metav1.GetOptions{})
-	if err != nil {
-		if errors.IsNotFound(err) {
-			return false, "challenging oauthclient does not exist", nil
-		}
-		return false, "", err
+// now returns the current time from the injectable clock, falling back to the
+// wall clock when none was set (i.e. bare structs in tests).
+func (c *authOperator) now() time.Time {
+	if c.clock == nil {
+		return time.Now()
 	}
-
-	return true, "", nil
+	return c.clock()
 }
 
 func (c *authOperator) setVersion(operandName, version string) {
@@ -0,0 +1,425 @@
+package operator2
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/library-go/pkg/operator/events"
+	"github.com/openshift/library-go/pkg/operator/v1helpers"
+)
+
+// fakeOperatorClient is a minimal v1helpers.OperatorClient for status update
+// tests.  it is safe for concurrent use.
+type fakeOperatorClient struct {
+	lock      sync.Mutex
+	status    operatorv1.OperatorStatus
+	updateErr error
+	updates   int
+}
+
+func (f *fakeOperatorClient) Informer() cache.SharedIndexInformer { return nil }
+
+func (f *fakeOperatorClient) GetOperatorState() (*operatorv1.OperatorSpec, *operatorv1.OperatorStatus, string, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return &operatorv1.OperatorSpec{}, f.status.DeepCopy(), "1", nil
+}
+
+func (f *fakeOperatorClient) UpdateOperatorSpec(resourceVersion string, spec *operatorv1.OperatorSpec) (*operatorv1.OperatorSpec, string, error) {
+	return spec, resourceVersion, nil
+}
+
+func (f *fakeOperatorClient) UpdateOperatorStatus(resourceVersion string, status *operatorv1.OperatorStatus) (*operatorv1.OperatorStatus, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.updates++
+	if f.updateErr != nil {
+		return nil, f.updateErr
+	}
+	f.status = *status.DeepCopy()
+	return status, nil
+}
+
+func (f *fakeOperatorClient) snapshot() operatorv1.OperatorStatus {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return *f.status.DeepCopy()
+}
+
+func TestUpdateOperatorStatusNotFound(t *testing.T) {
+	operatorConfigCopy := &operatorv1.Authentication{}
+	setProgressingTrue(operatorConfigCopy, "Testing", "forcing a status update")
+
+	// the config object disappearing mid-reconcile is benign
+	client := &fakeOperatorClient{updateErr: errors.NewNotFound(operatorv1.Resource("authentications"), globalConfigName)}
+	c := &authOperator{}
+	if err := c.updateOperatorStatus(client, operatorConfigCopy, nil); err != nil {
+		t.Errorf("expected a not-found status update to be a no-op, got: %v", err)
+	}
+	if client.updates == 0 {
+		t.Error("expected an update attempt")
+	}
+
+	// a sync error is still reported even when the update is skipped
+	syncErr := fmt.Errorf("sync went sideways")
+	if err := c.updateOperatorStatus(client, operatorConfigCopy, syncErr); err != syncErr {
+		t.Errorf("expected the original sync error, got: %v", err)
+	}
+
+	// other update failures still surface
+	client.updateErr = errors.NewInternalError(fmt.Errorf("boom"))
+	if err := c.updateOperatorStatus(client, operatorConfigCopy, nil); err == nil {
+		t.Error("expected a non-not-found update failure to surface")
+	}
+}
+
+func TestUpdateOperatorStatusObservedGeneration(t *testing.T) {
+	client := &fakeOperatorClient{}
+	c := &authOperator{}
+
+	operatorConfigCopy := &operatorv1.Authentication{}
+	operatorConfigCopy.Status.ObservedGeneration = 5
+	setProgressingTrue(operatorConfigCopy, "Testing", "forcing a status update")
+
+	if err := c.updateOperatorStatus(client, operatorConfigCopy, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if client.status.ObservedGeneration != 5 {
+		t.Errorf("ObservedGeneration = %d, want 5", client.status.ObservedGeneration)
+	}
+}
+
+// TestLastTransitionTimeStability guards the duration-based features (elapsed
+// failure reporting, grace periods) - they are all broken if rebuilding the
+// conditions every sync resets the transition timestamps.
+func TestLastTransitionTimeStability(t *testing.T) {
+	transition := metav1.NewTime(time.Date(2019, time.April, 1, 12, 0, 0, 0, time.UTC))
+
+	client := &fakeOperatorClient{
+		status: operatorv1.OperatorStatus{
+			Conditions: []operatorv1.OperatorCondition{
+				{
+					Type:               operatorv1.OperatorStatusTypeProgressing,
+					Status:             operatorv1.ConditionTrue,
+					Reason:             "NotReady",
+					Message:            "initial message",
+					LastTransitionTime: transition,
+				},
+			},
+		},
+	}
+	c := &authOperator{}
+
+	find := func() operatorv1.OperatorCondition {
+		condition := v1helpers.FindOperatorCondition(client.status.Conditions, operatorv1.OperatorStatusTypeProgressing)
+		if condition == nil {
+			t.Fatal("Progressing condition not found")
+		}
+		return *condition
+	}
+
+	// several syncs that keep the condition true, even with a changed message,
+	// must not touch the transition time
+	for i := 0; i < 3; i++ {
+		operatorConfigCopy := &operatorv1.Authentication{}
+		setProgressingTrue(operatorConfigCopy, "NotReady", fmt.Sprintf("sync %d message", i))
+		if err := c.updateOperatorStatus(client, operatorConfigCopy, nil); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got := find(); !got.LastTransitionTime.Equal(&transition) {
+			t.Fatalf("sync %d reset LastTransitionTime to %v", i, got.LastTransitionTime)
+		}
+	}
+	if got := find(); got.Message != "sync 2 message" {
+		t.Errorf("expected message updates to flow through, got %q", got.Message)
+	}
+
+	// an actual status flip must move the transition time
+	operatorConfigCopy := &operatorv1.Authentication{}
+	setProgressingFalse(operatorConfigCopy)
+	if err := c.updateOperatorStatus(client, operatorConfigCopy, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := find(); got.LastTransitionTime.Equal(&transition) {
+		t.Error("expected a status flip to update LastTransitionTime")
+	}
+}
+
+// TestConcurrentStatusUpdateConsistency hammers the status update path from
+// several goroutines, alternating healthy and failing condition sets, and
+// asserts the surviving condition set is internally consistent.  each writer
+// publishes a coherent set, so no interleaving may yield Available=true next
+// to a true Degraded condition.
+func TestConcurrentStatusUpdateConsistency(t *testing.T) {
+	client := &fakeOperatorClient{}
+	c := &authOperator{}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		i := i
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 25; j++ {
+				operatorConfigCopy := &operatorv1.Authentication{}
+				if (i+j)%2 == 0 {
+					handleDegraded(operatorConfigCopy, "RouteHealth", nil)
+					setProgressingFalse(operatorConfigCopy)
+					setAvailableTrue(operatorConfigCopy, "AsExpected")
+				} else {
+					handleDegraded(operatorConfigCopy, "RouteHealth", fmt.Errorf("route broke"))
+					c.setProgressingTrueAndAvailableFalse(operatorConfigCopy, "RouteNotReady", "route broke")
+				}
+				if err := c.updateOperatorStatus(client, operatorConfigCopy, nil); err != nil {
+					t.Errorf("unexpected error: %v", err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	conditions := client.snapshot().Conditions
+	for _, conditionType := range []string{
+		operatorv1.OperatorStatusTypeAvailable,
+		operatorv1.OperatorStatusTypeProgressing,
+		"RouteHealth" + operatorv1.OperatorStatusTypeDegraded,
+	} {
+		if v1helpers.FindOperatorCondition(conditions, conditionType) == nil {
+			t.Errorf("condition %s missing from the final set", conditionType)
+		}
+	}
+	available := v1helpers.FindOperatorCondition(conditions, operatorv1.OperatorStatusTypeAvailable)
+	degraded := v1helpers.FindOperatorCondition(conditions, "RouteHealth"+operatorv1.OperatorStatusTypeDegraded)
+	if available != nil && degraded != nil &&
+		available.Status == operatorv1.ConditionTrue && degraded.Status == operatorv1.ConditionTrue {
+		t.Errorf("inconsistent final set: Available=true together with %s=true", degraded.Type)
+	}
+}
+
+// TestConditionOrderingDeterministic asserts that the order the checks happen
+// to discover conditions in never leaks into the persisted status - unstable
+// ordering causes needless status churn and noisy yaml diffs in GitOps.
+func TestConditionOrderingDeterministic(t *testing.T) {
+	c := &authOperator{}
+
+	conditionTypes := []string{
+		"RouteHealthDegraded",
+		operatorv1.OperatorStatusTypeAvailable,
+		"WellKnownEndpointDegraded",
+		operatorv1.OperatorStatusTypeProgressing,
+	}
+
+	persist := func(order []int) []operatorv1.OperatorCondition {
+		client := &fakeOperatorClient{}
+		operatorConfigCopy := &operatorv1.Authentication{}
+		for _, i := range order {
+			v1helpers.SetOperatorCondition(&operatorConfigCopy.Status.Conditions, operatorv1.OperatorCondition{
+				Type:   conditionTypes[i],
+				Status: operatorv1.ConditionFalse,
+			})
+		}
+		if err := c.updateOperatorStatus(client, operatorConfigCopy, nil); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return client.snapshot().Conditions
+	}
+
+	first := persist([]int{0, 1, 2, 3})
+	second := persist([]int{3, 2, 1, 0})
+
+	for i := 1; i < len(first); i++ {
+		if first[i-1].Type >= first[i].Type {
+			t.Errorf("persisted conditions are not sorted by type: %q before %q", first[i-1].Type, first[i].Type)
+		}
+	}
+	if len(first) != len(second) {
+		t.Fatalf("expected the same condition set, got %d vs %d", len(first), len(second))
+	}
+	for i := range first {
+		if first[i].Type != second[i].Type {
+			t.Errorf("discovery order leaked into the persisted order: %q vs %q at %d", first[i].Type, second[i].Type, i)
+		}
+	}
+}
+
+func TestReconciliationPaused(t *testing.T) {
+	operatorConfig := &operatorv1.Authentication{}
+	if reconciliationPaused(operatorConfig) {
+		t.Error("a config without annotations must not be paused")
+	}
+
+	operatorConfig.Annotations = map[string]string{pauseAnnotation: "true"}
+	if !reconciliationPaused(operatorConfig) {
+		t.Error("expected the pause annotation to pause reconciliation")
+	}
+
+	// only the literal "true" pauses - anything else is ignored
+	operatorConfig.Annotations[pauseAnnotation] = "yes"
+	if reconciliationPaused(operatorConfig) {
+		t.Error("a non-true value must not pause reconciliation")
+	}
+
+	// the pause gates writes, it does not stop the sync
+	c := &authOperator{mutationsPaused: true}
+	if c.mutationAllowed("a corrective write") {
+		t.Error("expected writes to be refused while paused")
+	}
+	c.mutationsPaused = false
+	if !c.mutationAllowed("a corrective write") {
+		t.Error("expected writes to be allowed while not paused")
+	}
+}
+
+// TestHandleForceReconcile asserts the force-reconcile annotation drops the
+// probe caches exactly once per value change - a bump forces one fresh cycle,
+// an unchanged value is not a permanent cache bypass.
+func TestHandleForceReconcile(t *testing.T) {
+	now := time.Date(2019, time.June, 1, 12, 0, 0, 0, time.UTC)
+	c := &authOperator{clock: func() time.Time { return now }, probeInterval: time.Hour}
+
+	route := testRoute("oauth-openshift.apps.example.com")
+	cached := func() bool {
+		_, ok := c.cachedWellknownResult(route)
+		return ok
+	}
+	config := &operatorv1.Authentication{}
+
+	// no annotation leaves the cache alone
+	c.storeWellknownResult(route, true, "", "")
+	c.handleForceReconcile(config)
+	if !cached() {
+		t.Fatal("expected the cache to survive a sync without the annotation")
+	}
+
+	// a new value drops the cache
+	config.Annotations = map[string]string{forceReconcileAnnotation: "1"}
+	c.handleForceReconcile(config)
+	if cached() {
+		t.Fatal("expected the annotation bump to drop the cache")
+	}
+
+	// the same value seen again is a no-op
+	c.storeWellknownResult(route, true, "", "")
+	c.handleForceReconcile(config)
+	if !cached() {
+		t.Fatal("expected an unchanged annotation value to leave the cache alone")
+	}
+
+	// another bump forces again
+	config.Annotations[forceReconcileAnnotation] = "2"
+	c.handleForceReconcile(config)
+	if cached() {
+		t.Fatal("expected a second bump to drop the cache again")
+	}
+}
+
+// TestEventRecorderComponentSuffix asserts that events carry the configured
+// component suffix, that the default applies when none is set, and that the
+// suffix is appended exactly once no matter how many events are emitted.
+func TestEventRecorderComponentSuffix(t *testing.T) {
+	recorder := events.NewInMemoryRecorder("test")
+	c := &authOperator{recorder: recorder}
+	c.eventRecorder().Eventf("Probe", "first")
+	c.eventRecorder().Eventf("Probe", "second")
+	for _, event := range recorder.Events() {
+		if got, want := event.Source.Component, "test-"+defaultRecorderComponentSuffix; got != want {
+			t.Errorf("event component = %q, want %q", got, want)
+		}
+	}
+
+	recorder = events.NewInMemoryRecorder("test")
+	c = &authOperator{recorder: recorder, recorderComponentSuffix: "downstream-auth"}
+	c.eventRecorder().Eventf("Probe", "custom")
+	for _, event := range recorder.Events() {
+		if got, want := event.Source.Component, "test-downstream-auth"; got != want {
+			t.Errorf("event component = %q, want %q", got, want)
+		}
+	}
+}
+
+func TestEffectiveConfigLines(t *testing.T) {
+	// defaults are resolved, not printed as zero values
+	lines := strings.Join((&authOperator{}).effectiveConfigLines(), "\n")
+	for _, want := range []string{
+		"route: openshift-authentication/oauth-openshift",
+		"condition prefix: WellKnown",
+		"probe minimum TLS version: TLS 1.2",
+		"acceptable transitional issuers: none",
+		"condition mirror annotation: disabled",
+	} {
+		if !strings.Contains(lines, want) {
+			t.Errorf("expected the config block to contain %q, got:\n%s", want, lines)
+		}
+	}
+
+	// overrides show up as the effective values
+	custom := &authOperator{
+		conditionPrefix:            "Custom",
+		degradedGraceCount:         7,
+		mirrorConditionsAnnotation: "example.com/conditions",
+	}
+	lines = strings.Join(custom.effectiveConfigLines(), "\n")
+	for _, want := range []string{
+		"condition prefix: Custom",
+		"degraded grace count: 7",
+		"condition mirror annotation: example.com/conditions",
+	} {
+		if !strings.Contains(lines, want) {
+			t.Errorf("expected the config block to contain %q, got:\n%s", want, lines)
+		}
+	}
+}
+
+func TestInjectableClock(t *testing.T) {
+	frozen := time.Date(2019, time.May, 1, 12, 0, 0, 0, time.UTC)
+
+	c := &authOperator{clock: func() time.Time { return frozen }}
+	if got := c.now(); !got.Equal(frozen) {
+		t.Errorf("now() = %v, want the injected time %v", got, frozen)
+	}
+
+	// a bare struct must still work, falling back to the wall clock
+	bare := &authOperator{}
+	if got := bare.now(); got.IsZero() {
+		t.Error("now() on a bare struct returned the zero time")
+	}
+}
+
+func TestErrorRequeueRateLimiter(t *testing.T) {
+	limiter := errorRequeueRateLimiter()
+	key := "singleton"
+
+	// successive failures back off exponentially from the base
+	for i, want := range []time.Duration{
+		errorRequeueBackoffBase,
+		2 * errorRequeueBackoffBase,
+		4 * errorRequeueBackoffBase,
+	} {
+		if got := limiter.When(key); got != want {
+			t.Errorf("failure %d: When() = %s, want %s", i+1, got, want)
+		}
+	}
+
+	// the backoff never exceeds the cap no matter how long the outage lasts
+	for i := 0; i < 30; i++ {
+		limiter.When(key)
+	}
+	if got := limiter.When(key); got != errorRequeueBackoffMax {
+		t.Errorf("expected the backoff to cap at %s, got %s", errorRequeueBackoffMax, got)
+	}
+
+	// a successful sync forgets the key and the backoff starts over
+	limiter.Forget(key)
+	if got := limiter.When(key); got != errorRequeueBackoffBase {
+		t.Errorf("expected the backoff to reset to %s after Forget, got %s", errorRequeueBackoffBase, got)
+	}
+}
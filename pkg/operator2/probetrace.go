@@ -0,0 +1,98 @@
+package operator2
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"net/http/httptrace"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/klog"
+)
+
+// wellKnownProbeTracingEnv switches on per-phase tracing of the well-known
+// probe connections.  when enabled, every probe request is instrumented with
+// httptrace hooks and a span-style record is logged per member covering DNS,
+// connect, TLS handshake and time to first byte, plus one parent record per
+// probe cycle.  this lets SREs correlate slow OAuth readiness with a specific
+// member and connection phase, which the coarse latency signals cannot do.
+// it is off by default because the records are chatty and per-request.
+const wellKnownProbeTracingEnv = "OPERATOR_WELLKNOWN_PROBE_TRACING"
+
+func probeTracingEnabled() bool {
+	enabled, _ := strconv.ParseBool(os.Getenv(wellKnownProbeTracingEnv))
+	return enabled
+}
+
+// probeSpan accumulates the phase timings of one traced unit of work - a
+// probe request against a member, or a whole probe cycle.  the httptrace
+// callbacks fire on the transport's goroutines, hence the lock.
+type probeSpan struct {
+	name  string
+	now   func() time.Time
+	start time.Time
+
+	lock   sync.Mutex
+	starts map[string]time.Time
+	phases []string
+}
+
+func newProbeSpan(name string, now func() time.Time) *probeSpan {
+	return &probeSpan{name: name, now: now, start: now(), starts: map[string]time.Time{}}
+}
+
+func (s *probeSpan) phaseStart(phase string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.starts[phase] = s.now()
+}
+
+// phaseEnd records the phase duration.  a phase without an explicit start -
+// DNS when dialing an IP literal, or first-byte which only has a completion
+// hook - is measured from the start of the span.
+func (s *probeSpan) phaseEnd(phase string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	start, ok := s.starts[phase]
+	if !ok {
+		start = s.start
+	}
+	s.phases = append(s.phases, fmt.Sprintf("%s=%s", phase, s.now().Sub(start)))
+}
+
+// finish logs the completed span.  reused connections report no dns, connect
+// or tls phases - only first-byte - which is itself useful: it shows whether
+// the time went into connection setup or into the server.
+func (s *probeSpan) finish() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	klog.Infof("probe trace: span=%s total=%s %s", s.name, s.now().Sub(s.start), strings.Join(s.phases, " "))
+}
+
+// traceProbeRequest instruments the request with httptrace hooks when tracing
+// is enabled and returns the request to send together with a function that
+// logs the collected span once the request is done.
+func (c *authOperator) traceProbeRequest(req *http.Request, member string) (*http.Request, func()) {
+	if !probeTracingEnabled() {
+		return req, func() {}
+	}
+
+	span := newProbeSpan("wellknown-probe/"+member, c.now)
+	trace := &httptrace.ClientTrace{
+		DNSStart:             func(httptrace.DNSStartInfo) { span.phaseStart("dns") },
+		DNSDone:              func(httptrace.DNSDoneInfo) { span.phaseEnd("dns") },
+		ConnectStart:         func(network, addr string) { span.phaseStart("connect") },
+		ConnectDone:          func(network, addr string, err error) { span.phaseEnd("connect") },
+		TLSHandshakeStart:    func() { span.phaseStart("tls-handshake") },
+		TLSHandshakeDone:     func(tls.ConnectionState, error) { span.phaseEnd("tls-handshake") },
+		GotFirstResponseByte: func() { span.phaseEnd("first-byte") },
+	}
+	return req.WithContext(httptrace.WithClientTrace(req.Context(), trace)), span.finish
+}
@@ -0,0 +1,71 @@
+package operator2
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"k8s.io/klog"
+)
+
+func TestProbeTracing(t *testing.T) {
+	route := testRoute("oauth-openshift.apps.example.com")
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(getMetadata(route)))
+	}))
+	defer server.Close()
+
+	logs := captureKlog(t)
+	c := &authOperator{}
+
+	// tracing off by default - probing leaves no trace records behind
+	if _, _, _, err := c.checkWellknownEndpointReady(server.Listener.Addr().String(), server.Client().Transport, route); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	klog.Flush()
+	if strings.Contains(logs.String(), "probe trace:") {
+		t.Errorf("expected no trace records with tracing disabled, got %q", logs.String())
+	}
+
+	// enabled, the request is traced per member with per-phase timings
+	t.Setenv(wellKnownProbeTracingEnv, "true")
+	if _, _, _, err := c.checkWellknownEndpointReady(server.Listener.Addr().String(), server.Client().Transport, route); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	klog.Flush()
+	out := logs.String()
+	if want := "probe trace: span=wellknown-probe/" + server.Listener.Addr().String(); !strings.Contains(out, want) {
+		t.Errorf("expected a member span %q, got %q", want, out)
+	}
+	for _, phase := range []string{"total=", "first-byte="} {
+		if !strings.Contains(out, phase) {
+			t.Errorf("expected the span to carry %q, got %q", phase, out)
+		}
+	}
+}
+
+func TestProbeSpanPhases(t *testing.T) {
+	logs := captureKlog(t)
+
+	now := time.Date(2019, time.April, 1, 12, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+
+	span := newProbeSpan("test-span", clock)
+	span.phaseStart("connect")
+	now = now.Add(50 * time.Millisecond)
+	span.phaseEnd("connect")
+	// first-byte has no explicit start and is measured from the span start
+	now = now.Add(100 * time.Millisecond)
+	span.phaseEnd("first-byte")
+	span.finish()
+	klog.Flush()
+
+	out := logs.String()
+	for _, want := range []string{"span=test-span", "total=150ms", "connect=50ms", "first-byte=150ms"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected the record to carry %q, got %q", want, out)
+		}
+	}
+}
@@ -1,47 +1,151 @@
 package operator2
 
 import (
+	"crypto/sha256"
 	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
 	"fmt"
+	"net"
+	"strings"
+	"time"
 
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation"
 	"k8s.io/klog"
 
 	configv1 "github.com/openshift/api/config/v1"
+	operatorv1 "github.com/openshift/api/operator/v1"
 	routev1 "github.com/openshift/api/route/v1"
+	"github.com/openshift/library-go/pkg/operator/v1helpers"
 )
 
 func (c *authOperator) handleRoute(ingress *configv1.Ingress) (*routev1.Route, *corev1.Secret, error) {
-	route, err := c.route.Get(targetName, metav1.GetOptions{})
+	cfg := c.routeConfig()
+
+	// an ingress domain with invalid characters yields a host the router can
+	// never admit - fail early with a clear error instead of an opaque
+	// admission failure
+	expectedHost := cfg.host(ingress)
+	if errs := validation.IsDNS1123Subdomain(expectedHost); len(errs) > 0 {
+		return nil, nil, fmt.Errorf("invalid route host %q derived from ingress domain %q: %s", expectedHost, ingress.Spec.Domain, strings.Join(errs, ", "))
+	}
+
+	route, err := c.route.Get(cfg.name, metav1.GetOptions{})
 	if errors.IsNotFound(err) {
-		route, err = c.route.Create(defaultRoute(ingress))
+		// c.route is scoped to our namespace so a same-named route that ended
+		// up elsewhere (i.e. moved by an admin) is invisible here yet may
+		// shadow behavior - surface it clearly before we create a new one
+		c.reportShadowRoutes()
+		if c.mutationAllowed("creating the missing oauth route") {
+			route, err = c.route.Create(cfg.defaultRoute(ingress))
+			if err == nil {
+				c.noteRouteCorrection("created")
+			}
+		}
 	}
 	if err != nil {
 		return nil, nil, err
 	}
 
-	host := getCanonicalHost(route, ingress)
+	// defensive guard - c.route is namespace scoped so this should be impossible
+	if route.Namespace != cfg.namespace {
+		return nil, nil, fmt.Errorf("route %s is in unexpected namespace %s, expected %s", route.Name, route.Namespace, cfg.namespace)
+	}
+
+	// a deleted route that some controller's finalizer keeps alive makes
+	// every recreate attempt fail with AlreadyExists against an object that
+	// is already going away - name the stuck state and the finalizers holding
+	// it instead of looping on create errors
+	if route.DeletionTimestamp != nil {
+		return nil, nil, &routeStuckTerminatingError{message: routeStuckTerminatingMessage(route, c.now())}
+	}
+
+	// an admin stripping the TLS block from the oauth route is a real,
+	// login-breaking mistake - patch the expected passthrough config back in
+	// place instead of recreating the route so we heal quickly and visibly
+	if route.Spec.TLS == nil && c.mutationAllowed("restoring the TLS configuration on the route") {
+		route = route.DeepCopy()
+		route.Spec.TLS = cfg.defaultRoute(ingress).Spec.TLS
+		route, err = c.route.Update(route)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to restore TLS configuration on route: %v", err)
+		}
+		c.eventRecorder().Eventf("OAuthRouteTLSRestored", "restored the missing TLS configuration on route %s/%s", route.Namespace, route.Name)
+		c.noteRouteCorrection("tls-restored")
+	}
+
+	// a manually changed Spec.Host makes the router admit the route at a name
+	// logins never use - the status-based canonical host handling below hides
+	// that from the rest of the sync but leaves the wrong name live at the
+	// router, so put the expected host back (only for explicit-host routes;
+	// with a subdomain the host is the router's to fill in)
+	if len(route.Spec.Subdomain) == 0 && route.Spec.Host != expectedHost && c.mutationAllowed("restoring the canonical host on the route") {
+		route = route.DeepCopy()
+		route.Spec.Host = expectedHost
+		route, err = c.route.Update(route)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to restore the canonical host on route: %v", err)
+		}
+		c.eventRecorder().Eventf("OAuthRouteHostRestored", "restored the canonical host %s on route %s/%s", expectedHost, route.Namespace, route.Name)
+		c.noteRouteCorrection("host-restored")
+	}
+
+	// the operator's labels on the route feed selectors other tooling relies
+	// on, so an admin stripping them breaks more than cosmetics - re-apply the
+	// expected labels and annotations in place, leaving anything other actors
+	// added alone, instead of recreating the route
+	if stripped := strippedRouteMetaEntries(route, cfg.defaultRoute(ingress).ObjectMeta); len(stripped) > 0 && c.mutationAllowed("restoring the stripped metadata on the route") {
+		route = route.DeepCopy()
+		restoreRouteMetaEntries(route, cfg.defaultRoute(ingress).ObjectMeta)
+		route, err = c.route.Update(route)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to restore metadata on route: %v", err)
+		}
+		c.eventRecorder().Eventf("OAuthRouteMetadataRestored", "restored %s on route %s/%s", strings.Join(stripped, ", "), route.Namespace, route.Name)
+		c.noteRouteCorrection("metadata-restored")
+	}
+
+	host := getCanonicalHost(route, expectedHost)
+	c.noteRouteAdmission(host)
 	if len(host) == 0 {
-		// be careful not to print route.spec as it many contain secrets
-		return nil, nil, fmt.Errorf("route is not available at canonical host %s: %+v", ingressToHost(ingress), route.Status.Ingress)
+		// an empty status right after creation is the normal admission delay,
+		// not a failure - report it as progressing instead of spamming the
+		// logs with errors until the router catches up
+		if len(route.Status.Ingress) == 0 {
+			return nil, nil, &routeNotAdmittedError{message: fmt.Sprintf("route created, awaiting admission by the router at host %s", expectedHost)}
+		}
+		// a route still admitted at a host under another domain means an
+		// ingress domain change is propagating - deleting and recreating the
+		// route here would only add churn, so report the transition instead
+		if old := admittedHostsOtherThan(route, expectedHost); len(old) > 0 {
+			return nil, nil, &domainMigrationError{message: fmt.Sprintf("ingress domain change in progress: route is still admitted at %s while awaiting admission at %s", strings.Join(old, ", "), expectedHost)}
+		}
+		return nil, nil, fmt.Errorf("route is not available at canonical host %s: %s", expectedHost, sanitizeRouteForLog(route))
 	}
 
 	// assume it is unsafe to mutate route in case we go to a shared informer in the future
 	// this way everything else can just assume route.Spec.Host is correct
-	// note that we are not updating route.Spec.Host in the API - that value is nonsense to us
+	// (the spec host was already reconciled above - this pins the canonical
+	// host derived from the admitted status for the rest of the sync)
 	route = route.DeepCopy()
 	route.Spec.Host = host
 
-	if err := isValidRoute(route, ingress); err != nil {
-		// TODO remove this delete so that we do not lose the early creation timestamp of our route
-		// delete the route so that it is replaced with the proper one in next reconcile loop
-		klog.Infof("deleting invalid route: %#v", route)
-		opts := &metav1.DeleteOptions{Preconditions: &metav1.Preconditions{UID: &route.UID}}
-		if err := c.route.Delete(route.Name, opts); err != nil && !errors.IsNotFound(err) {
-			klog.Infof("failed to delete invalid route: %v", err)
+	if err := cfg.isValidRoute(route, ingress); err != nil {
+		if c.mutationAllowed("deleting the invalid oauth route") {
+			// TODO remove this delete so that we do not lose the early creation timestamp of our route
+			// delete the route so that it is replaced with the proper one in next reconcile loop
+			// log only the route identity - the spec may carry TLS key material
+			klog.Infof("deleting invalid route %s/%s", route.Namespace, route.Name)
+			opts := &metav1.DeleteOptions{Preconditions: &metav1.Preconditions{UID: &route.UID}}
+			if err := c.route.Delete(route.Name, opts); err != nil && !errors.IsNotFound(err) {
+				klog.Infof("failed to delete invalid route: %v", err)
+			}
+			c.noteRouteCorrection("deleted-invalid")
 		}
 		return nil, nil, err
 	}
@@ -58,52 +162,357 @@ func (c *authOperator) handleRoute(ingress *configv1.Ingress) (*routev1.Route, *
 	return route, routerSecret, nil
 }
 
-func isValidRoute(route *routev1.Route, ingress *configv1.Ingress) error {
+// strippedRouteMetaEntries lists the expected labels and annotations the route
+// no longer carries (or carries with a different value), rendered for the
+// restore event, sorted.
+func strippedRouteMetaEntries(route *routev1.Route, expected metav1.ObjectMeta) []string {
+	var stripped []string
+	for _, key := range sets.StringKeySet(expected.Labels).List() {
+		if route.Labels[key] != expected.Labels[key] {
+			stripped = append(stripped, fmt.Sprintf("label %s", key))
+		}
+	}
+	for _, key := range sets.StringKeySet(expected.Annotations).List() {
+		if route.Annotations[key] != expected.Annotations[key] {
+			stripped = append(stripped, fmt.Sprintf("annotation %s", key))
+		}
+	}
+	return stripped
+}
+
+// restoreRouteMetaEntries re-applies the expected labels and annotations onto
+// the route.  entries the operator does not own are left untouched.
+func restoreRouteMetaEntries(route *routev1.Route, expected metav1.ObjectMeta) {
+	if len(expected.Labels) != 0 && route.Labels == nil {
+		route.Labels = map[string]string{}
+	}
+	for key, value := range expected.Labels {
+		route.Labels[key] = value
+	}
+	if len(expected.Annotations) != 0 && route.Annotations == nil {
+		route.Annotations = map[string]string{}
+	}
+	for key, value := range expected.Annotations {
+		route.Annotations[key] = value
+	}
+}
+
+// noteRouteAdmission gives the event stream a clear "the OAuth login path is
+// live at X" breadcrumb by emitting a Normal event when the route becomes
+// admitted at a host.  admission is level-triggered on every sync, so the
+// last seen host is remembered and the event fires once per transition rather
+// than once per reconcile - a route that loses admission and comes back, or
+// moves to a new host, announces itself again.  only called from the sync
+// loop, so the field needs no locking.
+func (c *authOperator) noteRouteAdmission(host string) {
+	if host == c.lastAdmittedHost {
+		return
+	}
+	c.lastAdmittedHost = host
+	if len(host) == 0 {
+		return
+	}
+	c.eventRecorder().Eventf("OAuthRouteAdmitted", "OAuth route %s/%s admitted, serving at host %s", targetNamespace, targetName, host)
+}
+
+// routeNotAdmittedError marks the expected window between route creation and
+// router admission - handleSync reports it as progressing, not degraded.
+type routeNotAdmittedError struct {
+	message string
+}
+
+func (e *routeNotAdmittedError) Error() string {
+	return e.message
+}
+
+// domainMigrationError marks the transition window after the cluster ingress
+// domain changed: the router still admits the route at a host under the old
+// domain while the new host is not admitted yet.  handleSync reports it as
+// progressing so the change can finish without delete/recreate churn.
+type domainMigrationError struct {
+	message string
+}
+
+func (e *domainMigrationError) Error() string {
+	return e.message
+}
+
+// routeStuckTerminatingError marks a route whose deletion has been requested
+// but whose finalizers keep it around - creating a replacement can only fail
+// with AlreadyExists until they are removed, so handleSync reports the stuck
+// state instead of looping on create errors.
+type routeStuckTerminatingError struct {
+	message string
+}
+
+func (e *routeStuckTerminatingError) Error() string {
+	return e.message
+}
+
+// routeStuckTerminatingMessage names what is holding the terminating route,
+// since removing those finalizers (or chasing their owners) is the only way
+// out of the stuck state.
+func routeStuckTerminatingMessage(route *routev1.Route, now time.Time) string {
+	holders := "no finalizers - deletion should complete shortly"
+	if len(route.Finalizers) != 0 {
+		holders = fmt.Sprintf("blocked by finalizers: %s", strings.Join(route.Finalizers, ", "))
+	}
+	terminating := now.Sub(route.DeletionTimestamp.Time).Round(time.Second)
+	return fmt.Sprintf("route %s/%s has been terminating for %s (%s) - a replacement cannot be created until it is gone", route.Namespace, route.Name, terminating, holders)
+}
+
+// admittedHostsOtherThan lists the hosts the router has admitted that differ
+// from the expected canonical host, sorted.
+func admittedHostsOtherThan(route *routev1.Route, expectedHost string) []string {
+	hosts := sets.NewString()
+	for _, ingress := range route.Status.Ingress {
+		if !hostsEqual(ingress.Host, expectedHost) && isIngressAdmitted(ingress) {
+			hosts.Insert(ingress.Host)
+		}
+	}
+	return hosts.List()
+}
+
+// sanitizeRouteForLog renders the route for logs and error messages with
+// everything that could carry secrets removed: the certificate and key
+// material in Spec.TLS is replaced with redaction markers and annotation
+// values are dropped (controllers and admins stash arbitrary data there).
+// any route that ends up in a log line or an error must go through here.
+func sanitizeRouteForLog(route *routev1.Route) string {
+	if route == nil {
+		return "<nil>"
+	}
+	sanitized := route.DeepCopy()
+	if tls := sanitized.Spec.TLS; tls != nil {
+		for _, field := range []*string{&tls.Certificate, &tls.Key, &tls.CACertificate, &tls.DestinationCACertificate} {
+			if len(*field) != 0 {
+				*field = "<redacted>"
+			}
+		}
+	}
+	for key := range sanitized.Annotations {
+		sanitized.Annotations[key] = "<redacted>"
+	}
+	return fmt.Sprintf("%#v", sanitized)
+}
+
+const routeHashMessagePrefix = "route spec hash: "
+
+// routeSpecHash hashes the admin-relevant fields of the route spec.  the host
+// is excluded because we reconcile it from the ingress config ourselves.
+func routeSpecHash(spec routev1.RouteSpec) string {
+	hashedSpec := spec.DeepCopy()
+	hashedSpec.Host = ""
+	data, err := json.Marshal(hashedSpec)
+	if err != nil {
+		// RouteSpec contains nothing that can fail to marshal
+		panic(err)
+	}
+	return fmt.Sprintf("%x", sha256.Sum256(data))
+}
+
+// handleRouteDrift records the current route spec hash in the RouteSpecDrift
+// condition.  when the hash differs from the one recorded on the previous sync
+// the condition reports drift, giving external tooling a machine-readable
+// signal that some other actor keeps mutating the route.
+func (c *authOperator) handleRouteDrift(operatorConfig *operatorv1.Authentication, route *routev1.Route) {
+	currentHash := routeSpecHash(route.Spec)
+
+	lastHash := ""
+	if previous := v1helpers.FindOperatorCondition(operatorConfig.Status.Conditions, "RouteSpecDrift"); previous != nil {
+		lastHash = strings.TrimPrefix(previous.Message, routeHashMessagePrefix)
+	}
+
+	if len(lastHash) != 0 && lastHash != currentHash {
+		v1helpers.SetOperatorCondition(&operatorConfig.Status.Conditions, operatorv1.OperatorCondition{
+			Type:    "RouteSpecDrift",
+			Status:  operatorv1.ConditionTrue,
+			Reason:  "RouteChanged",
+			Message: routeHashMessagePrefix + currentHash,
+		})
+		return
+	}
+
+	v1helpers.SetOperatorCondition(&operatorConfig.Status.Conditions, operatorv1.OperatorCondition{
+		Type:    "RouteSpecDrift",
+		Status:  operatorv1.ConditionFalse,
+		Reason:  "AsExpected",
+		Message: routeHashMessagePrefix + currentHash,
+	})
+}
+
+// handleIssuerDNS optionally checks that the issuer host actually resolves
+// via the in-cluster resolver - a misconfigured DNS zone for the ingress
+// domain breaks logins long before any metadata comparison would notice.  the
+// check is opt-in because split-horizon DNS setups legitimately fail it.
+func (c *authOperator) handleIssuerDNS(operatorConfig *operatorv1.Authentication, route *routev1.Route) {
+	if !c.checkIssuerDNS {
+		return
+	}
+
+	resolve := c.resolveHost
+	if resolve == nil {
+		resolve = net.LookupHost
+	}
+
+	if _, err := resolve(route.Spec.Host); err != nil {
+		v1helpers.SetOperatorCondition(&operatorConfig.Status.Conditions, operatorv1.OperatorCondition{
+			Type:    "IssuerDNSResolution",
+			Status:  operatorv1.ConditionTrue,
+			Reason:  "DNSResolutionFailed",
+			Message: fmt.Sprintf("the issuer host %s does not resolve via cluster DNS: %v", route.Spec.Host, err),
+		})
+		return
+	}
+	v1helpers.SetOperatorCondition(&operatorConfig.Status.Conditions, operatorv1.OperatorCondition{
+		Type:   "IssuerDNSResolution",
+		Status: operatorv1.ConditionFalse,
+		Reason: "AsExpected",
+	})
+}
+
+// reportShadowRoutes logs any route that has our name but lives in a different
+// namespace.  such a route is never managed by us but can confuse admins (and
+// routers) into thinking the oauth route exists when ours is missing.
+func (c *authOperator) reportShadowRoutes() {
+	if c.routeGetter == nil {
+		return
+	}
+	allRoutes, err := c.routeGetter.Routes(metav1.NamespaceAll).List(metav1.ListOptions{FieldSelector: "metadata.name=" + targetName})
+	if err != nil {
+		klog.V(2).Infof("failed to list routes named %s across namespaces: %v", targetName, err)
+		return
+	}
+	for _, other := range allRoutes.Items {
+		if other.Namespace != targetNamespace {
+			klog.Warningf("found route %s in unexpected namespace %s - it is not managed by this operator and may shadow the expected route in %s", other.Name, other.Namespace, targetNamespace)
+		}
+	}
+}
+
+// routeConfig carries the identity and port values the route handling bakes
+// into the expected route.  downstream rebuilds occasionally change the
+// container port or resource names - they override these instead of patching
+// constants throughout the package.  validation and creation share the same
+// values by construction so they cannot disagree.
+type routeConfig struct {
+	// name is the route, service and host-prefix name, see targetName
+	name string
+	// namespace the route must live in, see targetNamespace
+	namespace string
+	// containerPort is the port the OAuth server pods listen on
+	containerPort int
+	// publicPort is the port logins reach the route at behind the router -
+	// the issuer URL only names it when it is not the implicit https port.
+	// zero means servicePort, so partial overrides keep the default.
+	publicPort int
+}
+
+func defaultRouteConfig() routeConfig {
+	return routeConfig{
+		name:          targetName,
+		namespace:     targetNamespace,
+		containerPort: containerPort,
+		publicPort:    servicePort,
+	}
+}
+
+// routeConfig returns the effective route configuration.
+func (c *authOperator) routeConfig() routeConfig {
+	if c.routeCfg == (routeConfig{}) {
+		return defaultRouteConfig()
+	}
+	return c.routeCfg
+}
+
+// host derives the canonical route host for the configured name.
+func (cfg routeConfig) host(ingress *configv1.Ingress) string {
+	return cfg.name + "." + ingress.Spec.Domain
+}
+
+// validateTLSCombination checks that termination and insecure policy are
+// internally consistent per route semantics, independent of what we expect the
+// values to be.  the router rejects Allow with passthrough because there is no
+// decrypted traffic to serve insecurely - catching it here yields a clear
+// error instead of two per-field mismatches.
+func validateTLSCombination(tls *routev1.TLSConfig) error {
+	if tls == nil {
+		return nil
+	}
+	if tls.Termination == routev1.TLSTerminationPassthrough && tls.InsecureEdgeTerminationPolicy == routev1.InsecureEdgeTerminationPolicyAllow {
+		return fmt.Errorf("insecure termination policy '%s' is not valid with '%s' termination", tls.InsecureEdgeTerminationPolicy, tls.Termination)
+	}
+	return nil
+}
+
+func (cfg routeConfig) isValidRoute(route *routev1.Route, ingress *configv1.Ingress) error {
 	// TODO: return all errors at once
 	// TODO error when fields that should be empty are set
 
+	if err := validateTLSCombination(route.Spec.TLS); err != nil {
+		return err
+	}
+
 	// get the expected settings from the default route
-	expectedRoute := defaultRoute(ingress)
+	expectedRoute := cfg.defaultRoute(ingress)
 	expName := expectedRoute.Spec.To.Name
 	expPort := expectedRoute.Spec.Port.TargetPort.IntValue()
 	expTLSTermination := expectedRoute.Spec.TLS.Termination
 	expInsecureEdgeTerminationPolicy := expectedRoute.Spec.TLS.InsecureEdgeTerminationPolicy
 
 	if route.Spec.To.Name != expName {
-		return fmt.Errorf("route targets a wrong service - needs %s: %#v", expName, route)
+		return fmt.Errorf("route targets a wrong service - needs %s: %s", expName, sanitizeRouteForLog(route))
 	}
 
 	if route.Spec.Port.TargetPort.IntValue() != expPort {
-		return fmt.Errorf("expected port '%d' for route: %#v", expPort, route)
+		return fmt.Errorf("expected port '%d' for route: %s", expPort, sanitizeRouteForLog(route))
 	}
 
 	if route.Spec.TLS == nil {
-		return fmt.Errorf("TLS needs to be configured for route: %#v", route)
+		return fmt.Errorf("TLS needs to be configured for route: %s", sanitizeRouteForLog(route))
 	}
 
 	if route.Spec.TLS.Termination != expTLSTermination {
-		return fmt.Errorf("route contains wrong TLS termination - '%s' is required: %#v", expTLSTermination, route)
+		return fmt.Errorf("route contains wrong TLS termination - '%s' is required: %s", expTLSTermination, sanitizeRouteForLog(route))
 	}
 
 	if route.Spec.TLS.InsecureEdgeTerminationPolicy != expInsecureEdgeTerminationPolicy {
-		return fmt.Errorf("route contains wrong insecure termination policy - '%s' is required: %#v", expInsecureEdgeTerminationPolicy, route)
+		return fmt.Errorf("route contains wrong insecure termination policy - '%s' is required: %s", expInsecureEdgeTerminationPolicy, sanitizeRouteForLog(route))
+	}
+
+	// inline TLS material is useless with passthrough termination - TLS is
+	// terminated at the oauth server - and a private key pasted into a route
+	// object is a secret exposure waiting to happen.  name the offending
+	// fields only, never their values.
+	var inlineErrs []error
+	if len(route.Spec.TLS.Certificate) != 0 {
+		inlineErrs = append(inlineErrs, fmt.Errorf("route must not carry an inline certificate with '%s' termination", expTLSTermination))
+	}
+	if len(route.Spec.TLS.Key) != 0 {
+		inlineErrs = append(inlineErrs, fmt.Errorf("route must not carry an inline private key with '%s' termination", expTLSTermination))
+	}
+	if len(inlineErrs) > 0 {
+		return v1helpers.NewMultiLineAggregate(inlineErrs)
 	}
 
 	return nil
 }
 
-func defaultRoute(ingress *configv1.Ingress) *routev1.Route {
+func (cfg routeConfig) defaultRoute(ingress *configv1.Ingress) *routev1.Route {
+	meta := defaultMeta()
+	meta.Name = cfg.name
+	meta.Namespace = cfg.namespace
 	return &routev1.Route{
-		ObjectMeta: defaultMeta(),
+		ObjectMeta: meta,
 		Spec: routev1.RouteSpec{
-			Host:      ingressToHost(ingress), // mimic the behavior of subdomain
-			Subdomain: "",                     // TODO once subdomain is functional, remove reliance on ingress config and just set subdomain=targetName
+			Host:      cfg.host(ingress), // mimic the behavior of subdomain
+			Subdomain: "",                // TODO once subdomain is functional, remove reliance on ingress config and just set subdomain=targetName
 			To: routev1.RouteTargetReference{
 				Kind: "Service",
-				Name: targetName,
+				Name: cfg.name,
 			},
 			Port: &routev1.RoutePort{
-				TargetPort: intstr.FromInt(containerPort),
+				TargetPort: intstr.FromInt(cfg.containerPort),
 			},
 			TLS: &routev1.TLSConfig{
 				Termination:                   routev1.TLSTerminationPassthrough,
@@ -113,9 +522,89 @@ func defaultRoute(ingress *configv1.Ingress) *routev1.Route {
 	}
 }
 
-func routerSecretToSNI(routerSecret *corev1.Secret) []configv1.NamedCertificate {
-	var out []configv1.NamedCertificate
+// routerSecretStrayKeys returns the router secret data keys that do not match
+// any known ingress domain, sorted.  such keys would generate SNI entries for
+// domains the cluster does not serve, potentially shadowing the correct entry.
+func routerSecretStrayKeys(routerSecret *corev1.Secret, ingressConfig *configv1.Ingress) []string {
+	stray := sets.NewString()
 	for domain := range routerSecret.Data {
+		if domain != ingressConfig.Spec.Domain {
+			stray.Insert(domain)
+		}
+	}
+	return stray.List()
+}
+
+// handleRouterCertsDomains reports divergence between the router secret's keys
+// and the cluster's ingress domains via a condition so a stale or mis-synced
+// secret is visible without digging through logs.
+func (c *authOperator) handleRouterCertsDomains(operatorConfig *operatorv1.Authentication, routerSecret *corev1.Secret, ingressConfig *configv1.Ingress) {
+	if stray := routerSecretStrayKeys(routerSecret, ingressConfig); len(stray) > 0 {
+		v1helpers.SetOperatorCondition(&operatorConfig.Status.Conditions, operatorv1.OperatorCondition{
+			Type:    "RouterCertsDomainMismatch",
+			Status:  operatorv1.ConditionTrue,
+			Reason:  "StrayDomains",
+			Message: fmt.Sprintf("router secret %s/%s contains keys that match no ingress domain: %s", routerSecret.Namespace, routerSecret.Name, strings.Join(stray, ", ")),
+		})
+		return
+	}
+	v1helpers.SetOperatorCondition(&operatorConfig.Status.Conditions, operatorv1.OperatorCondition{
+		Type:   "RouterCertsDomainMismatch",
+		Status: operatorv1.ConditionFalse,
+		Reason: "AsExpected",
+	})
+}
+
+// sniDomainsForHost returns the router secret keys whose implied wildcard
+// entry covers the given host, i.e. the keys that are a domain suffix of it,
+// sorted.  a secret with overlapping domains can yield several.
+func sniDomainsForHost(routerSecret *corev1.Secret, host string) []string {
+	domains := sets.NewString()
+	for domain := range routerSecret.Data {
+		if strings.HasSuffix(host, "."+domain) {
+			domains.Insert(domain)
+		}
+	}
+	return domains.List()
+}
+
+// mostSpecificSNIDomain picks the domain that must win for a host covered by
+// several overlapping entries: the longest one.  two distinct suffixes of the
+// same host cannot share a length, but ties are still broken lexicographically
+// so the choice stays deterministic on any input.
+func mostSpecificSNIDomain(domains []string) string {
+	best := ""
+	for _, domain := range domains {
+		if len(domain) > len(best) || (len(domain) == len(best) && domain < best) {
+			best = domain
+		}
+	}
+	return best
+}
+
+func (c *authOperator) routerSecretToSNI(routerSecret *corev1.Secret, ingressConfig *configv1.Ingress) []configv1.NamedCertificate {
+	// overlapping secret domains (e.g. example.com next to apps.example.com)
+	// would generate several wildcard entries that all cover the route host,
+	// leaving the served certificate to chance - always emit exactly the most
+	// specific covering entry.  the host comes from the effective route config
+	// so certificate selection cannot drift from the reconciled route.
+	host := c.routeConfig().host(ingressConfig)
+	matching := sniDomainsForHost(routerSecret, host)
+	chosen := mostSpecificSNIDomain(matching)
+	if len(matching) > 1 {
+		klog.Warningf("router secret keys %s all cover the route host %s - using the most specific %q", strings.Join(matching, ", "), host, chosen)
+	}
+
+	var out []configv1.NamedCertificate
+	for _, domain := range sets.StringKeySet(routerSecret.Data).List() {
+		if domain != chosen {
+			// a key that covers no route host is not a serving domain - do
+			// not generate an SNI entry that could shadow the correct one
+			if !strings.HasSuffix(host, "."+domain) {
+				klog.Warningf("skipping router secret key %q that matches no ingress domain", domain)
+			}
+			continue
+		}
 		out = append(out, configv1.NamedCertificate{
 			Names: []string{"*." + domain}, // ingress domain is always a wildcard
 			CertInfo: configv1.CertInfo{ // the cert and key are appended together
@@ -127,35 +616,258 @@ func routerSecretToSNI(routerSecret *corev1.Secret) []configv1.NamedCertificate
 	return out
 }
 
+// routeCertsExpiryWarningWindow is how close to its NotAfter the serving
+// certificate for the ingress domain may get before the route health
+// conditions start warning about it.
+const routeCertsExpiryWarningWindow = 30 * 24 * time.Hour
+
+// routeFightThreshold is how many route corrections since operator start are
+// tolerated before the health conditions call it a reconciliation fight.  a
+// few corrections are an admin poking at the route once; reaching the
+// threshold means something keeps undoing the operator's writes.
+const routeFightThreshold = 5
+
+// routeHealthConditions validates the route, the router secret and the ingress
+// config as one unit and renders the result as operator conditions, giving the
+// route subsystem the same first-class condition reporting the well-known
+// readiness checks have instead of opaque errors to the caller.  the returned
+// conditions always cover the full set so a recovered problem clears itself.
+func (c *authOperator) routeHealthConditions(route *routev1.Route, routerSecret *corev1.Secret, ingress *configv1.Ingress) []operatorv1.OperatorCondition {
+	cfg := c.routeConfig()
+
+	valid := operatorv1.OperatorCondition{Type: "RouteConfig" + operatorv1.OperatorStatusTypeDegraded, Status: operatorv1.ConditionFalse, Reason: "AsExpected"}
+	if err := cfg.isValidRoute(route, ingress); err != nil {
+		valid.Status = operatorv1.ConditionTrue
+		valid.Reason = "InvalidRoute"
+		valid.Message = err.Error()
+	}
+
+	notAdmitted := operatorv1.OperatorCondition{Type: "RouteNotAdmitted", Status: operatorv1.ConditionFalse, Reason: "AsExpected"}
+	if expectedHost := cfg.host(ingress); len(getCanonicalHost(route, expectedHost)) == 0 {
+		notAdmitted.Status = operatorv1.ConditionTrue
+		notAdmitted.Reason = "NotAdmitted"
+		notAdmitted.Message = fmt.Sprintf("route is not admitted by the router at canonical host %s", expectedHost)
+	}
+
+	// each heal in handleRoute is correct in isolation, but a stream of them
+	// means the operator and another actor are fighting over the route - a
+	// loop that otherwise only shows as repeated log lines
+	fight := operatorv1.OperatorCondition{Type: "RouteReconcileFight", Status: operatorv1.ConditionFalse, Reason: "AsExpected"}
+	if c.routeCorrections >= routeFightThreshold {
+		fight.Status = operatorv1.ConditionTrue
+		fight.Reason = "TooManyCorrections"
+		fight.Message = fmt.Sprintf("the route was corrected or recreated %d times since operator start - the operator and another actor appear to be fighting over the route", c.routeCorrections)
+	}
+
+	// admission is not the whole story - the router sets further conditions on
+	// an admitted ingress (a rejected custom certificate, a route unservable
+	// in future versions) that would otherwise pass as fully healthy
+	warning := operatorv1.OperatorCondition{Type: "RouteIngressWarning", Status: operatorv1.ConditionFalse, Reason: "AsExpected"}
+	if warnings := admittedIngressWarnings(route, cfg.host(ingress)); len(warnings) != 0 {
+		warning.Status = operatorv1.ConditionTrue
+		warning.Reason = "IngressWarning"
+		warning.Message = fmt.Sprintf("the router admitted the route at %s but reports: %s", cfg.host(ingress), strings.Join(warnings, "; "))
+	}
+
+	// be careful not to print the secret data in any message below
+	secret := operatorv1.OperatorCondition{Type: "RouterSecret" + operatorv1.OperatorStatusTypeDegraded, Status: operatorv1.ConditionFalse, Reason: "AsExpected"}
+	switch {
+	case routerSecret == nil || len(routerSecret.Data) == 0:
+		secret.Status = operatorv1.ConditionTrue
+		secret.Reason = "SecretMissing"
+		secret.Message = fmt.Sprintf("router secret %s/%s is missing or empty", targetNamespace, routerCertsLocalName)
+	case len(routerSecret.Data[ingress.Spec.Domain]) == 0:
+		secret.Status = operatorv1.ConditionTrue
+		secret.Reason = "DomainMissing"
+		secret.Message = fmt.Sprintf("router secret %s/%s has no entry for ingress domain %s", routerSecret.Namespace, routerSecret.Name, ingress.Spec.Domain)
+	}
+
+	expiring := operatorv1.OperatorCondition{Type: "RouteCertsExpiring", Status: operatorv1.ConditionFalse, Reason: "AsExpected"}
+	if routerSecret != nil {
+		if notAfter, ok := earliestCertExpiry(routerSecret.Data[ingress.Spec.Domain]); ok {
+			if remaining := notAfter.Sub(c.now()); remaining < routeCertsExpiryWarningWindow {
+				expiring.Status = operatorv1.ConditionTrue
+				expiring.Reason = "CertificateExpiring"
+				if remaining < 0 {
+					expiring.Message = fmt.Sprintf("the serving certificate for ingress domain %s expired %s ago", ingress.Spec.Domain, (-remaining).Round(time.Minute))
+				} else {
+					expiring.Message = fmt.Sprintf("the serving certificate for ingress domain %s expires in %s", ingress.Spec.Domain, remaining.Round(time.Minute))
+				}
+			}
+		}
+	}
+
+	return []operatorv1.OperatorCondition{valid, notAdmitted, fight, warning, secret, expiring}
+}
+
+// oauthBackendCondition reports whether the oauth server pods behind the
+// route have ready endpoints.  the well-known checks validate metadata served
+// by the KAS members, not the oauth server itself, so "route up, backend
+// down" passes them - the router keeps forwarding logins to a service with
+// nothing behind it.  the condition only fires while the route is admitted;
+// before admission the route conditions already tell the story.
+func (c *authOperator) oauthBackendCondition(route *routev1.Route, ingress *configv1.Ingress) operatorv1.OperatorCondition {
+	condition := operatorv1.OperatorCondition{
+		Type:   "OAuthBackendNotReady",
+		Status: operatorv1.ConditionFalse,
+		Reason: "AsExpected",
+	}
+
+	host := getCanonicalHost(route, c.routeConfig().host(ingress))
+	if len(host) == 0 {
+		return condition
+	}
+
+	// a missing target service is a different failure than a present service
+	// with no ready pods - the router admits the route either way and serves
+	// 503s, so name the absent service explicitly
+	if _, err := c.services.Services(targetNamespace).Get(route.Spec.To.Name, metav1.GetOptions{}); err != nil {
+		condition.Status = operatorv1.ConditionTrue
+		if errors.IsNotFound(err) {
+			condition.Reason = "ServiceMissing"
+			condition.Message = fmt.Sprintf("route is admitted at %s but its target service %s/%s does not exist", host, targetNamespace, route.Spec.To.Name)
+		} else {
+			condition.Reason = "ServiceLookupFailed"
+			condition.Message = fmt.Sprintf("failed to get the route's target service %s/%s: %v", targetNamespace, route.Spec.To.Name, err)
+		}
+		return condition
+	}
+
+	// the endpoints object shares the name of the service the route actually
+	// targets - looking anywhere else would judge the wrong backend when the
+	// target drifted
+	endpoints, err := c.endpoints.Endpoints(targetNamespace).Get(route.Spec.To.Name, metav1.GetOptions{})
+	if err != nil {
+		condition.Status = operatorv1.ConditionTrue
+		condition.Reason = "EndpointsLookupFailed"
+		condition.Message = fmt.Sprintf("failed to get endpoints %s/%s: %v", targetNamespace, route.Spec.To.Name, err)
+		return condition
+	}
+
+	ready := 0
+	for _, subset := range endpoints.Subsets {
+		ready += len(subset.Addresses)
+	}
+	if ready == 0 {
+		condition.Status = operatorv1.ConditionTrue
+		condition.Reason = "NoReadyBackend"
+		condition.Message = fmt.Sprintf("route is admitted at %s but the oauth server service %s/%s has no ready endpoints", host, targetNamespace, route.Spec.To.Name)
+	}
+	return condition
+}
+
+// latestCertExpiry returns the latest NotAfter among the certificates in the
+// PEM data - the moment the last usable certificate in a bundle runs out.
+func latestCertExpiry(pemData []byte) (time.Time, bool) {
+	var latest time.Time
+	found := false
+	for len(pemData) > 0 {
+		var block *pem.Block
+		block, pemData = pem.Decode(pemData)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			continue
+		}
+		if !found || cert.NotAfter.After(latest) {
+			latest = cert.NotAfter
+			found = true
+		}
+	}
+	return latest, found
+}
+
+// earliestCertExpiry returns the earliest NotAfter among the certificates in
+// the PEM data, skipping non-certificate blocks (i.e. the appended key).
+func earliestCertExpiry(pemData []byte) (time.Time, bool) {
+	var earliest time.Time
+	found := false
+	for len(pemData) > 0 {
+		var block *pem.Block
+		block, pemData = pem.Decode(pemData)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			continue
+		}
+		if !found || cert.NotAfter.Before(earliest) {
+			earliest = cert.NotAfter
+			found = true
+		}
+	}
+	return earliest, found
+}
+
 func routerSecretToCA(route *routev1.Route, routerSecret *corev1.Secret, ingress *configv1.Ingress) []byte {
-	var caData []byte
+	var pemData []byte
 
 	// find the domain that matches our route
 	if certs, ok := routerSecret.Data[ingress.Spec.Domain]; ok {
-		caData = certs
-	}
-
-	// if we have no CA, use system roots (or more correctly, if we have no CERTIFICATE block)
-	// TODO so this branch is effectively never taken, because the value of caData
-	// is the concatenation of tls.crt and tls.key - the .crt data gets parsed
-	// as a valid cert by AppendCertsFromPEM meaning ok is always true.
-	// because Go is weird with how it validates TLS connections, having the actual
-	// peer cert loaded in the transport is totally fine with the connection even
-	// without having the CA loaded.  this is weird but it lets us tolerate scenarios
-	// where we do not have the CA (i.e. admin is using a cert from an internal company CA).
-	// thus the only way we take this branch is if len(caData) == 0
-	if ok := x509.NewCertPool().AppendCertsFromPEM(caData); !ok {
-		klog.Infof("using global CAs for %s, ingress domain=%s, cert data len=%d", route.Spec.Host, ingress.Spec.Domain, len(caData))
+		pemData = certs
+	}
+
+	// the secret concatenates the serving cert, any intermediates, sometimes
+	// a root, and the private key - extract the actual verification bundle
+	// instead of feeding the whole blob to a cert pool
+	caData := caBundleFromPEM(pemData)
+	if len(caData) == 0 {
+		// no certificate blocks at all - use system roots
+		klog.Infof("using global CAs for %s, ingress domain=%s, cert data len=%d", route.Spec.Host, ingress.Spec.Domain, len(pemData))
 		return nil
 	}
 
 	return caData
 }
 
-func getCanonicalHost(route *routev1.Route, ingressConfig *configv1.Ingress) string {
-	host := ingressToHost(ingressConfig)
+// caBundleFromPEM extracts a verification bundle from the router secret's PEM
+// data.  iterating the blocks separates the pieces the naive whole-blob
+// treatment conflated: private keys are dropped, CA certificates
+// (intermediates plus any root) form the bundle so leaves signed by an
+// intermediate verify correctly, and when the data carries no CA at all the
+// leaf itself is kept - Go accepts the peer cert being in the pool, which is
+// what lets clusters using a cert from an internal company CA keep working
+// without publishing that CA.
+func caBundleFromPEM(pemData []byte) []byte {
+	var cas, leaves []byte
+	for len(pemData) > 0 {
+		var block *pem.Block
+		block, pemData = pem.Decode(pemData)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			continue
+		}
+		encoded := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: block.Bytes})
+		if cert.IsCA {
+			cas = append(cas, encoded...)
+		} else {
+			leaves = append(leaves, encoded...)
+		}
+	}
+	if len(cas) != 0 {
+		return cas
+	}
+	return leaves
+}
+
+func getCanonicalHost(route *routev1.Route, host string) string {
 	for _, ingress := range route.Status.Ingress {
-		if ingress.Host != host {
+		if !hostsEqual(ingress.Host, host) {
 			continue
 		}
 		if !isIngressAdmitted(ingress) {
@@ -166,6 +878,40 @@ func getCanonicalHost(route *routev1.Route, ingressConfig *configv1.Ingress) str
 	return ""
 }
 
+// hostsEqual compares two hostnames the way DNS does - case-insensitively -
+// so a router reporting the admitted host with different casing does not look
+// like a missing admission.
+func hostsEqual(a, b string) bool {
+	return strings.EqualFold(a, b)
+}
+
+// admittedIngressWarnings collects the problems the router reports on the
+// ingress that admitted the route at the expected host.  any condition beside
+// RouteAdmitted that stands at true is a router-side warning - admission says
+// traffic flows today, these say something about it is still wrong.
+func admittedIngressWarnings(route *routev1.Route, host string) []string {
+	var warnings []string
+	for _, ingress := range route.Status.Ingress {
+		if !hostsEqual(ingress.Host, host) || !isIngressAdmitted(ingress) {
+			continue
+		}
+		for _, condition := range ingress.Conditions {
+			if condition.Type == routev1.RouteAdmitted || condition.Status != corev1.ConditionTrue {
+				continue
+			}
+			warning := string(condition.Type)
+			if len(condition.Reason) != 0 {
+				warning += " (" + condition.Reason + ")"
+			}
+			if len(condition.Message) != 0 {
+				warning += ": " + condition.Message
+			}
+			warnings = append(warnings, warning)
+		}
+	}
+	return warnings
+}
+
 func isIngressAdmitted(ingress routev1.RouteIngress) bool {
 	for _, condition := range ingress.Conditions {
 		if condition.Type == routev1.RouteAdmitted && condition.Status == corev1.ConditionTrue {
@@ -175,6 +921,32 @@ func isIngressAdmitted(ingress routev1.RouteIngress) bool {
 	return false
 }
 
-func ingressToHost(ingress *configv1.Ingress) string {
-	return targetName + "." + ingress.Spec.Domain
+// routeToIssuerURL is issuerURL for the default route config.  the reconcile
+// path goes through the authOperator issuerURL method instead so a downstream
+// public-port override flows into every derivation.
+func routeToIssuerURL(route *routev1.Route) string {
+	return defaultRouteConfig().issuerURL(route)
+}
+
+// issuerURL maps the route (whose Spec.Host has already been fixed up to the
+// canonical host derived from the ingress config) to the canonical issuer URL
+// served via the router.  the route is public on publicPort - the port is only
+// made explicit when it is not the implicit https port.  every derivation of
+// the issuer must go through this function so that the metadata we publish and
+// the readiness comparison cannot drift apart.
+func (cfg routeConfig) issuerURL(route *routev1.Route) string {
+	port := cfg.publicPort
+	if port == 0 {
+		port = servicePort
+	}
+	issuer := "https://" + route.Spec.Host
+	if port != 443 {
+		issuer = fmt.Sprintf("%s:%d", issuer, port)
+	}
+	return issuer
+}
+
+// issuerURL derives the canonical issuer for the effective route config.
+func (c *authOperator) issuerURL(route *routev1.Route) string {
+	return c.routeConfig().issuerURL(route)
 }
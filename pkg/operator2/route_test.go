@@ -0,0 +1,1067 @@
+package operator2
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/pem"
+	"flag"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/klog"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	configv1 "github.com/openshift/api/config/v1"
+	operatorv1 "github.com/openshift/api/operator/v1"
+	routev1 "github.com/openshift/api/route/v1"
+	routefake "github.com/openshift/client-go/route/clientset/versioned/fake"
+	"github.com/openshift/library-go/pkg/operator/events"
+	"github.com/openshift/library-go/pkg/operator/v1helpers"
+)
+
+func testIngressConfig(domain string) *configv1.Ingress {
+	return &configv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{Name: globalConfigName},
+		Spec:       configv1.IngressSpec{Domain: domain},
+	}
+}
+
+// testAdmittedRoute returns the default route with status claiming admission at
+// the canonical host
+func testAdmittedRoute(ingress *configv1.Ingress) *routev1.Route {
+	route := defaultRouteConfig().defaultRoute(ingress)
+	route.Status.Ingress = []routev1.RouteIngress{
+		{
+			Host: defaultRouteConfig().host(ingress),
+			Conditions: []routev1.RouteIngressCondition{
+				{Type: routev1.RouteAdmitted, Status: corev1.ConditionTrue},
+			},
+		},
+	}
+	return route
+}
+
+func testRouterSecret(domain string) *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: routerCertsLocalName, Namespace: targetNamespace},
+		Data:       map[string][]byte{domain: []byte("certdata")},
+	}
+}
+
+func TestRouteHealthConditions(t *testing.T) {
+	now := time.Date(2019, time.June, 1, 12, 0, 0, 0, time.UTC)
+	ingress := testIngressConfig("apps.example.com")
+	ca := newTestCA(t, "router-ca", now.Add(-time.Hour), now.Add(365*24*time.Hour))
+	certPEM, keyPEM := ca.issue(t, "router", []string{"*.apps.example.com"}, false, now.Add(-time.Hour), now.Add(365*24*time.Hour))
+
+	routerSecret := testRouterSecret("apps.example.com")
+	routerSecret.Data["apps.example.com"] = append(append([]byte{}, certPEM...), keyPEM...)
+
+	c := &authOperator{clock: func() time.Time { return now }}
+
+	find := func(conditions []operatorv1.OperatorCondition, conditionType string) operatorv1.OperatorCondition {
+		for _, condition := range conditions {
+			if condition.Type == conditionType {
+				return condition
+			}
+		}
+		t.Fatalf("condition %s not found in %#v", conditionType, conditions)
+		return operatorv1.OperatorCondition{}
+	}
+
+	// a healthy triple yields the full set, all false
+	conditions := c.routeHealthConditions(testAdmittedRoute(ingress), routerSecret, ingress)
+	for _, conditionType := range []string{"RouteConfigDegraded", "RouteNotAdmitted", "RouteReconcileFight", "RouteIngressWarning", "RouterSecretDegraded", "RouteCertsExpiring"} {
+		if condition := find(conditions, conditionType); condition.Status != operatorv1.ConditionFalse {
+			t.Errorf("expected %s false for a healthy triple, got %#v", conditionType, condition)
+		}
+	}
+
+	// a route that targets the wrong service is flagged as invalid
+	badRoute := testAdmittedRoute(ingress)
+	badRoute.Spec.To.Name = "other-service"
+	if condition := find(c.routeHealthConditions(badRoute, routerSecret, ingress), "RouteConfigDegraded"); condition.Status != operatorv1.ConditionTrue || condition.Reason != "InvalidRoute" {
+		t.Errorf("expected RouteConfigDegraded for a wrong target service, got %#v", condition)
+	}
+
+	// a route without admitted status is flagged as not admitted
+	notAdmitted := defaultRouteConfig().defaultRoute(ingress)
+	if condition := find(c.routeHealthConditions(notAdmitted, routerSecret, ingress), "RouteNotAdmitted"); condition.Status != operatorv1.ConditionTrue {
+		t.Errorf("expected RouteNotAdmitted for a route without admitted status, got %#v", condition)
+	}
+
+	// enough corrections since start read as a fight over the route
+	fighting := &authOperator{clock: func() time.Time { return now }, routeCorrections: routeFightThreshold}
+	if condition := find(fighting.routeHealthConditions(testAdmittedRoute(ingress), routerSecret, ingress), "RouteReconcileFight"); condition.Status != operatorv1.ConditionTrue || condition.Reason != "TooManyCorrections" {
+		t.Errorf("expected RouteReconcileFight at the correction threshold, got %#v", condition)
+	}
+
+	// an admitted ingress carrying further true conditions is not fully
+	// healthy - the router-side warning is surfaced instead of swallowed
+	warned := testAdmittedRoute(ingress)
+	warned.Status.Ingress[0].Conditions = append(warned.Status.Ingress[0].Conditions, routev1.RouteIngressCondition{
+		Type:    "UnservableInFutureVersions",
+		Status:  corev1.ConditionTrue,
+		Reason:  "ExternallyManagedHostname",
+		Message: "the route will not be servable in future versions",
+	})
+	warning := find(c.routeHealthConditions(warned, routerSecret, ingress), "RouteIngressWarning")
+	if warning.Status != operatorv1.ConditionTrue || warning.Reason != "IngressWarning" {
+		t.Errorf("expected RouteIngressWarning for an admitted-but-warned ingress, got %#v", warning)
+	}
+	for _, want := range []string{"UnservableInFutureVersions", "ExternallyManagedHostname", "not be servable"} {
+		if !strings.Contains(warning.Message, want) {
+			t.Errorf("expected the warning message to contain %q, got %q", want, warning.Message)
+		}
+	}
+
+	// an empty router secret is flagged, without leaking any data
+	empty := testRouterSecret("apps.example.com")
+	empty.Data = nil
+	if condition := find(c.routeHealthConditions(testAdmittedRoute(ingress), empty, ingress), "RouterSecretDegraded"); condition.Status != operatorv1.ConditionTrue || condition.Reason != "SecretMissing" {
+		t.Errorf("expected RouterSecretDegraded for an empty secret, got %#v", condition)
+	}
+
+	// a secret without an entry for the ingress domain is flagged
+	wrongDomain := testRouterSecret("apps.other.com")
+	if condition := find(c.routeHealthConditions(testAdmittedRoute(ingress), wrongDomain, ingress), "RouterSecretDegraded"); condition.Status != operatorv1.ConditionTrue || condition.Reason != "DomainMissing" {
+		t.Errorf("expected RouterSecretDegraded for a missing domain entry, got %#v", condition)
+	}
+
+	// a serving cert within the expiry warning window is flagged
+	expiringCert, expiringKey := ca.issue(t, "router", []string{"*.apps.example.com"}, false, now.Add(-time.Hour), now.Add(7*24*time.Hour))
+	expiringSecret := testRouterSecret("apps.example.com")
+	expiringSecret.Data["apps.example.com"] = append(append([]byte{}, expiringCert...), expiringKey...)
+	condition := find(c.routeHealthConditions(testAdmittedRoute(ingress), expiringSecret, ingress), "RouteCertsExpiring")
+	if condition.Status != operatorv1.ConditionTrue || condition.Reason != "CertificateExpiring" {
+		t.Errorf("expected RouteCertsExpiring for a cert close to expiry, got %#v", condition)
+	}
+	if !strings.Contains(condition.Message, "expires in") {
+		t.Errorf("expected the message to state the remaining time, got %q", condition.Message)
+	}
+}
+
+// TestRouterSecretChainedCA asserts that a router secret carrying a full
+// chain (leaf, intermediate, root, key) yields a verification bundle of just
+// the CAs - enough to verify the intermediate-signed leaf - and never leaks
+// the private key into the CA data.
+func TestRouterSecretChainedCA(t *testing.T) {
+	now := time.Now()
+	root := newTestCA(t, "root-ca", now.Add(-time.Hour), now.Add(24*time.Hour))
+	intermediate := root.newIntermediateCA(t, "intermediate-ca", now.Add(-time.Hour), now.Add(24*time.Hour))
+	leafPEM, keyPEM := intermediate.issue(t, "router", []string{"*.apps.example.com"}, false, now.Add(-time.Hour), now.Add(24*time.Hour))
+
+	chained := append(append(append(append([]byte{}, leafPEM...), intermediate.certPEM...), root.certPEM...), keyPEM...)
+
+	bundle := caBundleFromPEM(chained)
+	if strings.Contains(string(bundle), "PRIVATE KEY") {
+		t.Fatal("the private key leaked into the CA bundle")
+	}
+
+	// the bundle is exactly the CAs, not the leaf
+	cas := 0
+	for rest := bundle; len(rest) > 0; {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			t.Fatalf("unparseable certificate in the bundle: %v", err)
+		}
+		if !cert.IsCA {
+			t.Errorf("non-CA certificate %q ended up in the bundle", cert.Subject.CommonName)
+		}
+		cas++
+	}
+	if cas != 2 {
+		t.Errorf("expected the intermediate and the root in the bundle, got %d certificates", cas)
+	}
+
+	// the bundle actually verifies the intermediate-signed leaf
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(bundle) {
+		t.Fatal("failed to load the bundle into a cert pool")
+	}
+	leafBlock, _ := pem.Decode(leafPEM)
+	leaf, err := x509.ParseCertificate(leafBlock.Bytes)
+	if err != nil {
+		t.Fatalf("failed to parse the leaf: %v", err)
+	}
+	if _, err := leaf.Verify(x509.VerifyOptions{Roots: pool}); err != nil {
+		t.Errorf("the leaf does not verify against the extracted bundle: %v", err)
+	}
+
+	// routerSecretToCA uses the extracted bundle for the route's CA data
+	ingress := testIngressConfig("apps.example.com")
+	secret := testRouterSecret("apps.example.com")
+	secret.Data["apps.example.com"] = chained
+	if got := routerSecretToCA(testRoute("oauth-openshift.apps.example.com"), secret, ingress); !bytes.Equal(got, bundle) {
+		t.Error("routerSecretToCA did not return the extracted bundle")
+	}
+
+	// a secret with only a leaf and a key keeps the leaf, preserving the
+	// tolerant internal-company-CA behavior
+	leafOnly := append(append([]byte{}, leafPEM...), keyPEM...)
+	if got := caBundleFromPEM(leafOnly); !bytes.Equal(got, leafPEM) {
+		t.Error("expected the leaf to be kept when the secret carries no CA")
+	}
+
+	// no certificate blocks at all means system roots
+	secret.Data["apps.example.com"] = keyPEM
+	if got := routerSecretToCA(testRoute("oauth-openshift.apps.example.com"), secret, ingress); got != nil {
+		t.Error("expected nil CA data for a secret without certificates")
+	}
+}
+
+func TestOAuthBackendCondition(t *testing.T) {
+	ingress := testIngressConfig("apps.example.com")
+
+	oauthEndpoints := func(ips ...string) *corev1.Endpoints {
+		endpoints := &corev1.Endpoints{
+			ObjectMeta: metav1.ObjectMeta{Name: targetName, Namespace: targetNamespace},
+		}
+		for _, ip := range ips {
+			endpoints.Subsets = append(endpoints.Subsets, corev1.EndpointSubset{
+				Addresses: []corev1.EndpointAddress{{IP: ip}},
+			})
+		}
+		return endpoints
+	}
+
+	oauthService := func() *corev1.Service {
+		return &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: targetName, Namespace: targetNamespace}}
+	}
+	newOperator := func(objects ...runtime.Object) *authOperator {
+		kubeClient := kubefake.NewSimpleClientset(objects...)
+		return &authOperator{services: kubeClient.CoreV1(), endpoints: kubeClient.CoreV1()}
+	}
+
+	// a route that is not admitted yet is not the backend's problem
+	c := newOperator()
+	if condition := c.oauthBackendCondition(defaultRouteConfig().defaultRoute(ingress), ingress); condition.Status != operatorv1.ConditionFalse {
+		t.Errorf("expected no backend condition before admission, got %#v", condition)
+	}
+
+	// admitted route with ready endpoints is healthy
+	c = newOperator(oauthService(), oauthEndpoints("10.128.0.5"))
+	if condition := c.oauthBackendCondition(testAdmittedRoute(ingress), ingress); condition.Status != operatorv1.ConditionFalse {
+		t.Errorf("expected a healthy backend condition, got %#v", condition)
+	}
+
+	// an admitted route whose target service was deleted is named explicitly
+	c = newOperator(oauthEndpoints("10.128.0.5"))
+	condition := c.oauthBackendCondition(testAdmittedRoute(ingress), ingress)
+	if condition.Status != operatorv1.ConditionTrue || condition.Reason != "ServiceMissing" {
+		t.Fatalf("expected ServiceMissing, got %#v", condition)
+	}
+	if !strings.Contains(condition.Message, targetNamespace+"/"+targetName) {
+		t.Errorf("expected the message to name the missing service, got %q", condition.Message)
+	}
+
+	// admitted route with no ready addresses is the partial failure this
+	// condition exists for
+	c = newOperator(oauthService(), oauthEndpoints())
+	condition = c.oauthBackendCondition(testAdmittedRoute(ingress), ingress)
+	if condition.Status != operatorv1.ConditionTrue || condition.Reason != "NoReadyBackend" {
+		t.Fatalf("expected NoReadyBackend, got %#v", condition)
+	}
+	if !strings.Contains(condition.Message, "oauth-openshift.apps.example.com") {
+		t.Errorf("expected the message to name the admitted host, got %q", condition.Message)
+	}
+
+	// a failed endpoints lookup is reported distinctly instead of pretending health
+	c = newOperator(oauthService())
+	if condition := c.oauthBackendCondition(testAdmittedRoute(ingress), ingress); condition.Status != operatorv1.ConditionTrue || condition.Reason != "EndpointsLookupFailed" {
+		t.Errorf("expected EndpointsLookupFailed, got %#v", condition)
+	}
+
+	// a drifted target is judged by the service the route actually points at,
+	// for both the service and the endpoints lookup
+	drifted := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "not-oauth", Namespace: targetNamespace}}
+	driftedEndpoints := oauthEndpoints()
+	driftedEndpoints.Name = "not-oauth"
+	c = newOperator(drifted, driftedEndpoints)
+	route := testAdmittedRoute(ingress)
+	route.Spec.To.Name = "not-oauth"
+	condition = c.oauthBackendCondition(route, ingress)
+	if condition.Status != operatorv1.ConditionTrue || condition.Reason != "NoReadyBackend" {
+		t.Fatalf("expected NoReadyBackend for the drifted target, got %#v", condition)
+	}
+	if !strings.Contains(condition.Message, targetNamespace+"/not-oauth") {
+		t.Errorf("expected the message to name the drifted service, got %q", condition.Message)
+	}
+}
+
+func TestHandleRouteDrift(t *testing.T) {
+	ingress := testIngressConfig("apps.example.com")
+	operatorConfig := &operatorv1.Authentication{}
+	c := &authOperator{}
+
+	find := func() *operatorv1.OperatorCondition {
+		condition := v1helpers.FindOperatorCondition(operatorConfig.Status.Conditions, "RouteSpecDrift")
+		if condition == nil {
+			t.Fatal("RouteSpecDrift condition not set")
+		}
+		return condition
+	}
+
+	// first sync just records the hash
+	c.handleRouteDrift(operatorConfig, defaultRouteConfig().defaultRoute(ingress))
+	if condition := find(); condition.Status != operatorv1.ConditionFalse {
+		t.Errorf("expected no drift on first sync, got %#v", condition)
+	}
+
+	// unchanged route keeps the condition false
+	c.handleRouteDrift(operatorConfig, defaultRouteConfig().defaultRoute(ingress))
+	if condition := find(); condition.Status != operatorv1.ConditionFalse {
+		t.Errorf("expected no drift for an unchanged route, got %#v", condition)
+	}
+
+	// a mutated admin-relevant field is drift
+	mutated := defaultRouteConfig().defaultRoute(ingress)
+	mutated.Spec.To.Name = "tampered"
+	c.handleRouteDrift(operatorConfig, mutated)
+	if condition := find(); condition.Status != operatorv1.ConditionTrue {
+		t.Errorf("expected drift for a mutated route, got %#v", condition)
+	}
+
+	// a host-only difference is ours to reconcile, not drift
+	hostOnly := defaultRouteConfig().defaultRoute(ingress)
+	c.handleRouteDrift(operatorConfig, hostOnly)
+	hostOnly.Spec.Host = "something.else.example.com"
+	c.handleRouteDrift(operatorConfig, hostOnly)
+	if condition := find(); condition.Status != operatorv1.ConditionFalse {
+		t.Errorf("expected no drift for a host-only change, got %#v", condition)
+	}
+}
+
+func TestHandleRouteRestoresStrippedLabels(t *testing.T) {
+	ingress := testIngressConfig("apps.example.com")
+	stripped := testAdmittedRoute(ingress)
+	stripped.Labels = map[string]string{"admin-added": "kept"}
+
+	routeClient := routefake.NewSimpleClientset(stripped).RouteV1().Routes(targetNamespace)
+	c := &authOperator{
+		route:    routeClient,
+		secrets:  kubefake.NewSimpleClientset(testRouterSecret("apps.example.com")).CoreV1(),
+		recorder: events.NewInMemoryRecorder("test"),
+	}
+	route, _, err := c.handleRoute(ingress)
+	if err != nil {
+		t.Fatalf("handleRoute() error = %v", err)
+	}
+
+	// the operator's labels come back, entries other actors added stay
+	for _, got := range []*routev1.Route{route} {
+		if got.Labels["app"] != targetName {
+			t.Errorf("expected the app label to be restored, got %v", got.Labels)
+		}
+		if got.Labels["admin-added"] != "kept" {
+			t.Errorf("expected the admin-added label to survive, got %v", got.Labels)
+		}
+	}
+	live, err := routeClient.Get(targetName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("failed to get route: %v", err)
+	}
+	if live.Labels["app"] != targetName || live.Labels["admin-added"] != "kept" {
+		t.Errorf("expected the restore to be persisted, got %v", live.Labels)
+	}
+
+	// an intact route is not updated again
+	if stripped := strippedRouteMetaEntries(live, defaultRouteConfig().defaultRoute(ingress).ObjectMeta); len(stripped) != 0 {
+		t.Errorf("expected nothing left to restore, got %v", stripped)
+	}
+}
+
+func TestHandleRouteRestoresMangledHost(t *testing.T) {
+	ingress := testIngressConfig("apps.example.com")
+	mangled := testAdmittedRoute(ingress)
+	mangled.Spec.Host = "oauth.wrong.example.net"
+
+	routeClient := routefake.NewSimpleClientset(mangled).RouteV1().Routes(targetNamespace)
+	c := &authOperator{
+		route:    routeClient,
+		secrets:  kubefake.NewSimpleClientset(testRouterSecret("apps.example.com")).CoreV1(),
+		recorder: events.NewInMemoryRecorder("test"),
+	}
+	if _, _, err := c.handleRoute(ingress); err != nil {
+		t.Fatalf("handleRoute() error = %v", err)
+	}
+
+	live, err := routeClient.Get(targetName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("failed to get route: %v", err)
+	}
+	if want := defaultRouteConfig().host(ingress); live.Spec.Host != want {
+		t.Errorf("expected the canonical host %s to be restored, got %s", want, live.Spec.Host)
+	}
+}
+
+// TestHandleRoutePaused asserts the pause annotation turns handleRoute into a
+// read-and-report pass: drifted state is left in place and a missing route is
+// reported instead of created.
+func TestHandleRoutePaused(t *testing.T) {
+	ingress := testIngressConfig("apps.example.com")
+	mangled := testAdmittedRoute(ingress)
+	mangled.Spec.Host = "oauth.wrong.example.net"
+
+	routeClient := routefake.NewSimpleClientset(mangled).RouteV1().Routes(targetNamespace)
+	c := &authOperator{
+		mutationsPaused: true,
+		route:           routeClient,
+		secrets:         kubefake.NewSimpleClientset(testRouterSecret("apps.example.com")).CoreV1(),
+		recorder:        events.NewInMemoryRecorder("test"),
+	}
+	if _, _, err := c.handleRoute(ingress); err != nil {
+		t.Fatalf("handleRoute() error = %v", err)
+	}
+	live, err := routeClient.Get(targetName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("failed to get route: %v", err)
+	}
+	if live.Spec.Host != "oauth.wrong.example.net" {
+		t.Errorf("expected the mangled host to be left alone while paused, got %s", live.Spec.Host)
+	}
+
+	// a missing route is reported, not created
+	c.route = routefake.NewSimpleClientset().RouteV1().Routes(targetNamespace)
+	if _, _, err := c.handleRoute(ingress); !errors.IsNotFound(err) {
+		t.Errorf("expected the missing route to surface as not found while paused, got %v", err)
+	}
+}
+
+func TestHandleRouteHostValidation(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		domain  string
+		wantErr bool
+	}{
+		{name: "valid domain", domain: "apps.example.com", wantErr: false},
+		{name: "invalid domain", domain: "bad_domain.example.com!", wantErr: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			ingress := testIngressConfig(tt.domain)
+			c := &authOperator{
+				route:    routefake.NewSimpleClientset(testAdmittedRoute(ingress)).RouteV1().Routes(targetNamespace),
+				secrets:  kubefake.NewSimpleClientset(testRouterSecret(tt.domain)).CoreV1(),
+				recorder: events.NewInMemoryRecorder("test"),
+			}
+			_, _, err := c.handleRoute(ingress)
+			if tt.wantErr != (err != nil) {
+				t.Errorf("handleRoute() error = %v, wantErr = %v", err, tt.wantErr)
+			}
+			if tt.wantErr && !strings.Contains(err.Error(), "invalid route host") {
+				t.Errorf("expected an invalid host error, got: %v", err)
+			}
+		})
+	}
+}
+
+// captureKlog redirects klog output into a buffer for the duration of the
+// test so assertions can be made about what gets logged.
+func captureKlog(t *testing.T) *bytes.Buffer {
+	fs := flag.NewFlagSet("klog", flag.ContinueOnError)
+	klog.InitFlags(fs)
+	if err := fs.Set("logtostderr", "false"); err != nil {
+		t.Fatalf("failed to redirect klog: %v", err)
+	}
+	if err := fs.Set("stderrthreshold", "FATAL"); err != nil {
+		t.Fatalf("failed to redirect klog: %v", err)
+	}
+	buf := &bytes.Buffer{}
+	klog.SetOutput(buf)
+	t.Cleanup(func() {
+		klog.Flush()
+		if err := fs.Set("logtostderr", "true"); err != nil {
+			t.Errorf("failed to restore klog: %v", err)
+		}
+	})
+	return buf
+}
+
+// TestHandleRouteInvalidRouteLogging asserts that the invalid-route delete
+// path never logs the route spec - Spec.TLS can carry certificate and key
+// material that must not end up in must-gathers.
+func TestHandleRouteInvalidRouteLogging(t *testing.T) {
+	logged := captureKlog(t)
+
+	ingress := testIngressConfig("apps.example.com")
+	invalid := testAdmittedRoute(ingress)
+	// an invalid route (wrong target service) that also carries TLS material
+	invalid.Spec.To.Name = "tampered"
+	invalid.Spec.TLS.Certificate = "FAKE-CERTIFICATE-MATERIAL"
+	invalid.Spec.TLS.Key = "FAKE-PRIVATE-KEY-MATERIAL"
+
+	c := &authOperator{
+		route:    routefake.NewSimpleClientset(invalid).RouteV1().Routes(targetNamespace),
+		secrets:  kubefake.NewSimpleClientset(testRouterSecret("apps.example.com")).CoreV1(),
+		recorder: events.NewInMemoryRecorder("test"),
+	}
+	_, _, err := c.handleRoute(ingress)
+	if err == nil {
+		t.Fatal("expected an error for an invalid route")
+	}
+
+	klog.Flush()
+	output := logged.String()
+	if !strings.Contains(output, "deleting invalid route "+targetNamespace+"/"+targetName) {
+		t.Errorf("expected the delete to be logged with the route identity, got:\n%s", output)
+	}
+	for _, leak := range []string{"FAKE-CERTIFICATE-MATERIAL", "FAKE-PRIVATE-KEY-MATERIAL"} {
+		if strings.Contains(output, leak) {
+			t.Errorf("TLS material leaked into the logs:\n%s", output)
+		}
+		// the validation error ends up in conditions and events - it must be
+		// just as clean as the logs
+		if strings.Contains(err.Error(), leak) {
+			t.Errorf("TLS material leaked into the returned error: %v", err)
+		}
+	}
+}
+
+// TestRouteAdmissionEvent asserts that the route announces itself with a
+// single event per admission transition - once when it first comes live, not
+// on every reconcile, and again after losing and regaining admission.
+func TestRouteAdmissionEvent(t *testing.T) {
+	ingress := testIngressConfig("apps.example.com")
+	recorder := events.NewInMemoryRecorder("test")
+	c := &authOperator{
+		route:    routefake.NewSimpleClientset(testAdmittedRoute(ingress)).RouteV1().Routes(targetNamespace),
+		secrets:  kubefake.NewSimpleClientset(testRouterSecret("apps.example.com")).CoreV1(),
+		recorder: recorder,
+	}
+
+	admittedEvents := func() []string {
+		var messages []string
+		for _, event := range recorder.Events() {
+			if event.Reason == "OAuthRouteAdmitted" {
+				messages = append(messages, event.Message)
+			}
+		}
+		return messages
+	}
+
+	// repeated syncs of an admitted route announce it exactly once
+	for i := 0; i < 3; i++ {
+		if _, _, err := c.handleRoute(ingress); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	messages := admittedEvents()
+	if len(messages) != 1 {
+		t.Fatalf("expected exactly one admission event, got %d: %v", len(messages), messages)
+	}
+	if !strings.Contains(messages[0], "oauth-openshift.apps.example.com") {
+		t.Errorf("expected the event to name the admitted host, got %q", messages[0])
+	}
+
+	// losing admission fires nothing but rearms the announcement
+	c.route = routefake.NewSimpleClientset(defaultRouteConfig().defaultRoute(ingress)).RouteV1().Routes(targetNamespace)
+	if _, _, err := c.handleRoute(ingress); err == nil {
+		t.Fatal("expected an error while the route is not admitted")
+	}
+	if got := admittedEvents(); len(got) != 1 {
+		t.Fatalf("expected no new event while not admitted, got %v", got)
+	}
+
+	// regaining admission announces again
+	c.route = routefake.NewSimpleClientset(testAdmittedRoute(ingress)).RouteV1().Routes(targetNamespace)
+	if _, _, err := c.handleRoute(ingress); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := admittedEvents(); len(got) != 2 {
+		t.Fatalf("expected a second admission event after re-admission, got %v", got)
+	}
+}
+
+// TestSanitizeRouteForLog asserts that the log rendering of a route never
+// contains TLS material or annotation values, and that sanitizing does not
+// mutate the caller's route.
+func TestSanitizeRouteForLog(t *testing.T) {
+	if got := sanitizeRouteForLog(nil); got != "<nil>" {
+		t.Errorf("sanitizeRouteForLog(nil) = %q, want <nil>", got)
+	}
+
+	ingress := testIngressConfig("apps.example.com")
+	route := testAdmittedRoute(ingress)
+	route.Annotations = map[string]string{"example.com/token": "FAKE-ANNOTATION-SECRET"}
+	route.Spec.TLS.Certificate = "FAKE-CERTIFICATE-MATERIAL"
+	route.Spec.TLS.Key = "FAKE-PRIVATE-KEY-MATERIAL"
+	route.Spec.TLS.CACertificate = "FAKE-CA-MATERIAL"
+	route.Spec.TLS.DestinationCACertificate = "FAKE-DESTINATION-CA-MATERIAL"
+
+	sanitized := sanitizeRouteForLog(route)
+	for _, leak := range []string{
+		"FAKE-CERTIFICATE-MATERIAL",
+		"FAKE-PRIVATE-KEY-MATERIAL",
+		"FAKE-CA-MATERIAL",
+		"FAKE-DESTINATION-CA-MATERIAL",
+		"FAKE-ANNOTATION-SECRET",
+	} {
+		if strings.Contains(sanitized, leak) {
+			t.Errorf("sensitive value %q leaked into the sanitized output", leak)
+		}
+	}
+
+	// the useful identifying parts survive sanitizing
+	for _, want := range []string{route.Name, "<redacted>", "example.com/token"} {
+		if !strings.Contains(sanitized, want) {
+			t.Errorf("expected the sanitized output to contain %q", want)
+		}
+	}
+
+	// the caller's route is untouched
+	if route.Spec.TLS.Key != "FAKE-PRIVATE-KEY-MATERIAL" || route.Annotations["example.com/token"] != "FAKE-ANNOTATION-SECRET" {
+		t.Error("sanitizeRouteForLog mutated its input")
+	}
+}
+
+func TestHandleRouteAwaitingAdmission(t *testing.T) {
+	ingress := testIngressConfig("apps.example.com")
+
+	// a freshly created route with an empty status is the normal admission
+	// delay and must surface as the typed progressing error
+	fresh := defaultRouteConfig().defaultRoute(ingress)
+	c := &authOperator{
+		route:    routefake.NewSimpleClientset(fresh).RouteV1().Routes(targetNamespace),
+		secrets:  kubefake.NewSimpleClientset(testRouterSecret("apps.example.com")).CoreV1(),
+		recorder: events.NewInMemoryRecorder("test"),
+	}
+	_, _, err := c.handleRoute(ingress)
+	if _, ok := err.(*routeNotAdmittedError); !ok {
+		t.Errorf("expected a routeNotAdmittedError for an empty status, got: %v", err)
+	}
+	if err == nil || !strings.Contains(err.Error(), "awaiting admission") {
+		t.Errorf("expected an awaiting admission message, got: %v", err)
+	}
+
+	// a route still admitted at a host under another domain is an ingress
+	// domain change in progress, reported as its own transitional error
+	oldHost := defaultRouteConfig().defaultRoute(ingress)
+	oldHost.Status.Ingress = []routev1.RouteIngress{
+		{
+			Host: "oauth-openshift.apps.old.example.com",
+			Conditions: []routev1.RouteIngressCondition{
+				{Type: routev1.RouteAdmitted, Status: corev1.ConditionTrue},
+			},
+		},
+	}
+	c.route = routefake.NewSimpleClientset(oldHost).RouteV1().Routes(targetNamespace)
+	_, _, err = c.handleRoute(ingress)
+	if _, ok := err.(*domainMigrationError); !ok {
+		t.Fatalf("expected a domainMigrationError for a route admitted under the old domain, got: %v", err)
+	}
+	for _, want := range []string{"oauth-openshift.apps.old.example.com", "oauth-openshift.apps.example.com"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected the message to name %q, got: %v", want, err)
+		}
+	}
+
+	// a non-admitted entry at another host is still a hard failure, not a
+	// migration in progress
+	rejected := defaultRouteConfig().defaultRoute(ingress)
+	rejected.Status.Ingress = []routev1.RouteIngress{
+		{
+			Host: "oauth-openshift.apps.old.example.com",
+			Conditions: []routev1.RouteIngressCondition{
+				{Type: routev1.RouteAdmitted, Status: corev1.ConditionFalse},
+			},
+		},
+	}
+	c.route = routefake.NewSimpleClientset(rejected).RouteV1().Routes(targetNamespace)
+	_, _, err = c.handleRoute(ingress)
+	if err == nil {
+		t.Fatal("expected an error for a route with no admitted hosts")
+	}
+	if _, ok := err.(*domainMigrationError); ok {
+		t.Errorf("a rejected host must not be reported as a domain migration, got: %v", err)
+	}
+	if _, ok := err.(*routeNotAdmittedError); ok {
+		t.Errorf("a rejected host must not be reported as admission delay, got: %v", err)
+	}
+}
+
+func TestHandleRouteRestoresTLS(t *testing.T) {
+	ingress := testIngressConfig("apps.example.com")
+	stripped := testAdmittedRoute(ingress)
+	stripped.Spec.TLS = nil
+
+	routeClient := routefake.NewSimpleClientset(stripped)
+	c := &authOperator{
+		route:    routeClient.RouteV1().Routes(targetNamespace),
+		secrets:  kubefake.NewSimpleClientset(testRouterSecret("apps.example.com")).CoreV1(),
+		recorder: events.NewInMemoryRecorder("test"),
+	}
+
+	route, _, err := c.handleRoute(ingress)
+	if err != nil {
+		t.Fatalf("handleRoute() returned error: %v", err)
+	}
+	if route.Spec.TLS == nil || route.Spec.TLS.Termination != routev1.TLSTerminationPassthrough {
+		t.Errorf("expected passthrough TLS to be restored, got %#v", route.Spec.TLS)
+	}
+
+	live, err := routeClient.RouteV1().Routes(targetNamespace).Get(targetName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("failed to get route: %v", err)
+	}
+	if live.Spec.TLS == nil {
+		t.Error("expected the live route to have its TLS configuration restored")
+	}
+}
+
+func TestHandleIssuerDNS(t *testing.T) {
+	route := testRoute("oauth-openshift.apps.example.com")
+	operatorConfig := &operatorv1.Authentication{}
+
+	// disabled by default - no condition is written
+	c := &authOperator{}
+	c.handleIssuerDNS(operatorConfig, route)
+	if condition := v1helpers.FindOperatorCondition(operatorConfig.Status.Conditions, "IssuerDNSResolution"); condition != nil {
+		t.Errorf("expected no condition while the check is disabled, got %#v", condition)
+	}
+
+	// a failing lookup is reported distinctly from metadata problems
+	c = &authOperator{
+		checkIssuerDNS: true,
+		resolveHost: func(host string) ([]string, error) {
+			return nil, fmt.Errorf("no such host %s", host)
+		},
+	}
+	c.handleIssuerDNS(operatorConfig, route)
+	condition := v1helpers.FindOperatorCondition(operatorConfig.Status.Conditions, "IssuerDNSResolution")
+	if condition == nil || condition.Status != operatorv1.ConditionTrue || condition.Reason != "DNSResolutionFailed" {
+		t.Errorf("expected a DNS failure condition, got %#v", condition)
+	}
+
+	// a resolvable host clears the condition
+	c.resolveHost = func(host string) ([]string, error) { return []string{"10.0.0.1"}, nil }
+	c.handleIssuerDNS(operatorConfig, route)
+	if condition := v1helpers.FindOperatorCondition(operatorConfig.Status.Conditions, "IssuerDNSResolution"); condition == nil || condition.Status != operatorv1.ConditionFalse {
+		t.Errorf("expected the condition to clear, got %#v", condition)
+	}
+}
+
+func TestValidateTLSCombination(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		tls     *routev1.TLSConfig
+		wantErr bool
+	}{
+		{name: "nil TLS", tls: nil, wantErr: false},
+		{name: "passthrough with redirect", tls: &routev1.TLSConfig{Termination: routev1.TLSTerminationPassthrough, InsecureEdgeTerminationPolicy: routev1.InsecureEdgeTerminationPolicyRedirect}, wantErr: false},
+		{name: "passthrough with none", tls: &routev1.TLSConfig{Termination: routev1.TLSTerminationPassthrough, InsecureEdgeTerminationPolicy: routev1.InsecureEdgeTerminationPolicyNone}, wantErr: false},
+		{name: "edge with allow", tls: &routev1.TLSConfig{Termination: routev1.TLSTerminationEdge, InsecureEdgeTerminationPolicy: routev1.InsecureEdgeTerminationPolicyAllow}, wantErr: false},
+		{name: "passthrough with allow", tls: &routev1.TLSConfig{Termination: routev1.TLSTerminationPassthrough, InsecureEdgeTerminationPolicy: routev1.InsecureEdgeTerminationPolicyAllow}, wantErr: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateTLSCombination(tt.tls)
+			if tt.wantErr != (err != nil) {
+				t.Errorf("validateTLSCombination() error = %v, wantErr = %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	// the cross-field check is part of route validation
+	ingress := testIngressConfig("apps.example.com")
+	route := defaultRouteConfig().defaultRoute(ingress)
+	route.Spec.TLS.InsecureEdgeTerminationPolicy = routev1.InsecureEdgeTerminationPolicyAllow
+	if err := defaultRouteConfig().isValidRoute(route, ingress); err == nil || !strings.Contains(err.Error(), "not valid with") {
+		t.Errorf("expected the combination error from isValidRoute, got: %v", err)
+	}
+}
+
+// TestIsValidRouteInlineTLSMaterial asserts that inline cert/key data on the
+// passthrough route is rejected, that both offending fields are reported at
+// once, and that the values themselves never appear in the error.
+func TestIsValidRouteInlineTLSMaterial(t *testing.T) {
+	ingress := testIngressConfig("apps.example.com")
+
+	route := defaultRouteConfig().defaultRoute(ingress)
+	route.Spec.TLS.Certificate = "FAKE-CERTIFICATE-MATERIAL"
+	err := defaultRouteConfig().isValidRoute(route, ingress)
+	if err == nil || !strings.Contains(err.Error(), "inline certificate") {
+		t.Errorf("expected an inline certificate error, got: %v", err)
+	}
+
+	route = defaultRouteConfig().defaultRoute(ingress)
+	route.Spec.TLS.Certificate = "FAKE-CERTIFICATE-MATERIAL"
+	route.Spec.TLS.Key = "FAKE-PRIVATE-KEY-MATERIAL"
+	err = defaultRouteConfig().isValidRoute(route, ingress)
+	if err == nil {
+		t.Fatal("expected an error for inline cert and key")
+	}
+	for _, want := range []string{"inline certificate", "inline private key"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected the aggregated error to mention %q, got: %v", want, err)
+		}
+	}
+	for _, leak := range []string{"FAKE-CERTIFICATE-MATERIAL", "FAKE-PRIVATE-KEY-MATERIAL"} {
+		if strings.Contains(err.Error(), leak) {
+			t.Errorf("the error leaked inline TLS material %q: %v", leak, err)
+		}
+	}
+
+	// the pristine default route still validates
+	if err := defaultRouteConfig().isValidRoute(defaultRouteConfig().defaultRoute(ingress), ingress); err != nil {
+		t.Errorf("the default route should be valid: %v", err)
+	}
+}
+
+func TestRouteConfigOverride(t *testing.T) {
+	ingress := testIngressConfig("apps.example.com")
+	cfg := routeConfig{name: "oauth-downstream", namespace: "downstream-auth", containerPort: 8443}
+
+	route := cfg.defaultRoute(ingress)
+	if route.Name != "oauth-downstream" || route.Namespace != "downstream-auth" {
+		t.Errorf("expected the overridden identity on the route, got %s/%s", route.Namespace, route.Name)
+	}
+	if got, want := route.Spec.Host, "oauth-downstream.apps.example.com"; got != want {
+		t.Errorf("route host = %q, want %q", got, want)
+	}
+	if route.Spec.Port.TargetPort.IntValue() != 8443 {
+		t.Errorf("route target port = %d, want 8443", route.Spec.Port.TargetPort.IntValue())
+	}
+
+	// creation and validation share the same values - the generated route must
+	// validate against the config that generated it, and must not validate
+	// against the defaults
+	if err := cfg.isValidRoute(route, ingress); err != nil {
+		t.Errorf("the generated route should be valid for its own config: %v", err)
+	}
+	if err := defaultRouteConfig().isValidRoute(route, ingress); err == nil {
+		t.Error("the overridden route should not validate against the default config")
+	}
+
+	// the host-derived helpers follow the injected config too - SNI selection
+	// and the admitted-age lookup must not fall back to the default name
+	c := &authOperator{routeCfg: cfg, clock: time.Now}
+	sni := c.routerSecretToSNI(testRouterSecret("apps.example.com"), ingress)
+	if len(sni) != 1 || sni[0].Names[0] != "*.apps.example.com" {
+		t.Errorf("routerSecretToSNI() = %#v, want the wildcard entry for the overridden host", sni)
+	}
+	admitted := metav1.NewTime(time.Date(2019, time.April, 1, 12, 0, 0, 0, time.UTC))
+	route.Status.Ingress = []routev1.RouteIngress{{
+		Host:       cfg.host(ingress),
+		Conditions: []routev1.RouteIngressCondition{{Type: routev1.RouteAdmitted, Status: corev1.ConditionTrue, LastTransitionTime: &admitted}},
+	}}
+	if _, ok := c.routeAdmittedTime(route, ingress); !ok {
+		t.Error("expected the admitted time to be found under the overridden host")
+	}
+	if _, ok := (&authOperator{clock: time.Now}).routeAdmittedTime(route, ingress); ok {
+		t.Error("expected no admitted time under the default host for an overridden route")
+	}
+}
+
+func TestRouterSecretToSNIOverlappingDomains(t *testing.T) {
+	ingress := testIngressConfig("apps.example.com")
+
+	// both keys cover oauth-openshift.apps.example.com - the most specific
+	// one must win, and the unrelated key must be skipped entirely
+	routerSecret := testRouterSecret("apps.example.com")
+	routerSecret.Data["example.com"] = []byte("certdata")
+	routerSecret.Data["unrelated.com"] = []byte("certdata")
+
+	c := &authOperator{}
+	sni := c.routerSecretToSNI(routerSecret, ingress)
+	if len(sni) != 1 {
+		t.Fatalf("expected exactly one SNI entry, got %#v", sni)
+	}
+	if got, want := sni[0].Names[0], "*.apps.example.com"; got != want {
+		t.Errorf("SNI name = %q, want the most specific domain %q", got, want)
+	}
+	if got, want := sni[0].CertFile, routerCertsLocalMount+"/apps.example.com"; got != want {
+		t.Errorf("CertFile = %q, want %q", got, want)
+	}
+
+	// the selection is deterministic across invocations
+	for i := 0; i < 10; i++ {
+		again := c.routerSecretToSNI(routerSecret, ingress)
+		if len(again) != 1 || again[0].Names[0] != sni[0].Names[0] {
+			t.Fatalf("SNI selection is not deterministic: %#v", again)
+		}
+	}
+
+	if got := mostSpecificSNIDomain([]string{"example.com", "apps.example.com"}); got != "apps.example.com" {
+		t.Errorf("mostSpecificSNIDomain() = %q, want the longest domain", got)
+	}
+	if got := mostSpecificSNIDomain(nil); got != "" {
+		t.Errorf("mostSpecificSNIDomain(nil) = %q, want empty", got)
+	}
+}
+
+func TestRouterSecretStrayKeys(t *testing.T) {
+	ingress := testIngressConfig("apps.example.com")
+
+	secret := testRouterSecret("apps.example.com")
+	secret.Data["apps.stale.com"] = []byte("certdata")
+
+	if got := routerSecretStrayKeys(secret, ingress); len(got) != 1 || got[0] != "apps.stale.com" {
+		t.Errorf("routerSecretStrayKeys() = %v, want only the stray key", got)
+	}
+
+	// stray keys do not become SNI entries, the matching key does
+	c := &authOperator{}
+	sni := c.routerSecretToSNI(secret, ingress)
+	if len(sni) != 1 || sni[0].Names[0] != "*.apps.example.com" {
+		t.Errorf("routerSecretToSNI() = %#v, want a single wildcard entry for the ingress domain", sni)
+	}
+
+	// the divergence shows up as a condition
+	operatorConfig := &operatorv1.Authentication{}
+	c.handleRouterCertsDomains(operatorConfig, secret, ingress)
+	condition := v1helpers.FindOperatorCondition(operatorConfig.Status.Conditions, "RouterCertsDomainMismatch")
+	if condition == nil || condition.Status != operatorv1.ConditionTrue || !strings.Contains(condition.Message, "apps.stale.com") {
+		t.Errorf("expected a true mismatch condition naming the stray key, got %#v", condition)
+	}
+
+	c.handleRouterCertsDomains(operatorConfig, testRouterSecret("apps.example.com"), ingress)
+	if condition := v1helpers.FindOperatorCondition(operatorConfig.Status.Conditions, "RouterCertsDomainMismatch"); condition == nil || condition.Status != operatorv1.ConditionFalse {
+		t.Errorf("expected the condition to clear for a clean secret, got %#v", condition)
+	}
+}
+
+func TestRouteToIssuerURL(t *testing.T) {
+	route := testRoute("oauth-openshift.apps.example.com")
+
+	// servicePort is the implicit https port so the issuer must not carry an
+	// explicit port - the served metadata would not match otherwise
+	if got, want := routeToIssuerURL(route), "https://oauth-openshift.apps.example.com"; got != want {
+		t.Errorf("routeToIssuerURL() = %q, want %q", got, want)
+	}
+
+	// the published metadata must agree with the issuer derivation
+	if metadata := getMetadata(route); !strings.Contains(metadata, `"issuer": "`+routeToIssuerURL(route)+`"`) {
+		t.Errorf("metadata issuer does not match routeToIssuerURL():\n%s", metadata)
+	}
+
+	// a downstream public-port override makes the port explicit in the issuer,
+	// and the operator derivation honors the injected config
+	cfg := defaultRouteConfig()
+	cfg.publicPort = 8443
+	if got, want := cfg.issuerURL(route), "https://oauth-openshift.apps.example.com:8443"; got != want {
+		t.Errorf("issuerURL() with a custom public port = %q, want %q", got, want)
+	}
+	c := &authOperator{routeCfg: cfg}
+	if got, want := c.issuerURL(route), cfg.issuerURL(route); got != want {
+		t.Errorf("operator issuerURL() = %q, want the configured %q", got, want)
+	}
+	if metadata := c.expectedMetadataStruct(route)["issuer"]; metadata != cfg.issuerURL(route) {
+		t.Errorf("expected metadata issuer = %v, want %q", metadata, cfg.issuerURL(route))
+	}
+
+	// a partial downstream override without a public port keeps the default
+	if got, want := (routeConfig{publicPort: 0}).issuerURL(route), "https://oauth-openshift.apps.example.com"; got != want {
+		t.Errorf("issuerURL() with a zero public port = %q, want %q", got, want)
+	}
+}
+
+func TestRouteCorrectionCounting(t *testing.T) {
+	ingress := testIngressConfig("apps.example.com")
+	stripped := testAdmittedRoute(ingress)
+	stripped.Spec.TLS = nil
+
+	before := testutil.ToFloat64(routeCorrectionsTotal)
+	c := &authOperator{
+		route:    routefake.NewSimpleClientset(stripped).RouteV1().Routes(targetNamespace),
+		secrets:  kubefake.NewSimpleClientset(testRouterSecret("apps.example.com")).CoreV1(),
+		recorder: events.NewInMemoryRecorder("test"),
+	}
+
+	// healing the stripped TLS block counts as one correction
+	if _, _, err := c.handleRoute(ingress); err != nil {
+		t.Fatalf("handleRoute() error = %v", err)
+	}
+	if c.routeCorrections != 1 {
+		t.Errorf("routeCorrections = %d, want 1", c.routeCorrections)
+	}
+	if got := testutil.ToFloat64(routeCorrectionsTotal) - before; got != 1 {
+		t.Errorf("route corrections metric moved by %v, want 1", got)
+	}
+
+	// a sync with nothing to heal counts nothing
+	if _, _, err := c.handleRoute(ingress); err != nil {
+		t.Fatalf("handleRoute() error = %v", err)
+	}
+	if c.routeCorrections != 1 {
+		t.Errorf("routeCorrections = %d after a clean sync, want 1", c.routeCorrections)
+	}
+}
+
+func TestHandleRouteStuckTerminating(t *testing.T) {
+	ingress := testIngressConfig("apps.example.com")
+	terminating := testAdmittedRoute(ingress)
+	deleted := metav1.NewTime(time.Date(2019, time.May, 1, 12, 0, 0, 0, time.UTC))
+	terminating.DeletionTimestamp = &deleted
+	terminating.Finalizers = []string{"example.com/some-controller"}
+
+	c := &authOperator{
+		route:    routefake.NewSimpleClientset(terminating).RouteV1().Routes(targetNamespace),
+		recorder: events.NewInMemoryRecorder("test"),
+		clock:    func() time.Time { return deleted.Add(3 * time.Minute) },
+	}
+	_, _, err := c.handleRoute(ingress)
+	stuck, ok := err.(*routeStuckTerminatingError)
+	if !ok {
+		t.Fatalf("expected a routeStuckTerminatingError, got %v", err)
+	}
+	for _, want := range []string{"terminating for 3m0s", "example.com/some-controller"} {
+		if !strings.Contains(stuck.Error(), want) {
+			t.Errorf("expected the message to contain %q, got %q", want, stuck.Error())
+		}
+	}
+
+	// without finalizers the message says deletion is expected to finish on
+	// its own rather than pointing at a holder
+	terminating.Finalizers = nil
+	if msg := routeStuckTerminatingMessage(terminating, deleted.Add(time.Minute)); !strings.Contains(msg, "no finalizers") {
+		t.Errorf("expected the message to note the absent finalizers, got %q", msg)
+	}
+}
+
+func TestGetCanonicalHostCaseInsensitive(t *testing.T) {
+	host := "oauth-openshift.apps.example.com"
+	admitted := func(reportedHost string) *routev1.Route {
+		return &routev1.Route{
+			Status: routev1.RouteStatus{
+				Ingress: []routev1.RouteIngress{
+					{
+						Host: reportedHost,
+						Conditions: []routev1.RouteIngressCondition{
+							{Type: routev1.RouteAdmitted, Status: corev1.ConditionTrue},
+						},
+					},
+				},
+			},
+		}
+	}
+
+	// hostnames are case-insensitive in DNS - a router reporting different
+	// casing still counts as admission at the canonical host
+	for _, reported := range []string{host, "OAuth-OpenShift.Apps.Example.COM"} {
+		if got := getCanonicalHost(admitted(reported), host); got != host {
+			t.Errorf("getCanonicalHost() with reported host %q = %q, want %q", reported, got, host)
+		}
+	}
+
+	// an actually different host still does not match
+	if got := getCanonicalHost(admitted("other.apps.example.com"), host); got != "" {
+		t.Errorf("expected no canonical host for a foreign admission, got %q", got)
+	}
+
+	// the same leniency applies when listing hosts other than the expected one
+	if hosts := admittedHostsOtherThan(admitted("OAUTH-OPENSHIFT.apps.example.com"), host); len(hosts) != 0 {
+		t.Errorf("expected a case-differing admission not to count as another host, got %v", hosts)
+	}
+}
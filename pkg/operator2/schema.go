@@ -0,0 +1,186 @@
+package operator2
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/library-go/pkg/operator/v1helpers"
+)
+
+// metadataSchemaKey is the ConfigMap key the downstream-provided JSON schema
+// is read from.
+const metadataSchemaKey = "schema.json"
+
+// handleMetadataSchema validates the last served well-known document against
+// a JSON schema a distribution provides via a ConfigMap, so downstreams can
+// enforce their own metadata contract on top of the operator's field-by-field
+// comparison.  the check reuses the document cached from the last probe - see
+// recordServedIssuer - and adds no probe traffic of its own.  when no schema
+// ConfigMap is configured the check does not run and the existing comparison
+// stands alone.
+func (c *authOperator) handleMetadataSchema(operatorConfig *operatorv1.Authentication) {
+	if len(c.metadataSchemaConfigMap) == 0 {
+		return
+	}
+
+	condition := operatorv1.OperatorCondition{
+		Type:   "MetadataSchema",
+		Status: operatorv1.ConditionFalse,
+		Reason: "AsExpected",
+	}
+	defer func() {
+		v1helpers.SetOperatorCondition(&operatorConfig.Status.Conditions, condition)
+	}()
+
+	configMap, err := c.configMaps.ConfigMaps(userConfigNamespace).Get(c.metadataSchemaConfigMap, metav1.GetOptions{})
+	if err != nil {
+		condition.Status = operatorv1.ConditionTrue
+		condition.Reason = "SchemaUnreadable"
+		condition.Message = fmt.Sprintf("failed to get the metadata schema config map %s/%s: %v", userConfigNamespace, c.metadataSchemaConfigMap, err)
+		return
+	}
+	var schema map[string]interface{}
+	if err := json.Unmarshal([]byte(configMap.Data[metadataSchemaKey]), &schema); err != nil {
+		condition.Status = operatorv1.ConditionTrue
+		condition.Reason = "SchemaUnreadable"
+		condition.Message = fmt.Sprintf("the metadata schema config map %s/%s does not carry valid JSON at key %s: %v", userConfigNamespace, c.metadataSchemaConfigMap, metadataSchemaKey, err)
+		return
+	}
+
+	// before the first successful probe there is no document to judge
+	doc := c.servedMetadataDoc()
+	if doc == nil {
+		return
+	}
+
+	if violations := schemaViolations(schema, doc, "$"); len(violations) > 0 {
+		condition.Status = operatorv1.ConditionTrue
+		condition.Reason = "SchemaViolation"
+		condition.Message = fmt.Sprintf("the served well-known document violates the configured metadata schema: %s", strings.Join(violations, "; "))
+	}
+}
+
+// schemaViolations checks the document against the subset of JSON Schema the
+// operator supports - type, required, properties, items, minItems and enum -
+// and describes every violation with its path.  full draft support would drag
+// in a dependency; this subset covers what metadata contracts actually use.
+func schemaViolations(schema map[string]interface{}, doc interface{}, path string) []string {
+	var violations []string
+
+	if typeName, ok := schema["type"].(string); ok && !schemaTypeMatches(typeName, doc) {
+		// the remaining keywords assume the right shape - stop here
+		return []string{fmt.Sprintf("%s: expected type %s, got %s", path, typeName, schemaTypeName(doc))}
+	}
+
+	if enum, ok := schema["enum"].([]interface{}); ok {
+		allowed := false
+		for _, value := range enum {
+			if reflect.DeepEqual(value, doc) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			violations = append(violations, fmt.Sprintf("%s: value %v is not among the allowed enum values", path, doc))
+		}
+	}
+
+	if object, ok := doc.(map[string]interface{}); ok {
+		if required, ok := schema["required"].([]interface{}); ok {
+			for _, field := range required {
+				name, ok := field.(string)
+				if !ok {
+					continue
+				}
+				if _, present := object[name]; !present {
+					violations = append(violations, fmt.Sprintf("%s: required field %q is missing", path, name))
+				}
+			}
+		}
+		if properties, ok := schema["properties"].(map[string]interface{}); ok {
+			// walk the properties in a stable order so the message does not
+			// churn between syncs
+			names := make([]string, 0, len(properties))
+			for name := range properties {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				propertySchema, ok := properties[name].(map[string]interface{})
+				if !ok {
+					continue
+				}
+				if value, present := object[name]; present {
+					violations = append(violations, schemaViolations(propertySchema, value, path+"."+name)...)
+				}
+			}
+		}
+	}
+
+	if array, ok := doc.([]interface{}); ok {
+		if minItems, ok := schema["minItems"].(float64); ok && len(array) < int(minItems) {
+			violations = append(violations, fmt.Sprintf("%s: expected at least %d items, got %d", path, int(minItems), len(array)))
+		}
+		if itemSchema, ok := schema["items"].(map[string]interface{}); ok {
+			for i, item := range array {
+				violations = append(violations, schemaViolations(itemSchema, item, fmt.Sprintf("%s[%d]", path, i))...)
+			}
+		}
+	}
+
+	return violations
+}
+
+// schemaTypeMatches reports whether the decoded JSON value has the named
+// schema type.  an unknown type name matches everything rather than failing
+// the whole document over a keyword this subset does not know.
+func schemaTypeMatches(typeName string, value interface{}) bool {
+	switch typeName {
+	case "object":
+		_, ok := value.(map[string]interface{})
+		return ok
+	case "array":
+		_, ok := value.([]interface{})
+		return ok
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "number":
+		_, ok := value.(float64)
+		return ok
+	case "integer":
+		number, ok := value.(float64)
+		return ok && number == float64(int64(number))
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "null":
+		return value == nil
+	}
+	return true
+}
+
+// schemaTypeName names the decoded JSON value's type for violation messages.
+func schemaTypeName(value interface{}) string {
+	switch value.(type) {
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	case nil:
+		return "null"
+	}
+	return fmt.Sprintf("%T", value)
+}
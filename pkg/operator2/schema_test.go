@@ -0,0 +1,157 @@
+package operator2
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/library-go/pkg/operator/v1helpers"
+)
+
+func TestSchemaViolations(t *testing.T) {
+	parse := func(t *testing.T, data string) map[string]interface{} {
+		var out map[string]interface{}
+		if err := json.Unmarshal([]byte(data), &out); err != nil {
+			t.Fatalf("failed to parse %q: %v", data, err)
+		}
+		return out
+	}
+
+	tests := []struct {
+		name   string
+		schema string
+		doc    string
+		want   []string
+	}{
+		{
+			name:   "conforming document",
+			schema: `{"type": "object", "required": ["issuer"], "properties": {"issuer": {"type": "string"}}}`,
+			doc:    `{"issuer": "https://oauth-openshift.apps.example.com"}`,
+			want:   nil,
+		},
+		{
+			name:   "top level type mismatch stops there",
+			schema: `{"type": "array"}`,
+			doc:    `{"issuer": "x"}`,
+			want:   []string{"$: expected type array, got object"},
+		},
+		{
+			name:   "required field missing",
+			schema: `{"required": ["issuer", "token_endpoint"]}`,
+			doc:    `{"issuer": "x"}`,
+			want:   []string{`$: required field "token_endpoint" is missing`},
+		},
+		{
+			name:   "nested property type mismatch",
+			schema: `{"properties": {"scopes_supported": {"type": "array", "items": {"type": "string"}}}}`,
+			doc:    `{"scopes_supported": ["user:full", 42]}`,
+			want:   []string{"$.scopes_supported[1]: expected type string, got number"},
+		},
+		{
+			name:   "too few array items",
+			schema: `{"properties": {"scopes_supported": {"minItems": 3}}}`,
+			doc:    `{"scopes_supported": ["user:full"]}`,
+			want:   []string{"$.scopes_supported: expected at least 3 items, got 1"},
+		},
+		{
+			name:   "value outside the enum",
+			schema: `{"properties": {"code_challenge_methods_supported": {"items": {"enum": ["plain", "S256"]}}}}`,
+			doc:    `{"code_challenge_methods_supported": ["S256", "md5"]}`,
+			want:   []string{"$.code_challenge_methods_supported[1]: value md5 is not among the allowed enum values"},
+		},
+		{
+			name:   "integer rejects a fraction",
+			schema: `{"properties": {"count": {"type": "integer"}}}`,
+			doc:    `{"count": 1.5}`,
+			want:   []string{"$.count: expected type integer, got number"},
+		},
+		{
+			name:   "unknown type name matches everything",
+			schema: `{"properties": {"issuer": {"type": "uri"}}}`,
+			doc:    `{"issuer": "x"}`,
+			want:   nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var doc interface{} = parse(t, tt.doc)
+			got := schemaViolations(parse(t, tt.schema), doc, "$")
+			if len(got) != len(tt.want) {
+				t.Fatalf("violations = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("violation[%d] = %q, want %q", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestHandleMetadataSchema(t *testing.T) {
+	route := testRoute("oauth-openshift.apps.example.com")
+	operatorConfig := &operatorv1.Authentication{}
+
+	condition := func() *operatorv1.OperatorCondition {
+		return v1helpers.FindOperatorCondition(operatorConfig.Status.Conditions, "MetadataSchema")
+	}
+	schemaConfigMap := func(schema string) *corev1.ConfigMap {
+		return &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "metadata-schema", Namespace: userConfigNamespace},
+			Data:       map[string]string{metadataSchemaKey: schema},
+		}
+	}
+
+	// with no schema configured the check never runs and sets no condition
+	c := &authOperator{}
+	c.handleMetadataSchema(operatorConfig)
+	if got := condition(); got != nil {
+		t.Errorf("expected no condition without a configured schema, got %#v", got)
+	}
+
+	// a configured but missing ConfigMap is its own reason
+	c.metadataSchemaConfigMap = "metadata-schema"
+	c.configMaps = kubefake.NewSimpleClientset().CoreV1()
+	c.handleMetadataSchema(operatorConfig)
+	if got := condition(); got == nil || got.Status != operatorv1.ConditionTrue || got.Reason != "SchemaUnreadable" {
+		t.Errorf("expected a true SchemaUnreadable condition for a missing config map, got %#v", got)
+	}
+
+	// so is a ConfigMap that does not hold valid JSON
+	c.configMaps = kubefake.NewSimpleClientset(schemaConfigMap("{not json")).CoreV1()
+	c.handleMetadataSchema(operatorConfig)
+	if got := condition(); got == nil || got.Status != operatorv1.ConditionTrue || got.Reason != "SchemaUnreadable" {
+		t.Errorf("expected a true SchemaUnreadable condition for invalid schema JSON, got %#v", got)
+	}
+
+	// before the first probe there is no served document to judge
+	c.configMaps = kubefake.NewSimpleClientset(schemaConfigMap(`{"type": "object", "required": ["issuer"]}`)).CoreV1()
+	c.handleMetadataSchema(operatorConfig)
+	if got := condition(); got == nil || got.Status != operatorv1.ConditionFalse {
+		t.Errorf("expected a false condition before the first served document, got %#v", got)
+	}
+
+	// a conforming served document keeps the condition clear
+	c.recordServedIssuer(getMetadataStruct(route))
+	c.handleMetadataSchema(operatorConfig)
+	if got := condition(); got == nil || got.Status != operatorv1.ConditionFalse {
+		t.Errorf("expected a false condition for a conforming document, got %#v", got)
+	}
+
+	// a violated contract names the violation and its path
+	c.configMaps = kubefake.NewSimpleClientset(schemaConfigMap(`{"type": "object", "required": ["downstream_extension"]}`)).CoreV1()
+	c.handleMetadataSchema(operatorConfig)
+	got := condition()
+	if got == nil || got.Status != operatorv1.ConditionTrue || got.Reason != "SchemaViolation" {
+		t.Fatalf("expected a true SchemaViolation condition, got %#v", got)
+	}
+	if !strings.Contains(got.Message, `required field "downstream_extension" is missing`) {
+		t.Errorf("expected the message to name the violation, got %q", got.Message)
+	}
+}
@@ -1,7 +1,13 @@
 package operator2
 
 import (
+	"encoding/json"
+	"fmt"
 	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog"
 
 	operatorv1 "github.com/openshift/api/operator/v1"
 	"github.com/openshift/library-go/pkg/operator/v1helpers"
@@ -25,6 +31,82 @@ func handleDegraded(operatorConfig *operatorv1.Authentication, prefix string, er
 		})
 }
 
+// defaultDegradedGraceCount is how many consecutive failures of the same check
+// are tolerated before its Degraded condition goes true.  a single transient
+// API hiccup should not flip the whole operator degraded.
+const defaultDegradedGraceCount = 3
+
+// handleDegradedWithGrace behaves like handleDegraded but tolerates transient
+// errors: the Degraded condition for prefix only goes true after the check has
+// failed enough syncs in a row, and any success resets the count.  it reports
+// whether the error is still within the grace window so the caller can surface
+// it as progressing instead of failing the sync.
+func (c *authOperator) handleDegradedWithGrace(operatorConfig *operatorv1.Authentication, prefix string, err error) bool {
+	c.failureCountLock.Lock()
+	if err == nil {
+		delete(c.consecutiveFailures, prefix)
+	} else {
+		if c.consecutiveFailures == nil {
+			c.consecutiveFailures = map[string]int{}
+		}
+		c.consecutiveFailures[prefix]++
+	}
+	failures := c.consecutiveFailures[prefix]
+	c.failureCountLock.Unlock()
+
+	threshold := c.degradedGraceCountOrDefault()
+	if err != nil && failures < threshold {
+		klog.V(2).Infof("tolerating %s failure %d of %d before degrading: %v", prefix, failures, threshold, err)
+		handleDegraded(operatorConfig, prefix, nil)
+		return true
+	}
+	handleDegraded(operatorConfig, prefix, err)
+	return false
+}
+
+// mirrorWellKnownConditions copies the conditions owned by the well-known
+// readiness checks onto the configured annotation of the operator config as a
+// JSON array, so tooling that does not understand operator status semantics
+// can consume readiness from a single metadata key.  the mirror is opt-in via
+// mirrorConditionsAnnotation, follows the authoritative conditions, and only
+// issues a write when the rendered value changed so it adds no steady-state
+// churn.
+func (c *authOperator) mirrorWellKnownConditions() error {
+	if len(c.mirrorConditionsAnnotation) == 0 {
+		return nil
+	}
+
+	// read back the freshly updated config so the mirror reflects the
+	// authoritative conditions, not the in-flight copy
+	operatorConfig, err := c.authOperatorConfigClient.Client.Authentications().Get(globalConfigName, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+
+	mirrored := make([]operatorv1.OperatorCondition, 0)
+	for _, condition := range operatorConfig.Status.Conditions {
+		if strings.HasPrefix(condition.Type, c.wellKnownPrefix()) {
+			mirrored = append(mirrored, condition)
+		}
+	}
+	value, err := json.Marshal(mirrored)
+	if err != nil {
+		return err
+	}
+
+	if operatorConfig.Annotations[c.mirrorConditionsAnnotation] == string(value) {
+		return nil
+	}
+
+	operatorConfigCopy := operatorConfig.DeepCopy()
+	if operatorConfigCopy.Annotations == nil {
+		operatorConfigCopy.Annotations = map[string]string{}
+	}
+	operatorConfigCopy.Annotations[c.mirrorConditionsAnnotation] = string(value)
+	_, err = c.authOperatorConfigClient.Client.Authentications().Update(operatorConfigCopy)
+	return err
+}
+
 func isDegraded(operatorConfig *operatorv1.Authentication) bool {
 	for _, condition := range operatorConfig.Status.Conditions {
 		if strings.HasSuffix(condition.Type, operatorv1.OperatorStatusTypeDegraded) &&
@@ -35,6 +117,41 @@ func isDegraded(operatorConfig *operatorv1.Authentication) bool {
 	return false
 }
 
+// HealthSummary is the coarse answer to "is the OAuth well-known path healthy
+// right now" derived from the operator conditions.
+type HealthSummary string
+
+const (
+	// HealthSummaryHealthy means nothing is degraded or progressing.
+	HealthSummaryHealthy HealthSummary = "Healthy"
+	// HealthSummaryProgressing means the operator is still working towards
+	// the desired state but nothing has failed.
+	HealthSummaryProgressing HealthSummary = "Progressing"
+	// HealthSummaryDegraded means at least one *Degraded condition is true.
+	HealthSummaryDegraded HealthSummary = "Degraded"
+)
+
+// SummarizeHealth interprets the conditions owned by this operator into a
+// single coarse state plus the most relevant message, so consumers (CLI,
+// status UIs) do not have to re-implement the semantics of the condition set.
+// degraded wins over progressing, and the message comes from the first true
+// condition of the winning severity.
+func SummarizeHealth(conditions []operatorv1.OperatorCondition) (HealthSummary, string) {
+	for _, condition := range conditions {
+		if strings.HasSuffix(condition.Type, operatorv1.OperatorStatusTypeDegraded) &&
+			condition.Status == operatorv1.ConditionTrue {
+			return HealthSummaryDegraded, condition.Message
+		}
+	}
+	for _, condition := range conditions {
+		if strings.HasSuffix(condition.Type, operatorv1.OperatorStatusTypeProgressing) &&
+			condition.Status == operatorv1.ConditionTrue {
+			return HealthSummaryProgressing, condition.Message
+		}
+	}
+	return HealthSummaryHealthy, ""
+}
+
 func setProgressingTrue(operatorConfig *operatorv1.Authentication, reason, message string) {
 	v1helpers.SetOperatorCondition(&operatorConfig.Status.Conditions, operatorv1.OperatorCondition{
 		Type:    operatorv1.OperatorStatusTypeProgressing,
@@ -45,10 +162,15 @@ func setProgressingTrue(operatorConfig *operatorv1.Authentication, reason, messa
 }
 
 func setAvailableTrue(operatorConfig *operatorv1.Authentication, reason string) {
+	setAvailableTrueWithMessage(operatorConfig, reason, "")
+}
+
+func setAvailableTrueWithMessage(operatorConfig *operatorv1.Authentication, reason, message string) {
 	v1helpers.SetOperatorCondition(&operatorConfig.Status.Conditions, operatorv1.OperatorCondition{
-		Type:   operatorv1.OperatorStatusTypeAvailable,
-		Status: operatorv1.ConditionTrue,
-		Reason: reason,
+		Type:    operatorv1.OperatorStatusTypeAvailable,
+		Status:  operatorv1.ConditionTrue,
+		Reason:  reason,
+		Message: message,
 	})
 }
 
@@ -67,3 +189,26 @@ func setProgressingTrueAndAvailableFalse(operatorConfig *operatorv1.Authenticati
 		Status: operatorv1.ConditionFalse,
 	})
 }
+
+// setProgressingTrueAndAvailableFalse additionally decorates the message with
+// how long the operator has already been progressing, so `oc get co` readers
+// can tell a momentary blip from a sustained problem at a glance.
+func (c *authOperator) setProgressingTrueAndAvailableFalse(operatorConfig *operatorv1.Authentication, reason, message string) {
+	setProgressingTrueAndAvailableFalse(operatorConfig, reason, appendFailureDuration(operatorConfig.Status.Conditions, operatorv1.OperatorStatusTypeProgressing, message, c.now()))
+}
+
+// appendFailureDuration appends how long conditionType has already been true,
+// derived from its persisted LastTransitionTime so the value is correct across
+// operator restarts.  the duration is rounded coarsely to keep the message
+// from churning every sync.
+func appendFailureDuration(conditions []operatorv1.OperatorCondition, conditionType string, message string, now time.Time) string {
+	condition := v1helpers.FindOperatorCondition(conditions, conditionType)
+	if condition == nil || condition.Status != operatorv1.ConditionTrue || condition.LastTransitionTime.IsZero() {
+		return message
+	}
+	failing := now.Sub(condition.LastTransitionTime.Time).Round(30 * time.Second)
+	if failing <= 0 {
+		return message
+	}
+	return fmt.Sprintf("%s (not ready for %s)", message, failing)
+}
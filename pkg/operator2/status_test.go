@@ -0,0 +1,183 @@
+package operator2
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+	operatorfake "github.com/openshift/client-go/operator/clientset/versioned/fake"
+)
+
+func TestAppendFailureDuration(t *testing.T) {
+	now := time.Date(2019, time.April, 1, 12, 0, 0, 0, time.UTC)
+
+	conditions := []operatorv1.OperatorCondition{
+		{
+			Type:               operatorv1.OperatorStatusTypeProgressing,
+			Status:             operatorv1.ConditionTrue,
+			LastTransitionTime: metav1.NewTime(now.Add(-270 * time.Second)),
+		},
+	}
+
+	if got, want := appendFailureDuration(conditions, operatorv1.OperatorStatusTypeProgressing, "well-known not ready", now), "well-known not ready (not ready for 4m30s)"; got != want {
+		t.Errorf("appendFailureDuration() = %q, want %q", got, want)
+	}
+
+	// a condition that just flipped or is false adds no noise
+	conditions[0].Status = operatorv1.ConditionFalse
+	if got := appendFailureDuration(conditions, operatorv1.OperatorStatusTypeProgressing, "msg", now); got != "msg" {
+		t.Errorf("expected the message unchanged for a false condition, got %q", got)
+	}
+	if got := appendFailureDuration(nil, operatorv1.OperatorStatusTypeProgressing, "msg", now); got != "msg" {
+		t.Errorf("expected the message unchanged without conditions, got %q", got)
+	}
+}
+
+func TestHandleDegradedWithGrace(t *testing.T) {
+	c := &authOperator{degradedGraceCount: 2}
+
+	degraded := func(operatorConfig *operatorv1.Authentication) bool {
+		return isDegraded(operatorConfig)
+	}
+
+	// a single transient failure stays non-degraded
+	operatorConfig := &operatorv1.Authentication{}
+	if !c.handleDegradedWithGrace(operatorConfig, "RouteStatus", fmt.Errorf("transient hiccup")) {
+		t.Error("expected the first failure to be within the grace window")
+	}
+	if degraded(operatorConfig) {
+		t.Error("a single transient failure must not degrade the operator")
+	}
+
+	// repeated failures cross the threshold and degrade
+	operatorConfig = &operatorv1.Authentication{}
+	if c.handleDegradedWithGrace(operatorConfig, "RouteStatus", fmt.Errorf("still broken")) {
+		t.Error("expected the second consecutive failure to escalate")
+	}
+	if !degraded(operatorConfig) {
+		t.Error("repeated failures must degrade the operator")
+	}
+
+	// a success resets the count, so the next failure gets a fresh window
+	operatorConfig = &operatorv1.Authentication{}
+	c.handleDegradedWithGrace(operatorConfig, "RouteStatus", nil)
+	if degraded(operatorConfig) {
+		t.Error("a success must clear the degraded condition")
+	}
+	operatorConfig = &operatorv1.Authentication{}
+	if !c.handleDegradedWithGrace(operatorConfig, "RouteStatus", fmt.Errorf("new hiccup")) {
+		t.Error("expected a fresh grace window after a success")
+	}
+
+	// counts are tracked per prefix
+	operatorConfig = &operatorv1.Authentication{}
+	if !c.handleDegradedWithGrace(operatorConfig, "AuthConfigStatus", fmt.Errorf("unrelated hiccup")) {
+		t.Error("expected an independent grace window per prefix")
+	}
+}
+
+func TestMirrorWellKnownConditions(t *testing.T) {
+	const annotation = "authentication.operator.openshift.io/wellknown-conditions"
+
+	operatorConfig := &operatorv1.Authentication{
+		ObjectMeta: metav1.ObjectMeta{Name: globalConfigName},
+		Status: operatorv1.AuthenticationStatus{
+			OperatorStatus: operatorv1.OperatorStatus{
+				Conditions: []operatorv1.OperatorCondition{
+					{Type: "WellKnownEndpointDegraded", Status: operatorv1.ConditionFalse},
+					{Type: "RouteHealthDegraded", Status: operatorv1.ConditionFalse},
+				},
+			},
+		},
+	}
+	fakeClient := operatorfake.NewSimpleClientset(operatorConfig)
+	c := &authOperator{
+		authOperatorConfigClient:   OperatorClient{Client: fakeClient.OperatorV1()},
+		mirrorConditionsAnnotation: annotation,
+	}
+
+	// the mirror is opt-in - a bare operator never writes
+	bare := &authOperator{authOperatorConfigClient: OperatorClient{Client: fakeClient.OperatorV1()}}
+	if err := bare.mirrorWellKnownConditions(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(fakeClient.Actions()) != 0 {
+		t.Errorf("expected no API calls without an annotation configured, got %d", len(fakeClient.Actions()))
+	}
+
+	if err := c.mirrorWellKnownConditions(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	mirrored, err := fakeClient.OperatorV1().Authentications().Get(globalConfigName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	value := mirrored.Annotations[annotation]
+	if !strings.Contains(value, "WellKnownEndpointDegraded") {
+		t.Errorf("expected the well-known condition in the mirror, got %q", value)
+	}
+	if strings.Contains(value, "RouteHealthDegraded") {
+		t.Errorf("conditions not owned by the well-known checks must not be mirrored, got %q", value)
+	}
+
+	// an unchanged condition set must not issue another write
+	updates := func() int {
+		count := 0
+		for _, action := range fakeClient.Actions() {
+			if action.GetVerb() == "update" {
+				count++
+			}
+		}
+		return count
+	}
+	before := updates()
+	if err := c.mirrorWellKnownConditions(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if updates() != before {
+		t.Error("expected no write churn for an unchanged condition set")
+	}
+}
+
+func TestSummarizeHealth(t *testing.T) {
+	degraded := operatorv1.OperatorCondition{
+		Type:    "WellKnownEndpointDegraded",
+		Status:  operatorv1.ConditionTrue,
+		Message: "endpoint is broken",
+	}
+	progressing := operatorv1.OperatorCondition{
+		Type:    operatorv1.OperatorStatusTypeProgressing,
+		Status:  operatorv1.ConditionTrue,
+		Message: "waiting for endpoints",
+	}
+	available := operatorv1.OperatorCondition{
+		Type:   operatorv1.OperatorStatusTypeAvailable,
+		Status: operatorv1.ConditionTrue,
+	}
+
+	for _, tt := range []struct {
+		name        string
+		conditions  []operatorv1.OperatorCondition
+		wantSummary HealthSummary
+		wantMessage string
+	}{
+		{name: "no conditions", wantSummary: HealthSummaryHealthy},
+		{name: "available only", conditions: []operatorv1.OperatorCondition{available}, wantSummary: HealthSummaryHealthy},
+		{name: "progressing", conditions: []operatorv1.OperatorCondition{available, progressing}, wantSummary: HealthSummaryProgressing, wantMessage: "waiting for endpoints"},
+		{name: "degraded wins over progressing", conditions: []operatorv1.OperatorCondition{progressing, degraded}, wantSummary: HealthSummaryDegraded, wantMessage: "endpoint is broken"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			summary, message := SummarizeHealth(tt.conditions)
+			if summary != tt.wantSummary {
+				t.Errorf("SummarizeHealth() = %q, want %q", summary, tt.wantSummary)
+			}
+			if message != tt.wantMessage {
+				t.Errorf("message = %q, want %q", message, tt.wantMessage)
+			}
+		})
+	}
+}
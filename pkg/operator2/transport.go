@@ -5,6 +5,7 @@ import (
 	"crypto/x509"
 	"errors"
 	"net/http"
+	"time"
 
 	"k8s.io/apimachinery/pkg/util/net"
 	ktransport "k8s.io/client-go/transport"
@@ -21,6 +22,25 @@ func transportFor(serverName string, caData, certData, keyData []byte) (http.Rou
 	return ktransport.DebugWrappers(transport), nil
 }
 
+// pooledTransportFor is transportFor with an explicit connection pool size so
+// frequent probes against the same hosts can reuse connections across
+// resyncs.  it additionally returns the underlying transport so the caller
+// can release idle connections when it stops using the pool.
+func pooledTransportFor(serverName string, caData, certData, keyData []byte, maxIdleConnsPerHost int) (http.RoundTripper, *http.Transport, error) {
+	rt, err := transportForInner(serverName, caData, certData, keyData)
+	if err != nil {
+		return nil, nil, err
+	}
+	transport, ok := rt.(*http.Transport)
+	if !ok {
+		// only http.DefaultTransport takes this path - do not mutate shared state
+		return ktransport.DebugWrappers(rt), nil, nil
+	}
+	transport.MaxIdleConnsPerHost = maxIdleConnsPerHost
+	transport.IdleConnTimeout = 90 * time.Second
+	return ktransport.DebugWrappers(transport), transport, nil
+}
+
 func transportForInner(serverName string, caData, certData, keyData []byte) (http.RoundTripper, error) {
 	if len(caData) == 0 && len(certData) == 0 && len(keyData) == 0 {
 		return http.DefaultTransport, nil
@@ -0,0 +1,274 @@
+package operator2
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestProbeTransportCaching(t *testing.T) {
+	now := time.Now()
+	ca := newTestCA(t, "test-ca", now.Add(-time.Hour), now.Add(time.Hour))
+	otherCA := newTestCA(t, "other-ca", now.Add(-time.Hour), now.Add(time.Hour))
+
+	c := &authOperator{}
+
+	first, err := c.probeTransportFor(kasServiceFullName, ca.certPEM)
+	if err != nil {
+		t.Fatalf("failed to build transport: %v", err)
+	}
+	again, err := c.probeTransportFor(kasServiceFullName, ca.certPEM)
+	if err != nil {
+		t.Fatalf("failed to build transport: %v", err)
+	}
+	if first != again {
+		t.Error("expected the transport to be reused while the CA is unchanged")
+	}
+
+	// a CA rotation must rebuild the transport
+	rotated, err := c.probeTransportFor(kasServiceFullName, otherCA.certPEM)
+	if err != nil {
+		t.Fatalf("failed to build transport: %v", err)
+	}
+	if rotated == first {
+		t.Error("expected a new transport after the CA changed")
+	}
+
+	// the pool size override reaches the underlying transport
+	sized := &authOperator{probeMaxIdleConnsPerHost: 7}
+	if _, err := sized.probeTransportFor(kasServiceFullName, ca.certPEM); err != nil {
+		t.Fatalf("failed to build transport: %v", err)
+	}
+	if sized.probeTransportInner == nil || sized.probeTransportInner.MaxIdleConnsPerHost != 7 {
+		t.Errorf("expected MaxIdleConnsPerHost = 7, got %#v", sized.probeTransportInner)
+	}
+}
+
+func TestProbeTransportTLSPolicy(t *testing.T) {
+	now := time.Now()
+	ca := newTestCA(t, "test-ca", now.Add(-time.Hour), now.Add(time.Hour))
+
+	// the default policy refuses anything below TLS 1.2
+	c := &authOperator{}
+	if _, err := c.probeTransportFor(kasServiceFullName, ca.certPEM); err != nil {
+		t.Fatalf("failed to build transport: %v", err)
+	}
+	if got := c.probeTransportInner.TLSClientConfig.MinVersion; got != uint16(tls.VersionTLS12) {
+		t.Errorf("MinVersion = %#x, want TLS 1.2", got)
+	}
+
+	// hardened clusters can raise the minimum and pin cipher suites
+	hardened := &authOperator{
+		probeMinTLSVersion: tls.VersionTLS13,
+		probeCipherSuites:  []uint16{tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384},
+	}
+	if _, err := hardened.probeTransportFor(kasServiceFullName, ca.certPEM); err != nil {
+		t.Fatalf("failed to build transport: %v", err)
+	}
+	if got := hardened.probeTransportInner.TLSClientConfig.MinVersion; got != uint16(tls.VersionTLS13) {
+		t.Errorf("MinVersion = %#x, want TLS 1.3", got)
+	}
+	if suites := hardened.probeTransportInner.TLSClientConfig.CipherSuites; len(suites) != 1 || suites[0] != tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384 {
+		t.Errorf("CipherSuites = %#x, want the pinned suite", suites)
+	}
+}
+
+func TestProbeTransportSourceAddress(t *testing.T) {
+	now := time.Now()
+	ca := newTestCA(t, "test-ca", now.Add(-time.Hour), now.Add(time.Hour))
+
+	// the default is the standard dialer, no local address pinned
+	dialer, err := (&authOperator{}).probeDialer()
+	if err != nil {
+		t.Fatalf("failed to build default dialer: %v", err)
+	}
+	if dialer.LocalAddr != nil {
+		t.Errorf("expected no local address by default, got %v", dialer.LocalAddr)
+	}
+
+	// a configured source address is pinned as the dialer's local IP
+	pinned := &authOperator{probeSourceAddress: "127.0.0.1"}
+	dialer, err = pinned.probeDialer()
+	if err != nil {
+		t.Fatalf("failed to build pinned dialer: %v", err)
+	}
+	tcpAddr, ok := dialer.LocalAddr.(*net.TCPAddr)
+	if !ok || tcpAddr.IP.String() != "127.0.0.1" {
+		t.Errorf("expected local address 127.0.0.1, got %v", dialer.LocalAddr)
+	}
+	if tcpAddr != nil && tcpAddr.Port != 0 {
+		t.Errorf("expected an ephemeral source port, got %d", tcpAddr.Port)
+	}
+
+	// the pinned source must actually be usable end to end
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+	if _, err := pinned.probeTransportFor(kasServiceFullName, ca.certPEM); err != nil {
+		t.Fatalf("failed to build transport: %v", err)
+	}
+	conn, err := pinned.probeTransportInner.DialContext(context.Background(), "tcp", server.Listener.Addr().String())
+	if err != nil {
+		t.Fatalf("failed to dial with the pinned source address: %v", err)
+	}
+	local := conn.LocalAddr().(*net.TCPAddr)
+	conn.Close()
+	if local.IP.String() != "127.0.0.1" {
+		t.Errorf("expected the connection to originate from 127.0.0.1, got %v", local)
+	}
+
+	// garbage configuration fails loudly instead of probing from the wrong place
+	broken := &authOperator{probeSourceAddress: "not-an-ip"}
+	if _, err := broken.probeTransportFor(kasServiceFullName, ca.certPEM); err == nil {
+		t.Error("expected an error for a source address that is not an IP")
+	}
+}
+
+// TestProbeTLSIdentityByMode asserts that each probe mode presents the right
+// SNI name on the wire: the KAS service name when probing members directly,
+// the route host when probing end to end through the router.
+func TestProbeTLSIdentityByMode(t *testing.T) {
+	now := time.Now()
+	ca := newTestCA(t, "test-ca", now.Add(-time.Hour), now.Add(time.Hour))
+
+	routeHost := "oauth-openshift.apps.example.com"
+	ingress := testIngressConfig("apps.example.com")
+	route := testRoute(routeHost)
+	route.Spec.Host = routeHost
+	routerSecret := testRouterSecret("apps.example.com")
+	routerSecret.Data["apps.example.com"] = ca.certPEM
+
+	// the selection itself
+	serverName, trust, err := probeTLSIdentity(probeModeKAS, nil, ca.certPEM, nil, nil)
+	if err != nil || serverName != kasServiceFullName || !bytes.Equal(trust, ca.certPEM) {
+		t.Errorf("KAS mode identity = (%q, %d bytes, %v), want the service name and the SA CA", serverName, len(trust), err)
+	}
+	serverName, trust, err = probeTLSIdentity(probeModeRoute, route, nil, routerSecret, ingress)
+	if err != nil || serverName != routeHost || !bytes.Equal(trust, ca.certPEM) {
+		t.Errorf("route mode identity = (%q, %d bytes, %v), want the route host and the router CA", serverName, len(trust), err)
+	}
+	if _, _, err := probeTLSIdentity(probeModeRoute, testRoute(""), nil, routerSecret, ingress); err == nil {
+		t.Error("expected an error for route mode without an admitted host")
+	}
+
+	// the selected names actually reach the wire as SNI
+	serverCert, serverKey := ca.issue(t, "server", []string{kasServiceFullName, routeHost, "127.0.0.1"}, false, now.Add(-time.Hour), now.Add(time.Hour))
+	pair, err := tls.X509KeyPair(serverCert, serverKey)
+	if err != nil {
+		t.Fatalf("failed to load server pair: %v", err)
+	}
+	var lock sync.Mutex
+	var sniSeen []string
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	server.TLS = &tls.Config{
+		GetCertificate: func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+			lock.Lock()
+			sniSeen = append(sniSeen, hello.ServerName)
+			lock.Unlock()
+			return &pair, nil
+		},
+	}
+	server.StartTLS()
+	defer server.Close()
+
+	get := func(rt http.RoundTripper) {
+		req, err := http.NewRequest(http.MethodGet, "https://"+server.Listener.Addr().String(), nil)
+		if err != nil {
+			t.Fatalf("failed to build request: %v", err)
+		}
+		resp, err := rt.RoundTrip(req)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		resp.Body.Close()
+	}
+
+	c := &authOperator{}
+	kasRT, err := c.probeTransportFor(kasServiceFullName, ca.certPEM)
+	if err != nil {
+		t.Fatalf("failed to build KAS probe transport: %v", err)
+	}
+	get(kasRT)
+
+	routeRT, err := c.routeProbeTransport(route, routerSecret, ingress)
+	if err != nil {
+		t.Fatalf("failed to build route probe transport: %v", err)
+	}
+	get(routeRT)
+
+	lock.Lock()
+	defer lock.Unlock()
+	want := []string{kasServiceFullName, routeHost}
+	if !reflect.DeepEqual(sniSeen, want) {
+		t.Errorf("SNI seen on the wire = %v, want %v", sniSeen, want)
+	}
+}
+
+func TestTransportForClientCert(t *testing.T) {
+	now := time.Now()
+	ca := newTestCA(t, "test-ca", now.Add(-time.Hour), now.Add(time.Hour))
+	serverCert, serverKey := ca.issue(t, "server", []string{"127.0.0.1"}, false, now.Add(-time.Hour), now.Add(time.Hour))
+	clientCert, clientKey := ca.issue(t, "client", nil, true, now.Add(-time.Hour), now.Add(time.Hour))
+
+	serverPair, err := tls.X509KeyPair(serverCert, serverKey)
+	if err != nil {
+		t.Fatalf("failed to load server pair: %v", err)
+	}
+	clientCAs := x509.NewCertPool()
+	if !clientCAs.AppendCertsFromPEM(ca.certPEM) {
+		t.Fatal("failed to load client CA pool")
+	}
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	server.TLS = &tls.Config{
+		Certificates: []tls.Certificate{serverPair},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    clientCAs,
+	}
+	server.StartTLS()
+	defer server.Close()
+
+	get := func(rt http.RoundTripper) error {
+		req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+		if err != nil {
+			t.Fatalf("failed to build request: %v", err)
+		}
+		resp, err := rt.RoundTrip(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		return nil
+	}
+
+	// without a client cert the server must reject us
+	noCert, err := transportFor("127.0.0.1", ca.certPEM, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to build transport: %v", err)
+	}
+	if err := get(noCert); err == nil {
+		t.Error("expected the request without a client cert to fail")
+	}
+
+	// with a client cert the probe must authenticate
+	withCert, err := transportFor("127.0.0.1", ca.certPEM, clientCert, clientKey)
+	if err != nil {
+		t.Fatalf("failed to build mTLS transport: %v", err)
+	}
+	if err := get(withCert); err != nil {
+		t.Errorf("expected the mTLS request to succeed, got: %v", err)
+	}
+}
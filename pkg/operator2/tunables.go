@@ -0,0 +1,171 @@
+package operator2
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"k8s.io/klog"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+)
+
+// wellknownTunablesKey is the unsupportedConfigOverrides key the readiness
+// controller reads its timing tunables from, e.g.
+//
+//	unsupportedConfigOverrides:
+//	  wellknownReadiness:
+//	    probeIntervalSeconds: 60
+//	    degradedGraceCount: 5
+//	    memberBackoffBaseSeconds: 30
+//	    memberBackoffMaxSeconds: 600
+//	    probeBreakerThreshold: 10
+//
+// this lets SREs adjust the probe cadence and backoff on a struggling cluster
+// without waiting for a new operator image.  as with everything under
+// unsupportedConfigOverrides this is a support escape hatch, not a supported
+// configuration.
+const wellknownTunablesKey = "wellknownReadiness"
+
+// clamp bounds for the tunables.  values outside these ranges are almost
+// certainly typos (an interval of a day would mask outages, a backoff base of
+// zero would disable the backoff entirely) - they are clamped and the clamping
+// is logged rather than silently honored or silently dropped.
+const (
+	maxTunableProbeInterval      = time.Hour
+	maxTunableDegradedGraceCount = 20
+	minTunableBackoffBase        = time.Second
+	maxTunableBackoffMax         = time.Hour
+	maxTunableBreakerThreshold   = 100
+)
+
+// readinessTunables carries the field values the overrides feed, so they can
+// be captured before the first override and restored when an override goes
+// away again.
+type readinessTunables struct {
+	probeInterval         time.Duration
+	degradedGraceCount    int
+	memberBackoffBase     time.Duration
+	memberBackoffMax      time.Duration
+	probeBreakerThreshold int
+}
+
+// applyUnsupportedTunables wires the readiness controller's timing knobs to
+// the operator config's unsupportedConfigOverrides.  the overrides are
+// re-applied only when their raw bytes change, each value is validated and
+// clamped to a safe range, and the effective values are logged so a
+// must-gather shows what was actually in effect.  only called from the sync
+// loop, like every consumer of the fields it sets.
+func (c *authOperator) applyUnsupportedTunables(operatorConfig *operatorv1.Authentication) {
+	raw := string(operatorConfig.Spec.UnsupportedConfigOverrides.Raw)
+	if raw == c.lastTunablesRaw && c.baselineTunables != nil {
+		return
+	}
+	c.lastTunablesRaw = raw
+
+	// remember what the fields held before any override so a removed override
+	// falls back to the built-in (or constructor-provided) value
+	if c.baselineTunables == nil {
+		c.baselineTunables = &readinessTunables{
+			probeInterval:         c.probeInterval,
+			degradedGraceCount:    c.degradedGraceCount,
+			memberBackoffBase:     c.memberBackoffBase,
+			memberBackoffMax:      c.memberBackoffMax,
+			probeBreakerThreshold: c.probeBreakerThreshold,
+		}
+	}
+
+	tunables := map[string]interface{}{}
+	if len(raw) != 0 {
+		var overrides map[string]interface{}
+		if err := json.Unmarshal([]byte(raw), &overrides); err != nil {
+			klog.Warningf("ignoring unparseable unsupportedConfigOverrides: %v", err)
+		} else {
+			tunables, _ = overrides[wellknownTunablesKey].(map[string]interface{})
+		}
+	}
+
+	c.probeInterval = tunableDuration(tunables, "probeIntervalSeconds", c.baselineTunables.probeInterval, 0, maxTunableProbeInterval)
+	c.degradedGraceCount = tunableCount(tunables, "degradedGraceCount", c.baselineTunables.degradedGraceCount, 0, maxTunableDegradedGraceCount)
+	c.memberBackoffBase = tunableDuration(tunables, "memberBackoffBaseSeconds", c.baselineTunables.memberBackoffBase, minTunableBackoffBase, maxTunableBackoffMax)
+	c.memberBackoffMax = tunableDuration(tunables, "memberBackoffMaxSeconds", c.baselineTunables.memberBackoffMax, minTunableBackoffBase, maxTunableBackoffMax)
+	c.probeBreakerThreshold = tunableCount(tunables, "probeBreakerThreshold", c.baselineTunables.probeBreakerThreshold, 0, maxTunableBreakerThreshold)
+	// a max below the base would make the first backoff overshoot the cap -
+	// clamp the pair into consistency rather than honoring the typo
+	if c.memberBackoffMax != 0 && c.memberBackoffMax < c.memberBackoffBaseOrDefault() {
+		klog.Warningf("clamping %s.memberBackoffMaxSeconds up to the backoff base %s", wellknownTunablesKey, c.memberBackoffBaseOrDefault())
+		c.memberBackoffMax = c.memberBackoffBaseOrDefault()
+	}
+
+	klog.Infof("effective readiness tunables: %s", strings.Join([]string{
+		fmt.Sprintf("probe interval %s", c.probeInterval),
+		fmt.Sprintf("degraded grace count %d", c.degradedGraceCountOrDefault()),
+		fmt.Sprintf("member backoff base %s", c.memberBackoffBaseOrDefault()),
+		fmt.Sprintf("member backoff max %s", c.memberBackoffMaxOrDefault()),
+		fmt.Sprintf("probe breaker threshold %d", c.probeBreakerThresholdOrDefault()),
+	}, ", "))
+}
+
+// tunableDuration resolves one seconds-valued tunable: the override when
+// present and within [min, max] (clamped and logged otherwise), or the
+// baseline value when absent or not a number.
+func tunableDuration(tunables map[string]interface{}, key string, baseline, min, max time.Duration) time.Duration {
+	seconds, ok := tunables[key].(float64)
+	if !ok {
+		return baseline
+	}
+	value := time.Duration(seconds * float64(time.Second))
+	if value < min {
+		klog.Warningf("clamping %s.%s from %s up to %s", wellknownTunablesKey, key, value, min)
+		return min
+	}
+	if value > max {
+		klog.Warningf("clamping %s.%s from %s down to %s", wellknownTunablesKey, key, value, max)
+		return max
+	}
+	return value
+}
+
+// tunableCount resolves one integer-valued tunable with the same clamping
+// semantics as tunableDuration.
+func tunableCount(tunables map[string]interface{}, key string, baseline, min, max int) int {
+	number, ok := tunables[key].(float64)
+	if !ok {
+		return baseline
+	}
+	value := int(number)
+	if value < min {
+		klog.Warningf("clamping %s.%s from %d up to %d", wellknownTunablesKey, key, value, min)
+		return min
+	}
+	if value > max {
+		klog.Warningf("clamping %s.%s from %d down to %d", wellknownTunablesKey, key, value, max)
+		return max
+	}
+	return value
+}
+
+// memberBackoffBaseOrDefault returns the effective backoff base.
+func (c *authOperator) memberBackoffBaseOrDefault() time.Duration {
+	if c.memberBackoffBase > 0 {
+		return c.memberBackoffBase
+	}
+	return probeMemberBackoffBase
+}
+
+// memberBackoffMaxOrDefault returns the effective backoff cap.
+func (c *authOperator) memberBackoffMaxOrDefault() time.Duration {
+	if c.memberBackoffMax > 0 {
+		return c.memberBackoffMax
+	}
+	return probeMemberBackoffMax
+}
+
+// degradedGraceCountOrDefault returns the effective degraded grace count.
+func (c *authOperator) degradedGraceCountOrDefault() int {
+	if c.degradedGraceCount > 0 {
+		return c.degradedGraceCount
+	}
+	return defaultDegradedGraceCount
+}
@@ -0,0 +1,75 @@
+package operator2
+
+import (
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime"
+
+	operatorv1 "github.com/openshift/api/operator/v1"
+)
+
+func testOperatorConfigWithOverrides(overrides string) *operatorv1.Authentication {
+	config := &operatorv1.Authentication{}
+	config.Spec.UnsupportedConfigOverrides = runtime.RawExtension{Raw: []byte(overrides)}
+	return config
+}
+
+func TestApplyUnsupportedTunables(t *testing.T) {
+	c := &authOperator{probeInterval: 42 * time.Second}
+
+	// without overrides the constructor-provided values stay in effect
+	c.applyUnsupportedTunables(testOperatorConfigWithOverrides(""))
+	if c.probeInterval != 42*time.Second {
+		t.Errorf("probe interval = %s, want the baseline 42s", c.probeInterval)
+	}
+
+	// overrides replace the baseline
+	c.applyUnsupportedTunables(testOperatorConfigWithOverrides(
+		`{"wellknownReadiness": {"probeIntervalSeconds": 60, "degradedGraceCount": 5, "memberBackoffBaseSeconds": 30, "memberBackoffMaxSeconds": 600}}`))
+	if c.probeInterval != time.Minute {
+		t.Errorf("probe interval = %s, want 1m", c.probeInterval)
+	}
+	if c.degradedGraceCountOrDefault() != 5 {
+		t.Errorf("degraded grace count = %d, want 5", c.degradedGraceCountOrDefault())
+	}
+	if c.memberBackoffBaseOrDefault() != 30*time.Second || c.memberBackoffMaxOrDefault() != 10*time.Minute {
+		t.Errorf("member backoff = %s/%s, want 30s/10m", c.memberBackoffBaseOrDefault(), c.memberBackoffMaxOrDefault())
+	}
+
+	// removing the overrides falls back to the baseline, not the last override
+	c.applyUnsupportedTunables(testOperatorConfigWithOverrides(""))
+	if c.probeInterval != 42*time.Second {
+		t.Errorf("probe interval = %s, want the baseline 42s after override removal", c.probeInterval)
+	}
+	if c.degradedGraceCountOrDefault() != defaultDegradedGraceCount {
+		t.Errorf("degraded grace count = %d, want the default after override removal", c.degradedGraceCountOrDefault())
+	}
+
+	// out-of-range values are clamped, not honored and not dropped
+	c.applyUnsupportedTunables(testOperatorConfigWithOverrides(
+		`{"wellknownReadiness": {"probeIntervalSeconds": 999999, "degradedGraceCount": 100, "memberBackoffBaseSeconds": 0}}`))
+	if c.probeInterval != maxTunableProbeInterval {
+		t.Errorf("probe interval = %s, want the clamp %s", c.probeInterval, maxTunableProbeInterval)
+	}
+	if c.degradedGraceCountOrDefault() != maxTunableDegradedGraceCount {
+		t.Errorf("degraded grace count = %d, want the clamp %d", c.degradedGraceCountOrDefault(), maxTunableDegradedGraceCount)
+	}
+	if c.memberBackoffBaseOrDefault() != minTunableBackoffBase {
+		t.Errorf("member backoff base = %s, want the clamp %s", c.memberBackoffBaseOrDefault(), minTunableBackoffBase)
+	}
+
+	// a max below the base is raised to the base so the first backoff cannot
+	// overshoot the cap
+	c.applyUnsupportedTunables(testOperatorConfigWithOverrides(
+		`{"wellknownReadiness": {"memberBackoffBaseSeconds": 120, "memberBackoffMaxSeconds": 60}}`))
+	if c.memberBackoffMaxOrDefault() != 2*time.Minute {
+		t.Errorf("member backoff max = %s, want it raised to the 2m base", c.memberBackoffMaxOrDefault())
+	}
+
+	// unparseable overrides are ignored and the baseline stays in effect
+	c.applyUnsupportedTunables(testOperatorConfigWithOverrides(`this is not JSON`))
+	if c.probeInterval != 42*time.Second {
+		t.Errorf("probe interval = %s, want the baseline 42s for garbage overrides", c.probeInterval)
+	}
+}
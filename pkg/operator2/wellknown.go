@@ -0,0 +1,2034 @@
+package operator2
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/klog"
+
+	configv1 "github.com/openshift/api/config/v1"
+	operatorv1 "github.com/openshift/api/operator/v1"
+	routev1 "github.com/openshift/api/route/v1"
+	"github.com/openshift/library-go/pkg/operator/v1helpers"
+)
+
+// reasons used on the Progressing condition when the well-known endpoints are
+// not ready.  monitoring keys off of these so they must stay stable:
+//   - WellKnownNotReady is the catch all for cases that have no specific reason
+//   - UnexpectedStatus means a KAS member answered with a non-200 status,
+//     i.e. the right server is unhealthy
+//   - DiscoveryRequiresAuthentication means a member answered 401 or 403 -
+//     the discovery endpoint must be anonymous, so demanding credentials
+//     there breaks every unauthenticated OAuth client at once
+//   - MalformedMetadata means a KAS member answered 200 with a body that is
+//     not valid JSON, i.e. the wrong server probably answered
+//   - EmptyMetadataBody means a member answered 200 with no body at all,
+//     which points at a proxy or routing layer eating the response rather
+//     than at the document being malformed
+//   - BodyReadFailed means the connection died part way through the body,
+//     which is usually a transient network issue during a KAS rollout
+//   - WaitingForEndpoints means the kubernetes endpoints have no ready
+//     addresses yet, i.e. the control plane is still coming up
+//   - ProbeDeadlineExceeded means the probe cycle ran out of its overall
+//     time budget before every member could be probed - the unprobed
+//     members are named rather than blocking the sync on them
+//   - MetadataMismatch means a member served a well-formed document whose
+//     content does not match the expected metadata
+//   - IssuerIsIPAddress means a member served a document whose issuer is an
+//     IP literal instead of the expected DNS host - the OAuth server is not
+//     aware of its public host
+//   - MissingMetadataField means a member served a well-formed document that
+//     lacks a required endpoint field entirely, i.e. the server answering is
+//     not fully configured as an OAuth server
+//   - DeclaredMetadataMismatch means the admin declared the served metadata
+//     via an OAuthMetadata ConfigMap reference and the document the well-known
+//     endpoint serves does not match it
+//   - RouteNotAdmitted means the oauth route has not been admitted by the
+//     router at its canonical host
+//   - CABundleError means the CA bundle used to verify the probe connection
+//     could not be used
+//   - CABundleExpired means every CA certificate in the probe bundle is past
+//     its notAfter - verification can only fail, and the remediation is
+//     rotating the service account CA, not debugging TLS errors
+//   - WeakTLSVersion means a KAS member negotiated a TLS version below the
+//     required minimum, i.e. it runs with weaker-than-expected TLS policy
+//   - ServingCertMissingName means a KAS member presented a serving
+//     certificate without the expected service DNS name in its SANs
+//   - NotApplicable means the cluster does not use the integrated OAuth
+//     stack at all (external OIDC), so the well-known check is skipped
+//     rather than silently reported as verified
+//   - AllProbeMembersExcluded means the admin excluded every KAS member from
+//     probing via the exclusion annotation, leaving nothing to verify the
+//     well-known endpoints against
+const (
+	reasonWellKnownNotReady        = "WellKnownNotReady"
+	reasonUnexpectedStatus         = "UnexpectedStatus"
+	reasonDiscoveryAuthRequired    = "DiscoveryRequiresAuthentication"
+	reasonMalformedMetadata        = "MalformedMetadata"
+	reasonEmptyMetadataBody        = "EmptyMetadataBody"
+	reasonBodyReadFailed           = "BodyReadFailed"
+	reasonWaitingForEndpoints      = "WaitingForEndpoints"
+	reasonProbeDeadlineExceeded    = "ProbeDeadlineExceeded"
+	reasonMetadataMismatch         = "MetadataMismatch"
+	reasonIssuerIsIPAddress        = "IssuerIsIPAddress"
+	reasonMissingMetadataField     = "MissingMetadataField"
+	reasonDeclaredMetadataMismatch = "DeclaredMetadataMismatch"
+	reasonRouteNotAdmitted         = "RouteNotAdmitted"
+	reasonCABundleError            = "CABundleError"
+	reasonCABundleExpired          = "CABundleExpired"
+	reasonWeakTLSVersion           = "WeakTLSVersion"
+	reasonServingCertMissingName   = "ServingCertMissingName"
+	reasonNotApplicable            = "NotApplicable"
+	reasonAllMembersExcluded       = "AllProbeMembersExcluded"
+)
+
+// defaultConditionPrefix is prepended to the condition types and default
+// reasons owned by the well-known readiness checks.  if this logic is ever
+// instantiated more than once, the second instance must use a distinct prefix
+// via the conditionPrefix field so condition names do not collide.
+const defaultConditionPrefix = "WellKnown"
+
+// wellKnownPrefix returns the effective condition-name prefix.
+func (c *authOperator) wellKnownPrefix() string {
+	if len(c.conditionPrefix) == 0 {
+		return defaultConditionPrefix
+	}
+	return c.conditionPrefix
+}
+
+// reasonNotReady is the catch all not-ready reason, derived from the
+// condition-name prefix.
+func (c *authOperator) reasonNotReady() string {
+	return c.wellKnownPrefix() + "NotReady"
+}
+
+// defaultProbeUserAgent identifies the well-known readiness probes in KAS audit
+// and access logs so admins can tell them apart from real client traffic.
+const defaultProbeUserAgent = "cluster-authentication-operator/wellknown-ready-controller"
+
+// probeUserAgent returns the User-Agent sent on the well-known probe requests.
+func (c *authOperator) probeUserAgent() string {
+	if len(c.userAgent) == 0 {
+		return defaultProbeUserAgent
+	}
+	return c.userAgent
+}
+
+const scopesSupportedKey = "scopes_supported"
+
+// defaultRequiredScopes returns the scopes the integrated OAuth server must
+// always advertise.  clusters may legitimately advertise additional scopes so
+// the readiness check only requires that at least these are present.
+func defaultRequiredScopes() []string {
+	return []string{
+		"user:check-access",
+		"user:full",
+		"user:info",
+		"user:list-projects",
+		"user:list-scoped-projects",
+	}
+}
+
+// wellknownResult is the cached outcome of a full readiness check, see
+// probeInterval.
+type wellknownResult struct {
+	ready   bool
+	reason  string
+	message string
+	expires time.Time
+	// issuer the check ran against - the outcome is only reusable while the
+	// route still derives the same issuer, see cachedWellknownResult
+	issuer string
+}
+
+func (c *authOperator) checkWellknownEndpointsReady(authConfig *configv1.Authentication, route *routev1.Route) (bool, string, string, error) {
+	// a cluster on external auth does not serve the integrated document at
+	// all - say so explicitly instead of reporting a check that never ran as
+	// passed, see reasonNotApplicable
+	if authConfig.Spec.Type != configv1.AuthenticationTypeIntegratedOAuth {
+		return true, reasonNotApplicable, fmt.Sprintf("the integrated OAuth well-known check is skipped because the cluster authentication type is %q", authConfig.Spec.Type), nil
+	}
+
+	// outside a pod (dev and test runs) the SA environment is absent and
+	// every sync would fail reading the CA bundle - say so once and skip
+	// probing instead of erroring forever
+	if !c.runningInCluster() {
+		if !c.notInClusterLogged {
+			c.notInClusterLogged = true
+			klog.Warningf("not running in-cluster (no %s and no KUBERNETES_SERVICE_HOST), the well-known probe is disabled", rootCAFile)
+		}
+		return true, "", "", nil
+	}
+
+	// with an OAuthMetadata reference the admin declares the served document -
+	// the integrated expectation does not apply, but the declared document and
+	// what is actually served can still diverge (e.g. an updated ConfigMap the
+	// serving layer has not picked up), which used to go entirely unchecked
+	if len(authConfig.Spec.OAuthMetadata.Name) != 0 {
+		return c.checkDeclaredMetadataServed(authConfig.Spec.OAuthMetadata.Name)
+	}
+
+	// inside the probe interval the last outcome still stands - reconcile can
+	// run as often as it likes without multiplying load on the KAS members
+	if result, ok := c.cachedWellknownResult(route); ok {
+		return result.ready, result.reason, result.message, nil
+	}
+
+	ready, reason, message, err := c.probeWellknownEndpointsReady(route)
+	if isShuttingDownError(err) {
+		// a cycle cut short by shutdown says nothing about health - no cache,
+		// no recovery bookkeeping, just hand the cancellation up
+		return ready, reason, message, err
+	}
+	if err == nil {
+		// hard errors are never cached - they should retry immediately
+		c.storeWellknownResult(route, ready, reason, message)
+	}
+	c.noteWellknownRecovery(ready && err == nil)
+	return ready, reason, message, err
+}
+
+// noteWellknownRecovery complements the route-admitted breadcrumb: a Normal
+// event pins the moment the well-known readiness came back after having been
+// not ready, which is exactly the timestamp incident retrospectives dig for.
+// the transition is tracked explicitly so the event fires once per recovery,
+// not once per sync - and an operator that starts out ready announces
+// nothing, since there was no outage to recover from.  only called from the
+// sync loop, so the field needs no locking.
+func (c *authOperator) noteWellknownRecovery(ready bool) {
+	if !ready {
+		c.wellknownWasNotReady = true
+		return
+	}
+	if !c.wellknownWasNotReady {
+		return
+	}
+	c.wellknownWasNotReady = false
+	passed, _ := c.LastProbeResults()
+	c.eventRecorder().Eventf("OAuthWellknownReady", "the OAuth well-known endpoints are ready again, verified on %d kube api server members", len(passed))
+}
+
+// cachedWellknownResult returns the outcome of the last readiness check while
+// it is still fresh and was computed against the issuer the route currently
+// derives - a route host change invalidates the outcome immediately, since a
+// stale "ready" for the old issuer must not outlive the change.  with a zero
+// probeInterval nothing is ever cached and every sync probes.
+func (c *authOperator) cachedWellknownResult(route *routev1.Route) (*wellknownResult, bool) {
+	if c.probeInterval <= 0 {
+		return nil, false
+	}
+
+	c.probeStateLock.Lock()
+	defer c.probeStateLock.Unlock()
+
+	if c.lastWellknownResult == nil || !c.now().Before(c.lastWellknownResult.expires) {
+		return nil, false
+	}
+	if c.lastWellknownResult.issuer != c.issuerURL(route) {
+		return nil, false
+	}
+	return c.lastWellknownResult, true
+}
+
+// storeWellknownResult records the outcome of a readiness check for reuse
+// until the probe interval elapses or the route-derived issuer changes.
+func (c *authOperator) storeWellknownResult(route *routev1.Route, ready bool, reason, message string) {
+	if c.probeInterval <= 0 {
+		return
+	}
+
+	c.probeStateLock.Lock()
+	defer c.probeStateLock.Unlock()
+
+	c.lastWellknownResult = &wellknownResult{
+		ready:   ready,
+		reason:  reason,
+		message: message,
+		expires: c.now().Add(c.probeInterval),
+		issuer:  c.issuerURL(route),
+	}
+}
+
+// checkDeclaredMetadataServed compares the metadata document declared via the
+// authConfig's OAuthMetadata ConfigMap reference with what the well-known
+// endpoint actually serves.  the declared document is served by the KAS, so a
+// single fetch through the service VIP is representative - per-member drift is
+// the integrated check's concern, not this one's.
+func (c *authOperator) checkDeclaredMetadataServed(name string) (bool, string, string, error) {
+	configMap, err := c.configMaps.ConfigMaps(userConfigNamespace).Get(name, metav1.GetOptions{})
+	if err != nil {
+		return false, reasonDeclaredMetadataMismatch, fmt.Sprintf("failed to get the declared metadata config map %s/%s: %v", userConfigNamespace, name, err), nil
+	}
+	var declared map[string]interface{}
+	if err := json.Unmarshal([]byte(configMap.Data[configv1.OAuthMetadataKey]), &declared); err != nil {
+		return false, reasonDeclaredMetadataMismatch, fmt.Sprintf("the declared metadata config map %s/%s does not carry valid JSON at key %s: %v", userConfigNamespace, name, configv1.OAuthMetadataKey, err), nil
+	}
+
+	caData, err := ioutil.ReadFile(c.rootCAPathOrDefault())
+	if err != nil {
+		return false, reasonCABundleError, fmt.Sprintf("failed to read SA ca.crt: %v", err), nil
+	}
+	rt, reason, message, err := c.wellknownProbeTransport(caData)
+	if err != nil {
+		return false, "", "", err
+	}
+	if rt == nil {
+		return false, reason, message, nil
+	}
+
+	return c.checkDeclaredMetadataAgainst(declared, fmt.Sprintf("%s:%d", kasServiceFullName, kasServicePort), rt)
+}
+
+// checkDeclaredMetadataAgainst fetches the document served behind apiIP and
+// reports the per-key divergence from the declared document.  apiIP is the KAS
+// service VIP here, so the served document is recorded as the KAS-served state
+// the consistency checks compare against.
+func (c *authOperator) checkDeclaredMetadataAgainst(declared map[string]interface{}, apiIP string, rt http.RoundTripper) (bool, string, string, error) {
+	served, reason, message, err := c.fetchServedMetadata(apiIP, rt)
+	if err != nil {
+		return false, "", "", err
+	}
+	if served == nil {
+		return false, reason, message, nil
+	}
+	c.recordServedIssuer(served)
+
+	if diffs := metadataDiff(declared, served); len(diffs) > 0 {
+		wellKnown := "https://" + apiIP + oauthMetadataAPIEndpoint
+		return false, reasonDeclaredMetadataMismatch, fmt.Sprintf("the declared OAuthMetadata diverges from the document served at well-known %s: %s", wellKnown, strings.Join(diffs, "; ")), nil
+	}
+	return true, "", "", nil
+}
+
+// fetchServedMetadata GETs and decodes the metadata document served behind
+// apiIP.  it is a plain fetch with no side effects: it records nothing into
+// the probe state caches and makes no assumptions about whose certificate is
+// on the other end, so it is safe against any vantage point - a KAS member,
+// the KAS service VIP, or the oauth service itself.  a nil document with a
+// non-empty reason means the endpoint answered but not usefully.
+func (c *authOperator) fetchServedMetadata(apiIP string, rt http.RoundTripper) (map[string]interface{}, string, string, error) {
+	wellKnown := "https://" + apiIP + oauthMetadataAPIEndpoint
+
+	req, err := http.NewRequest(http.MethodGet, wellKnown, nil)
+	if err != nil {
+		return nil, "", "", fmt.Errorf("failed to build request to well-known %s: %v", wellKnown, err)
+	}
+	req.Header.Set("User-Agent", c.probeUserAgent())
+
+	resp, err := rt.RoundTrip(req)
+	if err != nil {
+		return nil, "", "", fmt.Errorf("failed to GET well-known %s: %v", wellKnown, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		reason, message := unexpectedStatusResult(resp, wellKnown)
+		return nil, reason, message, nil
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, reasonBodyReadFailed, fmt.Sprintf("failed to read well-known %s body: %v", wellKnown, err), nil
+	}
+	if len(bytes.TrimSpace(body)) == 0 {
+		return nil, reasonEmptyMetadataBody, fmt.Sprintf("the well-known %s endpoint returned empty body - a proxy or routing layer between the operator and the server is likely eating the response", wellKnown), nil
+	}
+	var served map[string]interface{}
+	if err := unmarshalMetadata(body, &served); err != nil {
+		return nil, reasonMalformedMetadata, fmt.Sprintf("failed to unmarshal well-known %s JSON: %v", wellKnown, err), nil
+	}
+	return served, "", "", nil
+}
+
+// handleIssuerConsistency cross-checks the issuer across its possible sources
+// of truth: the route host, the OAuthMetadata ConfigMap the admin declared
+// (only consulted while that external-metadata mode is active), and the
+// document the well-known endpoint actually served to the last probe.  the
+// pairwise checks each catch their own drift but miss partial configuration
+// states - a ConfigMap updated while the route was not, or both updated while
+// the serving layer still answers with the old document - so any disagreement
+// is surfaced as a condition naming the sources that diverge.
+func (c *authOperator) handleIssuerConsistency(operatorConfig *operatorv1.Authentication, authConfig *configv1.Authentication, route *routev1.Route) {
+	condition := operatorv1.OperatorCondition{
+		Type:   "OAuthIssuerDrift",
+		Status: operatorv1.ConditionFalse,
+		Reason: "AsExpected",
+	}
+	defer func() {
+		v1helpers.SetOperatorCondition(&operatorConfig.Status.Conditions, condition)
+	}()
+
+	sources := []issuerSource{{name: "route", issuer: c.issuerURL(route)}}
+
+	if name := authConfig.Spec.OAuthMetadata.Name; len(name) != 0 {
+		declared, err := c.declaredIssuer(name)
+		if err != nil {
+			condition.Status = operatorv1.ConditionTrue
+			condition.Reason = "DeclaredIssuerUnreadable"
+			condition.Message = err.Error()
+			return
+		}
+		sources = append(sources, issuerSource{name: fmt.Sprintf("declared metadata config map %s/%s", userConfigNamespace, name), issuer: declared})
+	}
+
+	// before the first successful probe there is no served document to compare
+	if served := c.servedIssuer(); len(served) != 0 {
+		sources = append(sources, issuerSource{name: "served well-known document", issuer: served})
+	}
+
+	if diffs := issuerDisagreements(sources); len(diffs) > 0 {
+		condition.Status = operatorv1.ConditionTrue
+		condition.Reason = "IssuerMismatch"
+		condition.Message = fmt.Sprintf("the issuer is not consistent across its sources: %s", strings.Join(diffs, "; "))
+	}
+}
+
+// handleIDPConsistency optionally cross-references the served well-known
+// document against the oauth configuration it is supposed to reflect.  the
+// document does not list identity providers, but its issuer and endpoints
+// still have to agree with what the configured providers produce - endpoints
+// on a foreign host or an advertised authorization endpoint with no providers
+// behind it both mean the serving layer and the configuration diverged.
+// opt-in via checkIDPConsistency since clusters mid-configuration trip it
+// legitimately.
+func (c *authOperator) handleIDPConsistency(operatorConfig *operatorv1.Authentication) {
+	if !c.checkIDPConsistency {
+		return
+	}
+
+	condition := operatorv1.OperatorCondition{
+		Type:   "IdentityProviderConsistency",
+		Status: operatorv1.ConditionFalse,
+		Reason: "AsExpected",
+	}
+	defer func() {
+		v1helpers.SetOperatorCondition(&operatorConfig.Status.Conditions, condition)
+	}()
+
+	oauthConfig, err := c.oauth.Get(globalConfigName, metav1.GetOptions{})
+	if err != nil {
+		condition.Status = operatorv1.ConditionTrue
+		condition.Reason = "OAuthConfigError"
+		condition.Message = fmt.Sprintf("failed to get the oauth config: %v", err)
+		return
+	}
+
+	// before the first successful probe there is no served document to check
+	doc := c.servedMetadataDoc()
+	if doc == nil {
+		return
+	}
+
+	if problems := idpConsistencyProblems(oauthConfig, doc); len(problems) > 0 {
+		condition.Status = operatorv1.ConditionTrue
+		condition.Reason = "InconsistentWithConfiguration"
+		condition.Message = fmt.Sprintf("the served well-known document does not agree with the oauth configuration: %s", strings.Join(problems, "; "))
+	}
+}
+
+// idpConsistencyProblems describes every disagreement between the served
+// well-known document and the oauth configuration.
+func idpConsistencyProblems(oauthConfig *configv1.OAuth, doc map[string]interface{}) []string {
+	var problems []string
+
+	issuer, _ := doc["issuer"].(string)
+	issuerURL, err := url.Parse(issuer)
+	if err != nil || len(issuerURL.Host) == 0 {
+		problems = append(problems, fmt.Sprintf("the served issuer %q is not a valid URL", issuer))
+		return problems
+	}
+
+	// every advertised endpoint must live on the issuer host - anything else
+	// means a different serving layer answered than the one the configured
+	// providers are wired into
+	for _, field := range []string{"authorization_endpoint", "token_endpoint"} {
+		endpoint, ok := doc[field].(string)
+		if !ok || len(endpoint) == 0 {
+			continue
+		}
+		endpointURL, err := url.Parse(endpoint)
+		if err != nil || endpointURL.Host != issuerURL.Host {
+			problems = append(problems, fmt.Sprintf("the served %s %q does not live on the issuer host %q", field, endpoint, issuerURL.Host))
+		}
+	}
+
+	// an advertised authorization endpoint with no identity providers behind
+	// it sends interactive logins into a flow that cannot complete
+	if endpoint, ok := doc["authorization_endpoint"].(string); ok && len(endpoint) != 0 && len(oauthConfig.Spec.IdentityProviders) == 0 {
+		problems = append(problems, fmt.Sprintf("the served metadata advertises authorization endpoint %q but the oauth config has no identity providers - interactive logins cannot complete", endpoint))
+	}
+
+	return problems
+}
+
+// issuerSource is one place an issuer value can come from, named for condition
+// messages.
+type issuerSource struct {
+	name   string
+	issuer string
+}
+
+// issuerDisagreements compares every source against the first one - the route,
+// which is where the operator derives the canonical issuer - and describes
+// each disagreement.
+func issuerDisagreements(sources []issuerSource) []string {
+	reference := sources[0]
+	var diffs []string
+	for _, source := range sources[1:] {
+		if source.issuer != reference.issuer {
+			diffs = append(diffs, fmt.Sprintf("the %s carries issuer %q while the %s derives %q", source.name, source.issuer, reference.name, reference.issuer))
+		}
+	}
+	return diffs
+}
+
+// declaredIssuer extracts the issuer from the OAuthMetadata ConfigMap the
+// authConfig references.
+func (c *authOperator) declaredIssuer(name string) (string, error) {
+	configMap, err := c.configMaps.ConfigMaps(userConfigNamespace).Get(name, metav1.GetOptions{})
+	if err != nil {
+		return "", fmt.Errorf("failed to get the declared metadata config map %s/%s: %v", userConfigNamespace, name, err)
+	}
+	var declared map[string]interface{}
+	if err := json.Unmarshal([]byte(configMap.Data[configv1.OAuthMetadataKey]), &declared); err != nil {
+		return "", fmt.Errorf("the declared metadata config map %s/%s does not carry valid JSON at key %s: %v", userConfigNamespace, name, configv1.OAuthMetadataKey, err)
+	}
+	issuer, ok := declared["issuer"].(string)
+	if !ok || len(issuer) == 0 {
+		return "", fmt.Errorf("the declared metadata config map %s/%s carries no issuer", userConfigNamespace, name)
+	}
+	return issuer, nil
+}
+
+// recordServedIssuer remembers the issuer - and the whole document - from a
+// well-known document a probe successfully decoded.  the issuer consistency
+// and metadata schema checks reuse them instead of fetching the document
+// again, so neither check adds probe traffic of its own.
+func (c *authOperator) recordServedIssuer(receivedValues map[string]interface{}) {
+	c.probeStateLock.Lock()
+	defer c.probeStateLock.Unlock()
+
+	c.lastServedMetadata = receivedValues
+	if issuer, ok := receivedValues["issuer"].(string); ok && len(issuer) != 0 {
+		c.lastServedIssuer = issuer
+	}
+}
+
+// servedIssuer returns the issuer from the last decoded well-known document,
+// or an empty string before the first successful probe.
+func (c *authOperator) servedIssuer() string {
+	c.probeStateLock.Lock()
+	defer c.probeStateLock.Unlock()
+
+	return c.lastServedIssuer
+}
+
+// servedMetadataDoc returns the last decoded well-known document, or nil
+// before the first successful probe.
+func (c *authOperator) servedMetadataDoc() map[string]interface{} {
+	c.probeStateLock.Lock()
+	defer c.probeStateLock.Unlock()
+
+	return c.lastServedMetadata
+}
+
+// handleTokenEndpointCORS optionally verifies that the token endpoint the
+// metadata advertises answers a browser CORS preflight through the router.
+// single-page OAuth clients preflight the token endpoint with OPTIONS before
+// posting to it, so a CORS misconfiguration breaks browser logins while
+// server-to-server flows - and the metadata-only checks above - keep looking
+// healthy.  opt-in via checkTokenEndpointCORS because CORS expectations vary
+// between deployments.
+func (c *authOperator) handleTokenEndpointCORS(operatorConfig *operatorv1.Authentication, route *routev1.Route, routerSecret *corev1.Secret, ingress *configv1.Ingress) {
+	if !c.checkTokenEndpointCORS {
+		return
+	}
+
+	condition := operatorv1.OperatorCondition{
+		Type:   "TokenEndpointCORS",
+		Status: operatorv1.ConditionFalse,
+		Reason: "AsExpected",
+	}
+	defer func() {
+		v1helpers.SetOperatorCondition(&operatorConfig.Status.Conditions, condition)
+	}()
+
+	rt, err := c.routeProbeTransport(route, routerSecret, ingress)
+	if err != nil {
+		condition.Status = operatorv1.ConditionTrue
+		condition.Reason = "PreflightRequestFailed"
+		condition.Message = fmt.Sprintf("failed to build transport for the CORS preflight: %v", err)
+		return
+	}
+
+	endpoint, _ := c.expectedMetadataStruct(route)["token_endpoint"].(string)
+	if ok, reason, message := c.checkTokenEndpointCORSAgainst(endpoint, c.corsPreflightOrigin(ingress), rt); !ok {
+		condition.Status = operatorv1.ConditionTrue
+		condition.Reason = reason
+		condition.Message = message
+	}
+}
+
+// corsPreflightOrigin is the origin the preflight pretends to come from.  the
+// console is the canonical browser-based OAuth client on every cluster, so its
+// origin is the one that must be allowed for SPA logins to work.
+func (c *authOperator) corsPreflightOrigin(ingress *configv1.Ingress) string {
+	return "https://console-openshift-console." + ingress.Spec.Domain
+}
+
+// checkTokenEndpointCORSAgainst sends an OPTIONS preflight to the given token
+// endpoint and evaluates the CORS response headers the way a browser would:
+// the origin must be allowed (exactly or via the wildcard) and, when the
+// server enumerates allowed methods at all, POST must be among them.
+func (c *authOperator) checkTokenEndpointCORSAgainst(endpoint, origin string, rt http.RoundTripper) (bool, string, string) {
+	req, err := http.NewRequest(http.MethodOptions, endpoint, nil)
+	if err != nil {
+		return false, "PreflightRequestFailed", fmt.Sprintf("failed to build the CORS preflight request against %s: %v", endpoint, err)
+	}
+	req.Header.Set("User-Agent", c.probeUserAgent())
+	req.Header.Set("Origin", origin)
+	req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+
+	resp, err := rt.RoundTrip(req)
+	if err != nil {
+		return false, "PreflightRequestFailed", fmt.Sprintf("the CORS preflight against %s failed: %v", endpoint, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return false, "PreflightRejected", fmt.Sprintf("the token endpoint %s rejected the CORS preflight with '%s'", endpoint, resp.Status)
+	}
+	if allowed := resp.Header.Get("Access-Control-Allow-Origin"); allowed != "*" && allowed != origin {
+		return false, "PreflightFailed", fmt.Sprintf("the token endpoint %s does not allow origin %s (Access-Control-Allow-Origin is %q) - browser-based OAuth clients cannot complete a login", endpoint, origin, allowed)
+	}
+	if methods := resp.Header.Get("Access-Control-Allow-Methods"); len(methods) != 0 && !strings.Contains(methods, http.MethodPost) {
+		return false, "PreflightFailed", fmt.Sprintf("the token endpoint %s does not allow POST (Access-Control-Allow-Methods is %q) - browser-based OAuth clients cannot redeem authorization codes", endpoint, methods)
+	}
+	return true, "", ""
+}
+
+// rootCAPathOrDefault returns the path the probes read the SA CA bundle from.
+func (c *authOperator) rootCAPathOrDefault() string {
+	if len(c.rootCAPath) != 0 {
+		return c.rootCAPath
+	}
+	return rootCAFile
+}
+
+// runningInCluster reports whether the in-cluster SA environment is present:
+// the SA CA bundle on disk or the KUBERNETES_SERVICE_HOST variable the
+// kubelet injects.  a custom rootCAPath always counts as in-cluster - the
+// operator was explicitly pointed at a bundle.
+func (c *authOperator) runningInCluster() bool {
+	if len(c.rootCAPath) != 0 {
+		return true
+	}
+	if len(os.Getenv("KUBERNETES_SERVICE_HOST")) != 0 {
+		return true
+	}
+	_, err := os.Stat(rootCAFile)
+	return err == nil
+}
+
+// probeWellknownEndpointsReady performs a full readiness check against the
+// live KAS members, bypassing the probe interval cache.
+func (c *authOperator) probeWellknownEndpointsReady(route *routev1.Route) (bool, string, string, error) {
+	caData, err := ioutil.ReadFile(c.rootCAPathOrDefault())
+	if err != nil {
+		// the bundle being briefly unreadable is what a rotation race looks
+		// like - wait for the next sync instead of going degraded
+		return false, reasonCABundleError, fmt.Sprintf("failed to read SA ca.crt: %v", err), nil
+	}
+
+	// a bundle of nothing but expired CAs can only ever fail verification -
+	// say so up front instead of surfacing whatever TLS error it produces
+	if latest, ok := latestCertExpiry(caData); ok && !latest.After(c.now()) {
+		return false, reasonCABundleExpired, fmt.Sprintf("every CA certificate in the probe bundle %s expired (latest notAfter %s) - rotate the service account CA", c.rootCAPathOrDefault(), latest.Format(time.RFC3339)), nil
+	}
+
+	rt, reason, message, err := c.wellknownProbeTransport(caData)
+	if err != nil {
+		return false, "", "", err
+	}
+	if rt == nil {
+		return false, reason, message, nil
+	}
+
+	ips, err := c.getAPIServerIPs()
+	if err != nil {
+		// without a member list there are no per-IP results to report
+		c.recordProbeResults(nil, nil)
+		recordMemberHealthPercent(0, 0)
+		if notReady, ok := err.(*endpointsNotReadyError); ok {
+			if c.endpointsEverReady() {
+				// all members dropping out after the cluster was up is a
+				// regression, not bootstrap - make it loud
+				return false, "", "", fmt.Errorf("kube api server endpoints regressed to not ready: %v", notReady)
+			}
+			// nothing to probe yet during bootstrap - progressing, not degraded
+			return false, reasonWaitingForEndpoints, notReady.Error(), nil
+		}
+		// a broken endpoints lookup should not fully blind the readiness
+		// check - fall back to probing via the service VIP by DNS name.  the
+		// VIP load balances to one arbitrary member so a passing fallback
+		// probe is weaker evidence than the per-IP fan out, which the
+		// condition message makes explicit.
+		return c.checkWellknownEndpointReadyViaService(rt, route, err)
+	}
+
+	// members the admin excluded for planned maintenance are dropped from the
+	// fan out, and every outcome says so - see handleProbeExclusions
+	ips, excluded := partitionExcludedMembers(ips, c.excludedProbeMembers)
+	if len(ips) == 0 && len(excluded) != 0 {
+		return false, reasonAllMembersExcluded, fmt.Sprintf("every kube api server member (%s) is excluded from probing via the %s annotation - nothing verified the well-known endpoints", strings.Join(excluded, ", "), excludeProbeMembersAnnotation), nil
+	}
+
+	ready, reason, message, err := c.checkMembersWellknownReady(ips, rt, route)
+	if !ready && err == nil && len(excluded) != 0 {
+		message = fmt.Sprintf("%s (members %s were excluded from probing by admin request)", message, strings.Join(excluded, ", "))
+	}
+	return ready, reason, message, err
+}
+
+// excludeProbeMembersAnnotation set on the operator config names KAS member
+// IPs (comma separated, with or without port) that the well-known probing
+// skips.  it exists for planned master maintenance, where a member the admin
+// knows is out should not drag readiness into Progressing - the
+// ProbeMembersExcluded condition keeps the exclusion visible so it is not
+// forgotten once the maintenance ends.
+const excludeProbeMembersAnnotation = "authentication.operator.openshift.io/exclude-probe-members"
+
+// handleProbeExclusions parses the exclusion annotation for this sync and
+// reports any active exclusions as a condition.
+func (c *authOperator) handleProbeExclusions(operatorConfig *operatorv1.Authentication) {
+	c.excludedProbeMembers = parseProbeExclusions(operatorConfig.Annotations[excludeProbeMembersAnnotation])
+
+	condition := operatorv1.OperatorCondition{
+		Type:   "ProbeMembersExcluded",
+		Status: operatorv1.ConditionFalse,
+		Reason: "AsExpected",
+	}
+	if len(c.excludedProbeMembers) != 0 {
+		condition.Status = operatorv1.ConditionTrue
+		condition.Reason = "AdminExcluded"
+		condition.Message = fmt.Sprintf("members %s are excluded from the well-known probing via the %s annotation - remove it once the maintenance ends", strings.Join(c.excludedProbeMembers, ", "), excludeProbeMembersAnnotation)
+	}
+	v1helpers.SetOperatorCondition(&operatorConfig.Status.Conditions, condition)
+}
+
+// parseProbeExclusions splits the annotation value into a sorted, deduplicated
+// member list.  an empty or absent value excludes nothing.
+func parseProbeExclusions(value string) []string {
+	members := sets.NewString()
+	for _, entry := range strings.Split(value, ",") {
+		if entry = strings.TrimSpace(entry); len(entry) != 0 {
+			members.Insert(entry)
+		}
+	}
+	return members.List()
+}
+
+// partitionExcludedMembers splits the member list into the members to probe
+// and the ones the exclusions match - an entry matches a member either
+// exactly or by its IP alone, so admins do not have to know the probe port.
+func partitionExcludedMembers(ips, exclusions []string) (probed, excluded []string) {
+	if len(exclusions) == 0 {
+		return ips, nil
+	}
+	excludedSet := sets.NewString(exclusions...)
+	for _, member := range ips {
+		host := member
+		if h, _, err := net.SplitHostPort(member); err == nil {
+			host = h
+		}
+		if excludedSet.Has(member) || excludedSet.Has(host) {
+			excluded = append(excluded, member)
+			continue
+		}
+		probed = append(probed, member)
+	}
+	return probed, excluded
+}
+
+// probeCABundleExpiryWarningWindow is how close to the expiry of its last
+// certificate the probe CA bundle may get before the conditions start warning
+// about it, mirroring routeCertsExpiryWarningWindow.
+const probeCABundleExpiryWarningWindow = 30 * 24 * time.Hour
+
+// handleProbeCAExpiry warns while the probe CA bundle ages out.  once every
+// certificate in it is expired the probes can only fail with TLS errors that
+// say nothing about the cause - this condition names the bundle and the
+// remediation (rotate the service account CA) ahead of and during that state.
+func (c *authOperator) handleProbeCAExpiry(operatorConfig *operatorv1.Authentication) {
+	condition := operatorv1.OperatorCondition{
+		Type:   "ProbeCABundleExpiring",
+		Status: operatorv1.ConditionFalse,
+		Reason: "AsExpected",
+	}
+	defer func() {
+		v1helpers.SetOperatorCondition(&operatorConfig.Status.Conditions, condition)
+	}()
+
+	// an unreadable or unparseable bundle is reported by the probe path
+	// itself - this condition only judges freshness
+	caData, err := ioutil.ReadFile(c.rootCAPathOrDefault())
+	if err != nil {
+		return
+	}
+	latest, ok := latestCertExpiry(caData)
+	if !ok {
+		return
+	}
+
+	if remaining := latest.Sub(c.now()); remaining < probeCABundleExpiryWarningWindow {
+		condition.Status = operatorv1.ConditionTrue
+		condition.Reason = "CertificateExpiring"
+		if remaining < 0 {
+			condition.Message = fmt.Sprintf("the last CA certificate in the probe bundle %s expired %s ago - rotate the service account CA", c.rootCAPathOrDefault(), (-remaining).Round(time.Minute))
+		} else {
+			condition.Message = fmt.Sprintf("the last CA certificate in the probe bundle %s expires in %s", c.rootCAPathOrDefault(), remaining.Round(time.Minute))
+		}
+	}
+}
+
+// wellknownProbeTransport builds the probe transport for the given CA bundle,
+// classifying failures by retryability.  an empty bundle is what a transient
+// read race during bundle (re)publication looks like - it resolves itself, so
+// report progressing and retry on the next sync.  a bundle with content that
+// cannot be parsed will not fix itself and is returned as a hard error.  the
+// transport passes the KAS service name for SNI; the client cert and key are
+// normally empty - they are only set on clusters that require client
+// certificates to reach the KAS endpoints we probe.
+func (c *authOperator) wellknownProbeTransport(caData []byte) (http.RoundTripper, string, string, error) {
+	if len(caData) == 0 {
+		// without the explicit check an empty bundle would silently fall back
+		// to the system roots, hiding the problem behind TLS failures
+		return nil, reasonCABundleError, "SA ca.crt is empty - waiting for the CA bundle to be published", nil
+	}
+	serverName, trustBundle, err := probeTLSIdentity(probeModeKAS, nil, caData, nil, nil)
+	if err != nil {
+		return nil, "", "", err
+	}
+	rt, err := c.probeTransportFor(serverName, trustBundle)
+	if err != nil {
+		return nil, "", "", fmt.Errorf("failed to build transport for SA ca.crt: %v", err)
+	}
+	return rt, "", "", nil
+}
+
+// checkMembersWellknownReady fans the well-known probe out over the given KAS
+// members.  a member that refuses the connection may have legitimately rotated
+// out between listing the endpoints and probing it - the endpoints are
+// re-fetched once in that case and refusals from members no longer present are
+// ignored instead of failing the whole check.  a member that keeps failing is
+// held in an exponential backoff and its last result replayed instead of
+// re-probing it every cycle, see memberInBackoff.
+func (c *authOperator) checkMembersWellknownReady(ips []string, rt http.RoundTripper, route *routev1.Route) (bool, string, string, error) {
+	c.pruneProbeMetadata(ips)
+	c.pruneMemberBackoffs(ips)
+
+	// even with per-request timeouts, a serial fan out over many slow members
+	// can add up to more than a sync should ever take - the whole cycle shares
+	// one deadline and members that do not fit inside it are reported as not
+	// probed instead of blocking the sync on them
+	budget := c.probeCycleBudgetOrDefault()
+	deadline := c.now().Add(budget)
+	rt = &deadlineTransport{deadline: deadline, now: c.now, delegate: rt}
+
+	// operator shutdown aborts in-flight round-trips instead of letting the
+	// fan out run to completion against a closing process
+	if c.shutdown != nil {
+		rt = &shutdownTransport{stop: c.shutdown, delegate: rt}
+	}
+
+	// the parent span covers the whole fan out, its children the per-member
+	// requests - see traceProbeRequest
+	if probeTracingEnabled() {
+		defer newProbeSpan("wellknown-probe-cycle", c.now).finish()
+	}
+
+	// under a sustained total outage fanning over every member each cycle
+	// only multiplies load and log volume for the same answer - once the
+	// breaker opens, a single member is probed per cycle until one answers,
+	// then the full fan out resumes
+	if failures, open := c.probeBreakerOpen(); open {
+		ready, reason, msg, err := c.checkWellknownEndpointReady(ips[0], rt, route)
+		if err != nil || !ready {
+			c.recordProbeCycleOutcome(false)
+			c.recordProbeResults(nil, []string{ips[0]})
+			recordMemberHealthPercent(0, len(ips))
+			suffix := fmt.Sprintf(" (the probe circuit breaker is open after %d fully failed probe cycles - only member %s was probed)", failures, ips[0])
+			if err != nil {
+				return false, "", "", fmt.Errorf("%v%s", err, suffix)
+			}
+			return false, reason, msg + suffix, nil
+		}
+		// the half-open probe answered - close the breaker, drop the probed
+		// member's backoff and fall through to the full fan out
+		c.recordProbeCycleOutcome(true)
+		c.clearMemberBackoff(ips[0])
+		klog.Infof("probe circuit breaker closed - member %s answered after %d fully failed probe cycles", ips[0], failures)
+	}
+
+	var refreshedIPs sets.String
+	skipped := 0
+	passed := make([]string, 0, len(ips))
+	for i, ip := range ips {
+		if c.shuttingDown() {
+			return false, "", "", errShuttingDown
+		}
+		if !c.now().Before(deadline) {
+			c.recordProbeCycleOutcome(len(passed) > 0)
+			c.recordProbeResults(passed, nil)
+			recordMemberHealthPercent(len(passed), len(ips)-skipped)
+			return false, reasonProbeDeadlineExceeded, probeDeadlineMessage(ips[i:], passed, budget), nil
+		}
+		if backoff, remaining, ok := c.memberInBackoff(ip); ok {
+			klog.V(2).Infof("not re-probing %s for another %s - %d consecutive failures", ip, remaining.Round(time.Second), backoff.failures)
+			c.recordProbeCycleOutcome(len(passed) > 0)
+			c.recordProbeResults(passed, []string{ip})
+			recordMemberHealthPercent(len(passed), len(ips)-skipped)
+			suffix := fmt.Sprintf(" (member %s is in probe backoff for another %s after %d consecutive failures)", ip, remaining.Round(time.Second), backoff.failures)
+			if len(backoff.hardErr) != 0 {
+				return false, "", "", fmt.Errorf("%s%s", backoff.hardErr, suffix)
+			}
+			return false, backoff.reason, backoff.message + suffix, nil
+		}
+		wellknownReady, wellknownReason, wellknownMsg, err := c.checkWellknownEndpointReady(ip, rt, route)
+		if err != nil && c.shuttingDown() {
+			// a round-trip aborted by shutdown is reported as the shutdown, not
+			// as a member failure - it earns no backoff and no probe bookkeeping
+			return false, "", "", errShuttingDown
+		}
+		if err != nil && isConnectionRefused(err) {
+			if refreshedIPs == nil {
+				if current, refreshErr := c.getAPIServerIPs(); refreshErr == nil {
+					refreshedIPs = sets.NewString(current...)
+				}
+			}
+			if refreshedIPs != nil && !refreshedIPs.Has(ip) {
+				klog.V(2).Infof("ignoring connection refused from %s - it is no longer a kube api server endpoint", ip)
+				skipped++
+				continue
+			}
+		}
+		if err != nil && isProbeDeadlineExceeded(err) {
+			// the member was too slow for what was left of the budget - that
+			// is the cycle running over, not a member failure, so it earns no
+			// backoff and the unprobed members are named instead
+			c.recordProbeCycleOutcome(len(passed) > 0)
+			c.recordProbeResults(passed, nil)
+			recordMemberHealthPercent(len(passed), len(ips)-skipped)
+			return false, reasonProbeDeadlineExceeded, probeDeadlineMessage(ips[i:], passed, budget), nil
+		}
+		if err != nil || !wellknownReady {
+			// the fan out stops at the first bad member - members after it
+			// were not probed this cycle and appear in neither list
+			c.recordProbeCycleOutcome(len(passed) > 0)
+			c.recordProbeResults(passed, []string{ip})
+			recordMemberHealthPercent(len(passed), len(ips)-skipped)
+			c.recordMemberFailure(ip, wellknownReason, wellknownMsg, err)
+			return wellknownReady, wellknownReason, wellknownMsg, err
+		}
+		c.clearMemberBackoff(ip)
+		passed = append(passed, ip)
+	}
+	c.recordProbeCycleOutcome(true)
+	c.recordProbeResults(passed, nil)
+	recordMemberHealthPercent(len(passed), len(ips)-skipped)
+
+	// all members agree, drop any stored documents so the debug state stays bounded
+	c.clearProbeMetadata()
+
+	return true, "", "", nil
+}
+
+// unexpectedStatusResult classifies a non-200 answer from the well-known
+// endpoint.  401 and 403 get their own loud reason: the discovery endpoint
+// must be anonymous, so a member demanding credentials there is misconfigured
+// in a way that breaks every unauthenticated OAuth client, not merely
+// unhealthy like the generic non-200 case.
+func unexpectedStatusResult(resp *http.Response, wellKnown string) (string, string) {
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		return reasonDiscoveryAuthRequired, fmt.Sprintf("got '%s' status from the OAuth well-known %s endpoint - the discovery endpoint unexpectedly requires authentication, which breaks all unauthenticated OAuth clients", resp.Status, wellKnown)
+	}
+	return reasonUnexpectedStatus, fmt.Sprintf("got '%s' status while trying to GET the OAuth well-known %s endpoint data", resp.Status, wellKnown)
+}
+
+// isConnectionRefused matches the probe error produced when nothing listens at
+// the member's address anymore.
+func isConnectionRefused(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "connection refused")
+}
+
+// defaultProbeCycleBudget bounds one probe cycle across all members combined.
+// generous enough that healthy control planes never see it, small enough that
+// a cycle of slow members cannot stall the sync loop.
+const defaultProbeCycleBudget = 30 * time.Second
+
+// probeCycleBudgetOrDefault returns the effective probe cycle budget.
+func (c *authOperator) probeCycleBudgetOrDefault() time.Duration {
+	if c.probeCycleBudget > 0 {
+		return c.probeCycleBudget
+	}
+	return defaultProbeCycleBudget
+}
+
+// probeDeadlineMessage describes a probe cycle cut short by its budget, naming
+// the members that were not probed within the deadline.
+func probeDeadlineMessage(notProbed, passed []string, budget time.Duration) string {
+	return fmt.Sprintf("members %s were not probed within the %s probe cycle deadline (%d members passed before the budget ran out)", strings.Join(notProbed, ", "), budget, len(passed))
+}
+
+// isProbeDeadlineExceeded matches the probe error produced when the cycle
+// deadline cuts a request short.
+func isProbeDeadlineExceeded(err error) bool {
+	return err != nil && strings.Contains(err.Error(), context.DeadlineExceeded.Error())
+}
+
+// deadlineTransport applies what is left of the probe cycle budget to every
+// request sent through it, so one slow member cannot silently consume the
+// budget of all the members after it.  the remaining time is measured with the
+// operator clock so tests with an injected clock see the full budget.
+type deadlineTransport struct {
+	deadline time.Time
+	now      func() time.Time
+	delegate http.RoundTripper
+}
+
+func (t *deadlineTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, cancel := context.WithTimeout(req.Context(), t.deadline.Sub(t.now()))
+	resp, err := t.delegate.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	// the context has to stay alive while the caller reads the body - cancel
+	// when the body is closed instead of on return
+	resp.Body = &cancelOnCloseBody{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// cancelOnCloseBody releases a request context when the response body is
+// closed.
+type cancelOnCloseBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnCloseBody) Close() error {
+	b.cancel()
+	return b.ReadCloser.Close()
+}
+
+// errShuttingDown is the probe outcome while the operator is stopping.  it is
+// deliberately a hard error that is never cached and never recorded as a
+// member failure - a probe cut short by shutdown says nothing about health.
+var errShuttingDown = fmt.Errorf("the operator is shutting down, the probe cycle was aborted")
+
+// isShuttingDownError matches errShuttingDown even through wrapping.
+func isShuttingDownError(err error) bool {
+	return err != nil && strings.Contains(err.Error(), errShuttingDown.Error())
+}
+
+// shuttingDown reports whether the shutdown channel has been closed.  a nil
+// channel (bare structs, tests) never reports shutdown.
+func (c *authOperator) shuttingDown() bool {
+	if c.shutdown == nil {
+		return false
+	}
+	select {
+	case <-c.shutdown:
+		return true
+	default:
+		return false
+	}
+}
+
+// shutdownTransport cancels every request sent through it the moment the stop
+// channel closes, so operator shutdown aborts in-flight probe round-trips
+// promptly instead of waiting out their timeouts.
+type shutdownTransport struct {
+	stop     <-chan struct{}
+	delegate http.RoundTripper
+}
+
+func (t *shutdownTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, cancel := context.WithCancel(req.Context())
+	go func() {
+		select {
+		case <-t.stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	resp, err := t.delegate.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	// the context has to stay alive while the caller reads the body
+	resp.Body = &cancelOnCloseBody{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// probe backoff bounds for persistently failing members: the wait doubles per
+// consecutive failure starting at the base and never exceeds the max, so a
+// flapping member recovers quickly while a dead one is mostly left alone.
+const (
+	probeMemberBackoffBase = 10 * time.Second
+	probeMemberBackoffMax  = 5 * time.Minute
+)
+
+// memberBackoff tracks consecutive probe failures against a single KAS member
+// together with the failure outcome, so the member can be held off and its
+// last result replayed instead of re-probing it at full frequency.
+type memberBackoff struct {
+	failures int
+	until    time.Time
+	reason   string
+	message  string
+	hardErr  string
+}
+
+// memberInBackoff returns the stored failure and the remaining backoff for a
+// member that failed recently and is still inside its backoff window.
+func (c *authOperator) memberInBackoff(ip string) (*memberBackoff, time.Duration, bool) {
+	c.probeStateLock.Lock()
+	defer c.probeStateLock.Unlock()
+
+	backoff, ok := c.memberBackoffs[ip]
+	if !ok {
+		return nil, 0, false
+	}
+	remaining := backoff.until.Sub(c.now())
+	if remaining <= 0 {
+		return nil, 0, false
+	}
+	return backoff, remaining, true
+}
+
+// recordMemberFailure counts a probe failure against the member and extends
+// its backoff exponentially.
+func (c *authOperator) recordMemberFailure(ip, reason, message string, err error) {
+	c.probeStateLock.Lock()
+	defer c.probeStateLock.Unlock()
+
+	if c.memberBackoffs == nil {
+		c.memberBackoffs = map[string]*memberBackoff{}
+	}
+	backoff := c.memberBackoffs[ip]
+	if backoff == nil {
+		backoff = &memberBackoff{}
+		c.memberBackoffs[ip] = backoff
+	}
+	backoff.failures++
+	wait := c.memberBackoffBaseOrDefault() << uint(backoff.failures-1)
+	if wait <= 0 || wait > c.memberBackoffMaxOrDefault() {
+		wait = c.memberBackoffMaxOrDefault()
+	}
+	backoff.until = c.now().Add(wait)
+	backoff.reason, backoff.message, backoff.hardErr = reason, message, ""
+	if err != nil {
+		backoff.hardErr = err.Error()
+	}
+}
+
+// clearMemberBackoff resets the backoff after the member passes a probe.
+func (c *authOperator) clearMemberBackoff(ip string) {
+	c.probeStateLock.Lock()
+	defer c.probeStateLock.Unlock()
+
+	delete(c.memberBackoffs, ip)
+}
+
+// pruneMemberBackoffs drops backoff state for members that left the endpoint
+// set so a returning member starts with a clean slate.
+func (c *authOperator) pruneMemberBackoffs(ips []string) {
+	c.probeStateLock.Lock()
+	defer c.probeStateLock.Unlock()
+
+	current := sets.NewString(ips...)
+	for ip := range c.memberBackoffs {
+		if !current.Has(ip) {
+			delete(c.memberBackoffs, ip)
+		}
+	}
+}
+
+// defaultProbeBreakerThreshold is how many consecutive fully failed probe
+// cycles (no member passed at all) open the circuit breaker.
+const defaultProbeBreakerThreshold = 5
+
+// probeBreakerThresholdOrDefault returns the effective breaker threshold.
+func (c *authOperator) probeBreakerThresholdOrDefault() int {
+	if c.probeBreakerThreshold > 0 {
+		return c.probeBreakerThreshold
+	}
+	return defaultProbeBreakerThreshold
+}
+
+// recordProbeCycleOutcome feeds the circuit breaker: a cycle in which at
+// least one member passed resets the count, a fully failed cycle extends it.
+func (c *authOperator) recordProbeCycleOutcome(anyPassed bool) {
+	c.probeStateLock.Lock()
+	defer c.probeStateLock.Unlock()
+
+	if anyPassed {
+		c.breakerFailures = 0
+		return
+	}
+	c.breakerFailures++
+}
+
+// probeBreakerOpen returns the consecutive fully failed cycle count and
+// whether it has reached the threshold that opens the breaker.
+func (c *authOperator) probeBreakerOpen() (int, bool) {
+	c.probeStateLock.Lock()
+	defer c.probeStateLock.Unlock()
+
+	failures := c.breakerFailures
+	return failures, failures >= c.probeBreakerThresholdOrDefault()
+}
+
+// handleProbeBreaker surfaces the circuit breaker state as a condition so
+// reduced probing during an extended outage is visible, not something to
+// reverse engineer from quieter logs.
+func (c *authOperator) handleProbeBreaker(operatorConfig *operatorv1.Authentication) {
+	condition := operatorv1.OperatorCondition{
+		Type:   c.wellKnownPrefix() + "ProbeBreakerOpen",
+		Status: operatorv1.ConditionFalse,
+		Reason: "AsExpected",
+	}
+	if failures, open := c.probeBreakerOpen(); open {
+		condition.Status = operatorv1.ConditionTrue
+		condition.Reason = "BreakerOpen"
+		condition.Message = fmt.Sprintf("the well-known probe circuit breaker is open after %d consecutive fully failed probe cycles - a single member is probed per cycle until one answers", failures)
+	}
+	v1helpers.SetOperatorCondition(&operatorConfig.Status.Conditions, condition)
+}
+
+// checkWellknownEndpointReadyViaService probes the well-known endpoint through
+// the cluster-internal KAS service DNS name.  it is the fallback for when the
+// per-IP fan out cannot even enumerate the members (listErr) - the transport
+// already uses the service name for SNI so the same round tripper works here.
+// any result is annotated so consumers know it covers only one arbitrary
+// member behind the VIP.
+func (c *authOperator) checkWellknownEndpointReadyViaService(rt http.RoundTripper, route *routev1.Route, listErr error) (bool, string, string, error) {
+	ready, reason, msg, err := c.checkWellknownEndpointReady(fmt.Sprintf("%s:%d", kasServiceFullName, kasServicePort), rt, route)
+	if err != nil {
+		return false, "", "", fmt.Errorf("failed to get API server IPs (%v) and the service fallback probe also failed: %v", listErr, err)
+	}
+	if !ready {
+		return false, reason, fmt.Sprintf("%s (checked via the %s service because listing API server IPs failed: %v)", msg, kasServiceFullName, listErr), nil
+	}
+	return true, "", fmt.Sprintf("well-known is ready via the %s service, but only one arbitrary member was checked because listing API server IPs failed: %v", kasServiceFullName, listErr), nil
+}
+
+// recordProbeMetadata stores the last metadata document received from the given
+// KAS member.  when members disagree, having each member's actual document is
+// invaluable for debugging, so we keep it around for must-gather style
+// collection via LastProbeMetadata.  the documents contain no secrets but we
+// still only store them at high log verbosity to avoid useless memory growth.
+func (c *authOperator) recordProbeMetadata(apiIP string, body []byte) {
+	if !bool(klog.V(4)) {
+		return
+	}
+
+	c.probeStateLock.Lock()
+	defer c.probeStateLock.Unlock()
+
+	if c.lastProbeMetadata == nil {
+		c.lastProbeMetadata = map[string][]byte{}
+	}
+	c.lastProbeMetadata[apiIP] = body
+}
+
+// pruneProbeMetadata drops stored documents for members that are no longer part
+// of the endpoint set so the storage stays bounded by the control plane size.
+func (c *authOperator) pruneProbeMetadata(ips []string) {
+	c.probeStateLock.Lock()
+	defer c.probeStateLock.Unlock()
+
+	current := sets.NewString(ips...)
+	for ip := range c.lastProbeMetadata {
+		if !current.Has(ip) {
+			delete(c.lastProbeMetadata, ip)
+		}
+	}
+}
+
+// recordProbeResults atomically replaces the per-member outcome of the last
+// probe cycle.  the lists are bounded by the endpoint set size and rebuilt
+// from scratch every cycle, so stale members age out on their own.
+func (c *authOperator) recordProbeResults(passed, failed []string) {
+	c.probeStateLock.Lock()
+	defer c.probeStateLock.Unlock()
+
+	c.lastProbePassed = append([]string(nil), passed...)
+	c.lastProbeFailed = append([]string(nil), failed...)
+}
+
+// LastProbeResults returns the KAS members that passed and failed the last
+// well-known probe cycle.  correlated with the endpoint-to-node mapping this
+// pinpoints a bad master.  members the fan out never reached (it stops at the
+// first failure) appear in neither list, and both lists are empty when the
+// last cycle could not enumerate the members at all.
+func (c *authOperator) LastProbeResults() (passed, failed []string) {
+	c.probeStateLock.Lock()
+	defer c.probeStateLock.Unlock()
+
+	return append([]string(nil), c.lastProbePassed...), append([]string(nil), c.lastProbeFailed...)
+}
+
+func (c *authOperator) clearProbeMetadata() {
+	c.probeStateLock.Lock()
+	defer c.probeStateLock.Unlock()
+
+	c.lastProbeMetadata = nil
+}
+
+// LastProbeMetadata returns a copy of the last metadata document received per
+// KAS IP.  it is only populated at log level 4 and above and is empty whenever
+// the last check converged.
+func (c *authOperator) LastProbeMetadata() map[string]string {
+	c.probeStateLock.Lock()
+	defer c.probeStateLock.Unlock()
+
+	out := map[string]string{}
+	for ip, body := range c.lastProbeMetadata {
+		out[ip] = string(body)
+	}
+	return out
+}
+
+// defaultProbeMaxIdleConnsPerHost sizes the probe connection pool.  each probe
+// cycle talks to every KAS member once, so a handful of idle connections per
+// host covers overlapping syncs without hoarding sockets.
+const defaultProbeMaxIdleConnsPerHost = 4
+
+// probeMode selects which server identity the well-known probe verifies:
+// the KAS members directly, or end to end through the router.
+type probeMode int
+
+const (
+	// probeModeKAS connects to KAS members by IP, so the transport presents
+	// the KAS service name as SNI and trusts the SA CA bundle.
+	probeModeKAS probeMode = iota
+	// probeModeRoute connects through the router at the route host, so the
+	// transport must present the route host as SNI and trust the router CA -
+	// the router knows nothing about the KAS service name.
+	probeModeRoute
+)
+
+// probeTLSIdentity returns the SNI server name and trust bundle the probe
+// transport must be built with for the given mode.  factoring the selection
+// keeps either path from accidentally presenting the other's servername,
+// which breaks verification against any SNI-dependent serving cert.
+func probeTLSIdentity(mode probeMode, route *routev1.Route, serviceCA []byte, routerSecret *corev1.Secret, ingress *configv1.Ingress) (string, []byte, error) {
+	switch mode {
+	case probeModeKAS:
+		return kasServiceFullName, serviceCA, nil
+	case probeModeRoute:
+		if route == nil || len(route.Spec.Host) == 0 {
+			return "", nil, fmt.Errorf("cannot probe through the router without an admitted route host")
+		}
+		// a nil CA here means the router serves a globally trusted cert and
+		// the system roots apply, see routerSecretToCA
+		return route.Spec.Host, routerSecretToCA(route, routerSecret, ingress), nil
+	default:
+		return "", nil, fmt.Errorf("unknown probe mode %d", mode)
+	}
+}
+
+// oauthServiceFullName is the in-cluster DNS name of the oauth server's own
+// service, the third vantage point the metadata can be probed from.
+const oauthServiceFullName = targetName + "." + targetNamespace + ".svc"
+
+// handleOAuthServiceMetadata optionally probes the metadata document served
+// by the oauth server's own cluster-internal service.  the KAS members and
+// the route both sit between clients and the oauth server, so when metadata
+// drifts, this third vantage point tells apart "the oauth server itself
+// serves the wrong document" from "the server is right and the KAS
+// integration or route is stale".  opt-in via checkOAuthServiceMetadata
+// since the service name only resolves in-cluster.  the probe deliberately
+// shares nothing with the KAS member checks: it records no served state the
+// consistency handlers would mistake for the KAS-served document, and it
+// verifies the oauth server's own certificate, not the KAS service name.
+func (c *authOperator) handleOAuthServiceMetadata(operatorConfig *operatorv1.Authentication, route *routev1.Route) {
+	if !c.checkOAuthServiceMetadata {
+		return
+	}
+
+	condition := operatorv1.OperatorCondition{
+		Type:   "OAuthServiceMetadata",
+		Status: operatorv1.ConditionFalse,
+		Reason: "AsExpected",
+	}
+	defer func() {
+		v1helpers.SetOperatorCondition(&operatorConfig.Status.Conditions, condition)
+	}()
+
+	rt := c.oauthServiceRT
+	if rt == nil {
+		var err error
+		rt, err = c.oauthServiceProbeTransport()
+		if err != nil {
+			condition.Status = operatorv1.ConditionTrue
+			condition.Reason = "TransportError"
+			condition.Message = fmt.Sprintf("failed to build transport for the oauth service probe: %v", err)
+			return
+		}
+	}
+
+	served, reason, message, err := c.fetchServedMetadata(oauthServiceFullName, rt)
+	if err != nil {
+		condition.Status = operatorv1.ConditionTrue
+		condition.Reason = "ProbeFailed"
+		condition.Message = fmt.Sprintf("failed to probe the oauth service metadata: %v", err)
+		return
+	}
+	if served == nil {
+		condition.Status = operatorv1.ConditionTrue
+		condition.Reason = reason
+		condition.Message = fmt.Sprintf("the oauth service %s serves problematic metadata: %s", oauthServiceFullName, message)
+		return
+	}
+	if !metadataMatchesIgnoringExtraScopes(c.expectedMetadataStruct(route), served) {
+		condition.Status = operatorv1.ConditionTrue
+		condition.Reason = reasonMetadataMismatch
+		condition.Message = fmt.Sprintf("the oauth service %s serves problematic metadata: the document does not match the expected metadata for this route", oauthServiceFullName)
+	}
+}
+
+// oauthServiceProbeTransport builds the transport for probing the oauth
+// service directly, verifying its serving cert against the service CA the
+// way any in-cluster client of the service would.
+func (c *authOperator) oauthServiceProbeTransport() (http.RoundTripper, error) {
+	serviceCA, err := c.configMaps.ConfigMaps(targetNamespace).Get(serviceCAName, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the service CA config map: %v", err)
+	}
+	caData := []byte(serviceCA.Data[serviceCAKey])
+	if len(caData) == 0 {
+		return nil, fmt.Errorf("the service CA config map %s/%s has no %s", targetNamespace, serviceCAName, serviceCAKey)
+	}
+	return transportFor(oauthServiceFullName, caData, nil, nil)
+}
+
+// routeProbeTransport builds the transport for an end-to-end probe through
+// the router, verifying the route host identity instead of the KAS service
+// name.
+func (c *authOperator) routeProbeTransport(route *routev1.Route, routerSecret *corev1.Secret, ingress *configv1.Ingress) (http.RoundTripper, error) {
+	serverName, caData, err := probeTLSIdentity(probeModeRoute, route, nil, routerSecret, ingress)
+	if err != nil {
+		return nil, err
+	}
+	return transportFor(serverName, caData, nil, nil)
+}
+
+// probeTransportFor returns the cached probe transport, rebuilding it only
+// when the SNI server name, CA bundle or client credentials change.  reusing the transport
+// across resyncs keeps connections to the KAS members alive between probes,
+// saving a TLS handshake per member per sync on large control planes.
+func (c *authOperator) probeTransportFor(serverName string, caData []byte) (http.RoundTripper, error) {
+	hash := sha256.New()
+	hash.Write([]byte(serverName))
+	hash.Write(caData)
+	hash.Write(c.probeClientCert)
+	hash.Write(c.probeClientKey)
+	key := fmt.Sprintf("%x", hash.Sum(nil))
+
+	c.probeStateLock.Lock()
+	defer c.probeStateLock.Unlock()
+
+	if c.probeTransport != nil && c.probeTransportKey == key {
+		return c.probeTransport, nil
+	}
+
+	maxIdle := c.probeMaxIdleConnsPerHost
+	if maxIdle <= 0 {
+		maxIdle = defaultProbeMaxIdleConnsPerHost
+	}
+	rt, inner, err := pooledTransportFor(serverName, caData, c.probeClientCert, c.probeClientKey, maxIdle)
+	if err != nil {
+		return nil, err
+	}
+	if inner != nil && len(c.probeSourceAddress) != 0 {
+		dialer, err := c.probeDialer()
+		if err != nil {
+			return nil, err
+		}
+		inner.DialContext = dialer.DialContext
+	}
+	if inner != nil {
+		// hardened clusters expect nothing below the cluster TLS policy - the
+		// handshake itself fails rather than silently accepting a weak member
+		inner.TLSClientConfig.MinVersion = c.probeMinTLSVersionOrDefault()
+		if len(c.probeCipherSuites) != 0 {
+			inner.TLSClientConfig.CipherSuites = c.probeCipherSuites
+		}
+	}
+
+	// release the sockets held by the transport being replaced
+	if c.probeTransportInner != nil {
+		c.probeTransportInner.CloseIdleConnections()
+	}
+	c.probeTransport = rt
+	c.probeTransportInner = inner
+	c.probeTransportKey = key
+	return rt, nil
+}
+
+// probeDialer returns the dialer the probe transport uses.  when
+// probeSourceAddress is set the dialer binds outgoing connections to that
+// local IP so support can test reachability from a specific interface when
+// diagnosing asymmetric routing - otherwise this is the standard dialer.
+func (c *authOperator) probeDialer() (*net.Dialer, error) {
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}
+	if len(c.probeSourceAddress) != 0 {
+		ip := net.ParseIP(c.probeSourceAddress)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid probe source address %q: not an IP address", c.probeSourceAddress)
+		}
+		// port zero - the kernel still picks an ephemeral port, only the
+		// source IP is pinned
+		dialer.LocalAddr = &net.TCPAddr{IP: ip}
+	}
+	return dialer, nil
+}
+
+// defaultProbeMinTLSVersion matches the minimum TLS version the rest of the
+// cluster is expected to speak.
+const defaultProbeMinTLSVersion = tls.VersionTLS12
+
+// probeMinTLSVersionOrDefault returns the minimum TLS version the probe
+// enforces.
+func (c *authOperator) probeMinTLSVersionOrDefault() uint16 {
+	if c.probeMinTLSVersion == 0 {
+		return defaultProbeMinTLSVersion
+	}
+	return c.probeMinTLSVersion
+}
+
+// noteNegotiatedCipherSuite records the cipher suite a probe connection
+// negotiated: the info metric always reflects the latest connection, a
+// change is logged at the support log level, and - when an approved set is
+// configured - negotiating outside it is warned about.  FIPS auditors get
+// evidence from connections the operator makes anyway.
+func (c *authOperator) noteNegotiatedCipherSuite(suite uint16) {
+	c.probeStateLock.Lock()
+	changed := suite != c.lastNegotiatedCipher
+	c.lastNegotiatedCipher = suite
+	c.probeStateLock.Unlock()
+
+	name := tls.CipherSuiteName(suite)
+	recordProbeCipherSuite(name)
+	if !changed {
+		return
+	}
+
+	klog.V(2).Infof("the well-known probe negotiated cipher suite %s", name)
+	if len(c.approvedProbeCiphers) == 0 {
+		return
+	}
+	for _, approved := range c.approvedProbeCiphers {
+		if approved == suite {
+			return
+		}
+	}
+	klog.Warningf("the well-known probe negotiated cipher suite %s, which is not in the approved set: %s", name, strings.Join(cipherSuiteNames(c.approvedProbeCiphers), ", "))
+}
+
+// cipherSuiteNames renders cipher suite constants for log messages.
+func cipherSuiteNames(suites []uint16) []string {
+	names := make([]string, 0, len(suites))
+	for _, suite := range suites {
+		names = append(names, tls.CipherSuiteName(suite))
+	}
+	return names
+}
+
+// tlsVersionName renders a TLS version constant for condition messages.
+func tlsVersionName(version uint16) string {
+	switch version {
+	case tls.VersionTLS10:
+		return "TLS 1.0"
+	case tls.VersionTLS11:
+		return "TLS 1.1"
+	case tls.VersionTLS12:
+		return "TLS 1.2"
+	case tls.VersionTLS13:
+		return "TLS 1.3"
+	default:
+		return fmt.Sprintf("unknown TLS version %#x", version)
+	}
+}
+
+// markEndpointsReady records that the kube api server endpoints had ready
+// addresses at least once in this process.
+func (c *authOperator) markEndpointsReady() {
+	c.probeStateLock.Lock()
+	defer c.probeStateLock.Unlock()
+
+	c.sawReadyEndpoints = true
+}
+
+// endpointsEverReady reports whether ready endpoints were ever observed.
+func (c *authOperator) endpointsEverReady() bool {
+	c.probeStateLock.Lock()
+	defer c.probeStateLock.Unlock()
+
+	return c.sawReadyEndpoints
+}
+
+// restoreEndpointsBootstrapMarker recovers the "endpoints were ready before"
+// marker across operator restarts: an operator that reported Available must
+// have seen ready endpoints, so a restart during an endpoint outage still
+// classifies the outage as a regression rather than bootstrap.
+func (c *authOperator) restoreEndpointsBootstrapMarker(operatorConfig *operatorv1.Authentication) {
+	if c.endpointsEverReady() {
+		return
+	}
+	if condition := v1helpers.FindOperatorCondition(operatorConfig.Status.Conditions, operatorv1.OperatorStatusTypeAvailable); condition != nil && condition.Status == operatorv1.ConditionTrue {
+		c.markEndpointsReady()
+	}
+}
+
+// endpointsNotReadyError marks the case where the kubernetes endpoints exist
+// but carry no ready addresses - the readiness check reports it as progressing
+// with reason WaitingForEndpoints instead of marking the operator degraded.
+type endpointsNotReadyError struct {
+	message string
+}
+
+func (e *endpointsNotReadyError) Error() string {
+	return e.message
+}
+
+// kasLocation returns the namespace and name of the kube api server service
+// and endpoints objects to probe.
+func (c *authOperator) kasLocation() (string, string) {
+	namespace, name := c.kasNamespace, c.kasName
+	if len(namespace) == 0 {
+		namespace = corev1.NamespaceDefault
+	}
+	if len(name) == 0 {
+		name = kasServiceAndEndpointName
+	}
+	return namespace, name
+}
+
+// probePortOrDefault returns the port the well-known probe connects to: the
+// configured override, or the resolved endpoint target port when none is set.
+// some deployments serve the discovery document on a side port next to the
+// main API - the member addresses still come from the endpoints, only the
+// port the probe dials is swapped.
+func (c *authOperator) probePortOrDefault(targetPort int) int {
+	if c.probePort > 0 {
+		return c.probePort
+	}
+	return targetPort
+}
+
+// manualEndpointsNote annotates endpoint failures when the kube api server
+// service has no selector: without one the endpoints controller does not
+// reconcile the endpoints, so a stale member list pointing at dead masters
+// will not self-heal and the admin must fix the endpoints object by hand.
+// knowing that up front changes the remediation, so say it in the condition.
+func manualEndpointsNote(service *corev1.Service) string {
+	if len(service.Spec.Selector) != 0 {
+		return ""
+	}
+	return fmt.Sprintf(" (the %s/%s service has no selector - its endpoints are managed outside the endpoints controller and must be fixed manually if stale)", service.Namespace, service.Name)
+}
+
+func (c *authOperator) getAPIServerIPs() ([]string, error) {
+	kasNamespace, kasName := c.kasLocation()
+
+	kasService, err := c.services.Services(kasNamespace).Get(kasName, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get kube api server service: %v", err)
+	}
+
+	targetPort, portName, ok := getKASTargetPortFromService(kasService)
+	if !ok {
+		return nil, fmt.Errorf("unable to find kube api server service target port: %#v", kasService)
+	}
+
+	kasEndpoint, err := c.endpoints.Endpoints(kasNamespace).Get(kasName, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get kube api server endpoints: %v", err)
+	}
+
+	// a named targetPort may resolve to a different number on some pods, and
+	// the endpoints controller surfaces that as separate subsets - every
+	// matching subset contributes its addresses under its own concrete port
+	// instead of one global number that would probe part of the members on
+	// the wrong port.  on dual-stack clusters the addresses mix families -
+	// JoinHostPort brackets the IPv6 members so the probe URLs stay valid,
+	// and the families are grouped and sorted so messages that enumerate the
+	// members read consistently across syncs
+	var v4, v6 []string
+	matched := false
+	for _, subset := range kasEndpoint.Subsets {
+		subsetPort, ok := subsetKASTargetPort(subset, targetPort, portName)
+		if !ok {
+			continue
+		}
+		matched = true
+
+		if len(subset.NotReadyAddresses) != 0 || len(subset.Addresses) == 0 {
+			return nil, &endpointsNotReadyError{message: fmt.Sprintf("kube api server endpoints is not ready%s: %#v", manualEndpointsNote(kasService), kasEndpoint)}
+		}
+
+		for _, address := range subset.Addresses {
+			// a malformed address would turn the probe URL into garbage with
+			// a confusing failure mode - skip it and probe the valid members
+			ip := net.ParseIP(address.IP)
+			if ip == nil {
+				klog.Warningf("skipping malformed kube api server endpoint address %q", address.IP)
+				continue
+			}
+			hostPort := net.JoinHostPort(address.IP, strconv.Itoa(c.probePortOrDefault(subsetPort)))
+			if ip.To4() != nil {
+				v4 = append(v4, hostPort)
+			} else {
+				v6 = append(v6, hostPort)
+			}
+		}
+	}
+	if !matched {
+		// none of the subsets carry the expected port - list what is actually
+		// there so a named/numbered port mismatch is immediately visible
+		return nil, fmt.Errorf("unable to find kube api server endpoints port: expected target port %d, endpoints have ports %s%s", targetPort, strings.Join(endpointPorts(kasEndpoint), ", "), manualEndpointsNote(kasService))
+	}
+	if len(v4)+len(v6) == 0 {
+		return nil, fmt.Errorf("kube api server endpoints contain no valid IP addresses: %#v", kasEndpoint.Subsets)
+	}
+	sort.Strings(v4)
+	sort.Strings(v6)
+	ips := append(v4, v6...)
+	c.markEndpointsReady()
+	return ips, nil
+}
+
+// endpointPorts renders the ports present on the endpoints for diagnostics.
+func endpointPorts(endpoints *corev1.Endpoints) []string {
+	ports := sets.NewString()
+	for _, subset := range endpoints.Subsets {
+		for _, port := range subset.Ports {
+			ports.Insert(fmt.Sprintf("%s/%d/%s", port.Name, port.Port, port.Protocol))
+		}
+	}
+	return ports.List()
+}
+
+// getKASTargetPortFromService returns the target port number and the port's
+// name, so subsets carrying the target under a different number can still be
+// matched by name.
+func getKASTargetPortFromService(service *corev1.Service) (int, string, bool) {
+	for _, port := range service.Spec.Ports {
+		if targetPort := port.TargetPort.IntValue(); targetPort != 0 && port.Protocol == corev1.ProtocolTCP && int(port.Port) == kasServicePort {
+			return targetPort, port.Name, true
+		}
+	}
+	return 0, "", false
+}
+
+// subsetKASTargetPort returns the concrete TCP port this subset serves the
+// KAS target on.  a subset normally matches the service target port by
+// number, but a named targetPort may resolve to a different number on some
+// pods - those subsets match by the port's name and contribute their own
+// number, so heterogeneous members are probed where they actually listen.
+func subsetKASTargetPort(subset corev1.EndpointSubset, targetPort int, portName string) (int, bool) {
+	for _, port := range subset.Ports {
+		if port.Protocol == corev1.ProtocolTCP && int(port.Port) == targetPort {
+			return targetPort, true
+		}
+	}
+	if len(portName) != 0 {
+		for _, port := range subset.Ports {
+			if port.Protocol == corev1.ProtocolTCP && port.Name == portName {
+				return int(port.Port), true
+			}
+		}
+	}
+	return 0, false
+}
+
+func (c *authOperator) checkWellknownEndpointReady(apiIP string, rt http.RoundTripper, route *routev1.Route) (bool, string, string, error) {
+	wellKnown := "https://" + apiIP + oauthMetadataAPIEndpoint
+
+	req, err := http.NewRequest(http.MethodGet, wellKnown, nil)
+	if err != nil {
+		return false, "", "", fmt.Errorf("failed to build request to well-known %s: %v", wellKnown, err)
+	}
+	req.Header.Set("User-Agent", c.probeUserAgent())
+	req, finishSpan := c.traceProbeRequest(req, apiIP)
+	defer finishSpan()
+
+	resp, err := rt.RoundTrip(req)
+	if err != nil {
+		return false, "", "", fmt.Errorf("failed to GET well-known %s: %v", wellKnown, err)
+	}
+	defer resp.Body.Close()
+
+	// defense in depth: the probe transport already enforces the minimum but
+	// an injected round tripper may not - flag a member that negotiated below
+	// the cluster TLS policy instead of treating its answer as healthy
+	if minVersion := c.probeMinTLSVersionOrDefault(); resp.TLS != nil && resp.TLS.Version < minVersion {
+		return false, reasonWeakTLSVersion, fmt.Sprintf("the server behind well-known %s negotiated %s, below the required minimum %s", wellKnown, tlsVersionName(resp.TLS.Version), tlsVersionName(minVersion)), nil
+	}
+
+	// the connection is already established - capture which cipher suite it
+	// negotiated as audit evidence at no extra cost
+	if resp.TLS != nil {
+		c.noteNegotiatedCipherSuite(resp.TLS.CipherSuite)
+	}
+
+	// the probe connects by IP with the service name as SNI, so verification
+	// can succeed off the loaded CA even when the serving cert lacks the
+	// service DNS name - optionally insist that the name is really there
+	if c.strictSNICheck && resp.TLS != nil && len(resp.TLS.PeerCertificates) > 0 {
+		if err := resp.TLS.PeerCertificates[0].VerifyHostname(kasServiceFullName); err != nil {
+			return false, reasonServingCertMissingName, fmt.Sprintf("the serving certificate presented behind well-known %s does not include the expected name %s: %v", wellKnown, kasServiceFullName, err), nil
+		}
+	}
+
+	if resp.StatusCode != 200 {
+		reason, message := unexpectedStatusResult(resp, wellKnown)
+		return false, reason, message, nil
+	}
+
+	var receivedValues map[string]interface{}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		// a failure part way through the body is retryable - do not escalate
+		// it to a hard error that would mark us degraded
+		return false, reasonBodyReadFailed, fmt.Sprintf("failed to read well-known %s body: %v", wellKnown, err), nil
+	}
+	c.recordProbeMetadata(apiIP, body)
+	// an empty 200 points at a proxy or routing misconfiguration, which wants
+	// a different remediation than the malformed-JSON case below
+	if len(bytes.TrimSpace(body)) == 0 {
+		return false, reasonEmptyMetadataBody, fmt.Sprintf("the well-known %s endpoint returned empty body - a proxy or routing layer between the operator and the server is likely eating the response", wellKnown), nil
+	}
+	if err := unmarshalMetadata(body, &receivedValues); err != nil {
+		return false, reasonMalformedMetadata, fmt.Sprintf("failed to unmarshal well-known %s JSON: %v", wellKnown, err), nil
+	}
+	c.recordServedIssuer(receivedValues)
+
+	// a required field missing outright means the answering server is not
+	// fully configured as an OAuth server, which is a different problem than
+	// the host or issuer drift a value mismatch points at - name the field
+	// before the structural comparison can reduce it to a generic mismatch
+	if missing := missingRequiredMetadataFields(receivedValues); len(missing) > 0 {
+		return false, reasonMissingMetadataField, fmt.Sprintf("the well-known %s response is missing required field %s", wellKnown, strings.Join(missing, ", ")), nil
+	}
+
+	// hidden QE mode: compare against a committed golden document instead of
+	// the dynamically computed expectation
+	if golden, err := expectedMetadataOverride(); err != nil {
+		return false, "", "", fmt.Errorf("failed to load expected metadata override: %v", err)
+	} else if golden != nil {
+		if diffs := metadataDiff(golden, receivedValues); len(diffs) > 0 {
+			return false, reasonMetadataMismatch, fmt.Sprintf("the well-known %s endpoint does not match the expected metadata file: %s", wellKnown, strings.Join(diffs, "; ")), nil
+		}
+		return true, "", "", nil
+	}
+
+	required := c.requiredScopes
+	if required == nil {
+		required = defaultRequiredScopes()
+	}
+	if missing := missingRequiredScopes(required, receivedValues); len(missing) > 0 {
+		return false, reasonMetadataMismatch, fmt.Sprintf("the well-known %s endpoint does not advertise the required scopes %v", wellKnown, missing), nil
+	}
+
+	expectedMetadata := c.expectedMetadataStruct(route)
+	if !metadataMatchesIgnoringExtraScopes(expectedMetadata, receivedValues) {
+		// during an ingress domain migration a member may briefly still serve
+		// metadata built around the old issuer - accept any configured
+		// transitional issuer so the migration does not flap readiness
+		if matched, ok := c.matchesAcceptableIssuer(receivedValues); ok {
+			klog.V(2).Infof("the well-known %s endpoint matched the transitional acceptable issuer %q instead of the canonical issuer %q", wellKnown, matched, c.issuerURL(route))
+			return true, "", "", nil
+		}
+		// the probe connects by IP, so a server that builds its issuer from
+		// the incoming connection echoes an IP literal back - that is not a
+		// generic drift, it means the OAuth server does not know its public
+		// host, so call it out explicitly
+		if ip, ok := issuerIPLiteral(receivedValues); ok {
+			return false, reasonIssuerIsIPAddress, fmt.Sprintf("the well-known %s endpoint reports the IP literal %s as its issuer instead of the expected %s - the OAuth server is not aware of its public host", wellKnown, ip, c.issuerURL(route)), nil
+		}
+		return false, reasonMetadataMismatch, fmt.Sprintf("the value returned by the well-known %s endpoint does not match expectations", wellKnown), nil
+	}
+
+	return true, "", "", nil
+}
+
+// matchesAcceptableIssuer reports whether the received metadata matches the
+// expectation rebuilt around any of the configured transitional issuers, and
+// which one matched.  the set is only populated during an ingress domain
+// migration - once the migration completes it narrows back to nothing and the
+// canonical issuer is the only acceptable one again.
+func (c *authOperator) matchesAcceptableIssuer(receivedValues map[string]interface{}) (string, bool) {
+	for _, issuer := range c.acceptableIssuers {
+		if metadataMatchesIgnoringExtraScopes(metadataStructForIssuer(issuer), receivedValues) {
+			return issuer, true
+		}
+	}
+	return "", false
+}
+
+// issuerIPLiteral returns the host when the document's issuer points at an IP
+// literal (e.g. "https://10.0.0.1:6443") instead of a DNS name.
+func issuerIPLiteral(receivedValues map[string]interface{}) (string, bool) {
+	issuer, ok := receivedValues["issuer"].(string)
+	if !ok {
+		return "", false
+	}
+	parsed, err := url.Parse(issuer)
+	if err != nil {
+		return "", false
+	}
+	host := parsed.Hostname()
+	if net.ParseIP(host) == nil {
+		return "", false
+	}
+	return host, true
+}
+
+// missingRequiredMetadataFields returns the required endpoint fields the
+// received metadata document lacks or leaves empty.  these are the fields
+// every OAuth client needs to do anything at all - a document without them
+// cannot have come from a fully configured OAuth server.
+func missingRequiredMetadataFields(receivedValues map[string]interface{}) []string {
+	var missing []string
+	for _, field := range []string{"authorization_endpoint", "token_endpoint"} {
+		if value, ok := receivedValues[field].(string); !ok || len(value) == 0 {
+			missing = append(missing, field)
+		}
+	}
+	return missing
+}
+
+// unmarshalMetadata decodes the body into receivedValues and requires that the
+// body is exactly one JSON document.  some broken proxies append garbage after
+// an otherwise valid response - json.Unmarshal would reject that with a generic
+// syntax error, hiding the fact that the document itself was fine, so report
+// the trailing data explicitly instead.
+func unmarshalMetadata(body []byte, receivedValues *map[string]interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	if err := decoder.Decode(receivedValues); err != nil {
+		return err
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return fmt.Errorf("response contained trailing non-JSON data after the metadata document")
+	}
+	return nil
+}
+
+// wellKnownExpectedMetadataEnv optionally points at a JSON file containing the
+// exact metadata document the well-known endpoints are expected to serve.
+// when set, the probe compares against that golden document instead of the
+// dynamically computed expectation.  this is a testing hook for QE contract
+// tests, not a supported configuration.
+const wellKnownExpectedMetadataEnv = "OPERATOR_WELLKNOWN_EXPECTED_METADATA_FILE"
+
+func expectedMetadataOverride() (map[string]interface{}, error) {
+	path := os.Getenv(wellKnownExpectedMetadataEnv)
+	if len(path) == 0 {
+		return nil, nil
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s=%s: %v", wellKnownExpectedMetadataEnv, path, err)
+	}
+	var golden map[string]interface{}
+	if err := json.Unmarshal(data, &golden); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal %s=%s: %v", wellKnownExpectedMetadataEnv, path, err)
+	}
+	return golden, nil
+}
+
+// metadataDiff renders the per-key differences between the expected and
+// received documents so a mismatch message points straight at the broken
+// field instead of a bare "does not match".
+func metadataDiff(expected, received map[string]interface{}) []string {
+	keys := sets.NewString()
+	for key := range expected {
+		keys.Insert(key)
+	}
+	for key := range received {
+		keys.Insert(key)
+	}
+
+	var diffs []string
+	for _, key := range keys.List() {
+		expectedValue, expectedOK := expected[key]
+		receivedValue, receivedOK := received[key]
+		switch {
+		case !expectedOK:
+			diffs = append(diffs, fmt.Sprintf("unexpected key %q", key))
+		case !receivedOK:
+			diffs = append(diffs, fmt.Sprintf("missing key %q", key))
+		case !reflect.DeepEqual(expectedValue, receivedValue):
+			diffs = append(diffs, fmt.Sprintf("key %q: expected %v, received %v", key, expectedValue, receivedValue))
+		}
+	}
+	return diffs
+}
+
+// missingRequiredScopes returns the required scopes the server does not
+// advertise in scopes_supported.
+func missingRequiredScopes(required []string, receivedValues map[string]interface{}) []string {
+	advertised := sets.NewString()
+	if scopes, ok := receivedValues[scopesSupportedKey].([]interface{}); ok {
+		for _, scope := range scopes {
+			if s, ok := scope.(string); ok {
+				advertised.Insert(s)
+			}
+		}
+	}
+	return sets.NewString(required...).Difference(advertised).List()
+}
+
+// metadataMatchesIgnoringExtraScopes compares the metadata documents field by
+// field but leaves scopes_supported to missingRequiredScopes, which allows
+// additive scope customization without breaking readiness.
+func metadataMatchesIgnoringExtraScopes(expected, received map[string]interface{}) bool {
+	expectedCopy := map[string]interface{}{}
+	for key, value := range expected {
+		expectedCopy[key] = value
+	}
+	receivedCopy := map[string]interface{}{}
+	for key, value := range received {
+		receivedCopy[key] = value
+	}
+	delete(expectedCopy, scopesSupportedKey)
+	delete(receivedCopy, scopesSupportedKey)
+	return reflect.DeepEqual(expectedCopy, receivedCopy)
+}
@@ -0,0 +1,1883 @@
+package operator2
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/klog"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	configv1 "github.com/openshift/api/config/v1"
+	operatorv1 "github.com/openshift/api/operator/v1"
+	routev1 "github.com/openshift/api/route/v1"
+	configfake "github.com/openshift/client-go/config/clientset/versioned/fake"
+	"github.com/openshift/library-go/pkg/operator/events"
+	"github.com/openshift/library-go/pkg/operator/v1helpers"
+)
+
+func testRoute(host string) *routev1.Route {
+	return &routev1.Route{
+		ObjectMeta: defaultMeta(),
+		Spec: routev1.RouteSpec{
+			Host: host,
+		},
+	}
+}
+
+func testKASService() *corev1.Service {
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: kasServiceAndEndpointName, Namespace: corev1.NamespaceDefault},
+		Spec: corev1.ServiceSpec{
+			Selector: map[string]string{"apiserver": "true"},
+			Ports: []corev1.ServicePort{
+				{Protocol: corev1.ProtocolTCP, Port: int32(kasServicePort), TargetPort: intstr.FromInt(6443)},
+			},
+		},
+	}
+}
+
+func testKASEndpoints(port int32, ips ...string) *corev1.Endpoints {
+	addresses := make([]corev1.EndpointAddress, 0, len(ips))
+	for _, ip := range ips {
+		addresses = append(addresses, corev1.EndpointAddress{IP: ip})
+	}
+	return &corev1.Endpoints{
+		ObjectMeta: metav1.ObjectMeta{Name: kasServiceAndEndpointName, Namespace: corev1.NamespaceDefault},
+		Subsets: []corev1.EndpointSubset{
+			{
+				Addresses: addresses,
+				Ports:     []corev1.EndpointPort{{Name: "https", Port: port, Protocol: corev1.ProtocolTCP}},
+			},
+		},
+	}
+}
+
+func TestGetAPIServerIPsPortMismatchDiagnostics(t *testing.T) {
+	// the endpoints carry a port, just not the one derived from the service
+	kubeClient := kubefake.NewSimpleClientset(testKASService(), testKASEndpoints(6444, "10.0.0.1"))
+	c := &authOperator{
+		services:  kubeClient.CoreV1(),
+		endpoints: kubeClient.CoreV1(),
+	}
+
+	_, err := c.getAPIServerIPs()
+	if err == nil {
+		t.Fatal("expected an error for the port mismatch")
+	}
+	for _, want := range []string{"6443", "https/6444/TCP"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error should mention %q, got: %v", want, err)
+		}
+	}
+}
+
+func TestGetAPIServerIPsSkipsMalformedAddresses(t *testing.T) {
+	endpoints := testKASEndpoints(6443, "10.0.0.1", "not-an-ip", "")
+	kubeClient := kubefake.NewSimpleClientset(testKASService(), endpoints)
+	c := &authOperator{
+		services:  kubeClient.CoreV1(),
+		endpoints: kubeClient.CoreV1(),
+	}
+
+	ips, err := c.getAPIServerIPs()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(ips) != 1 || ips[0] != "10.0.0.1:6443" {
+		t.Errorf("expected only the valid address, got %v", ips)
+	}
+
+	// all-malformed addresses are an error, not an empty probe set
+	kubeClient = kubefake.NewSimpleClientset(testKASService(), testKASEndpoints(6443, "not-an-ip"))
+	c.services, c.endpoints = kubeClient.CoreV1(), kubeClient.CoreV1()
+	if _, err := c.getAPIServerIPs(); err == nil || !strings.Contains(err.Error(), "no valid IP addresses") {
+		t.Errorf("expected a no-valid-addresses error, got: %v", err)
+	}
+}
+
+func TestGetAPIServerIPsCustomLocation(t *testing.T) {
+	service := testKASService()
+	service.Namespace, service.Name = "test-ns", "test-kas"
+	endpoints := testKASEndpoints(6443, "10.0.0.1")
+	endpoints.Namespace, endpoints.Name = "test-ns", "test-kas"
+
+	kubeClient := kubefake.NewSimpleClientset(service, endpoints)
+	c := &authOperator{
+		services:     kubeClient.CoreV1(),
+		endpoints:    kubeClient.CoreV1(),
+		kasNamespace: "test-ns",
+		kasName:      "test-kas",
+	}
+
+	ips, err := c.getAPIServerIPs()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(ips) != 1 || ips[0] != "10.0.0.1:6443" {
+		t.Errorf("getAPIServerIPs() = %v, want the test namespace member", ips)
+	}
+}
+
+func TestGetAPIServerIPsProbePortOverride(t *testing.T) {
+	// the endpoint subset is still matched by the service target port, only
+	// the port the probe dials is swapped for the override
+	kubeClient := kubefake.NewSimpleClientset(testKASService(), testKASEndpoints(6443, "10.0.0.1"))
+	c := &authOperator{
+		services:  kubeClient.CoreV1(),
+		endpoints: kubeClient.CoreV1(),
+		probePort: 7443,
+	}
+
+	ips, err := c.getAPIServerIPs()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(ips) != 1 || ips[0] != "10.0.0.1:7443" {
+		t.Errorf("getAPIServerIPs() = %v, want the member on the override port", ips)
+	}
+
+	// without the override the resolved target port is used as before
+	c.probePort = 0
+	ips, err = c.getAPIServerIPs()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(ips) != 1 || ips[0] != "10.0.0.1:6443" {
+		t.Errorf("getAPIServerIPs() = %v, want the member on the target port", ips)
+	}
+}
+
+func TestGetAPIServerIPsDualStack(t *testing.T) {
+	// a dual-stack subset, deliberately out of order
+	endpoints := testKASEndpoints(6443, "fd02::2", "10.0.0.2", "fd02::1", "10.0.0.1")
+	kubeClient := kubefake.NewSimpleClientset(testKASService(), endpoints)
+	c := &authOperator{
+		services:  kubeClient.CoreV1(),
+		endpoints: kubeClient.CoreV1(),
+	}
+
+	ips, err := c.getAPIServerIPs()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// IPv6 members must be bracketed and the families grouped and sorted so
+	// downstream messages enumerate the members deterministically
+	want := []string{"10.0.0.1:6443", "10.0.0.2:6443", "[fd02::1]:6443", "[fd02::2]:6443"}
+	if !reflect.DeepEqual(ips, want) {
+		t.Errorf("getAPIServerIPs() = %v, want %v", ips, want)
+	}
+}
+
+// TestManualEndpointsNote asserts that endpoint failures call out a
+// selector-less kube api server service - those endpoints are managed outside
+// the endpoints controller, so a stale member list needs manual remediation.
+func TestManualEndpointsNote(t *testing.T) {
+	notReady := testKASEndpoints(6443, "10.0.0.1")
+	notReady.Subsets[0].NotReadyAddresses = notReady.Subsets[0].Addresses
+	notReady.Subsets[0].Addresses = nil
+
+	selectorless := testKASService()
+	selectorless.Spec.Selector = nil
+
+	kubeClient := kubefake.NewSimpleClientset(selectorless, notReady)
+	c := &authOperator{services: kubeClient.CoreV1(), endpoints: kubeClient.CoreV1()}
+	_, err := c.getAPIServerIPs()
+	if err == nil || !strings.Contains(err.Error(), "no selector") {
+		t.Errorf("expected the not-ready error to note the manually managed endpoints, got: %v", err)
+	}
+
+	// the same service with a selector is reconciled normally - no note
+	kubeClient = kubefake.NewSimpleClientset(testKASService(), notReady.DeepCopy())
+	c = &authOperator{services: kubeClient.CoreV1(), endpoints: kubeClient.CoreV1()}
+	_, err = c.getAPIServerIPs()
+	if err == nil || strings.Contains(err.Error(), "no selector") {
+		t.Errorf("expected a plain not-ready error for a selector-backed service, got: %v", err)
+	}
+}
+
+func TestEndpointsBootstrapMarker(t *testing.T) {
+	notReady := testKASEndpoints(6443, "10.0.0.1")
+	notReady.Subsets[0].NotReadyAddresses = notReady.Subsets[0].Addresses
+	notReady.Subsets[0].Addresses = nil
+
+	// not ready endpoints without prior success stay classified as bootstrap
+	c := &authOperator{}
+	kubeClient := kubefake.NewSimpleClientset(testKASService(), notReady)
+	c.services, c.endpoints = kubeClient.CoreV1(), kubeClient.CoreV1()
+	if _, err := c.getAPIServerIPs(); err == nil {
+		t.Fatal("expected an error for not ready endpoints")
+	}
+	if c.endpointsEverReady() {
+		t.Error("not ready endpoints must not set the marker")
+	}
+
+	// a successful listing sets the marker
+	kubeClient = kubefake.NewSimpleClientset(testKASService(), testKASEndpoints(6443, "10.0.0.1"))
+	c.services, c.endpoints = kubeClient.CoreV1(), kubeClient.CoreV1()
+	if _, err := c.getAPIServerIPs(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !c.endpointsEverReady() {
+		t.Error("ready endpoints must set the marker")
+	}
+
+	// across a restart the marker is recovered from a prior Available=true
+	restarted := &authOperator{}
+	operatorConfig := &operatorv1.Authentication{
+		Status: operatorv1.AuthenticationStatus{
+			OperatorStatus: operatorv1.OperatorStatus{
+				Conditions: []operatorv1.OperatorCondition{
+					{Type: operatorv1.OperatorStatusTypeAvailable, Status: operatorv1.ConditionTrue},
+				},
+			},
+		},
+	}
+	restarted.restoreEndpointsBootstrapMarker(operatorConfig)
+	if !restarted.endpointsEverReady() {
+		t.Error("a previously available operator must classify outages as regression after restart")
+	}
+}
+
+// TestConcurrentProbeState runs overlapping probes together with readers and
+// writers of the shared probe state - it only proves its worth under -race.
+func TestConcurrentProbeState(t *testing.T) {
+	route := testRoute("oauth-openshift.apps.example.com")
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(getMetadata(route)))
+	}))
+	defer server.Close()
+
+	c := &authOperator{}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				if _, _, _, err := c.checkWellknownEndpointReady(server.Listener.Addr().String(), server.Client().Transport, route); err != nil {
+					t.Errorf("unexpected error: %v", err)
+					return
+				}
+				c.recordProbeMetadata(server.Listener.Addr().String(), []byte("{}"))
+				c.pruneProbeMetadata([]string{server.Listener.Addr().String()})
+				_ = c.LastProbeMetadata()
+				c.recordProbeResults([]string{server.Listener.Addr().String()}, nil)
+				_, _ = c.LastProbeResults()
+				c.clearProbeMetadata()
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestCheckMembersWellknownReadyRotatedOutMember(t *testing.T) {
+	route := testRoute("oauth-openshift.apps.example.com")
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(getMetadata(route)))
+	}))
+	defer server.Close()
+
+	liveAddr := server.Listener.Addr().String()
+	_, portStr, err := net.SplitHostPort(liveAddr)
+	if err != nil {
+		t.Fatalf("failed to split server address: %v", err)
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		t.Fatalf("failed to parse server port: %v", err)
+	}
+	deadAddr := net.JoinHostPort("127.0.0.2", portStr)
+
+	service := testKASService()
+	service.Spec.Ports[0].TargetPort = intstr.FromInt(port)
+
+	// the current endpoints only know the live member - a refusal from the
+	// stale member must be ignored after the refresh
+	kubeClient := kubefake.NewSimpleClientset(service, testKASEndpoints(int32(port), "127.0.0.1"))
+	c := &authOperator{
+		services:  kubeClient.CoreV1(),
+		endpoints: kubeClient.CoreV1(),
+	}
+	ready, _, msg, err := c.checkMembersWellknownReady([]string{deadAddr, liveAddr}, server.Client().Transport, route)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !ready {
+		t.Errorf("expected ready when the refused member rotated out, got: %q", msg)
+	}
+
+	// a refusal from a member the endpoints still list is a real failure
+	kubeClient = kubefake.NewSimpleClientset(service, testKASEndpoints(int32(port), "127.0.0.1", "127.0.0.2"))
+	c.services, c.endpoints = kubeClient.CoreV1(), kubeClient.CoreV1()
+	if _, _, _, err := c.checkMembersWellknownReady([]string{deadAddr, liveAddr}, server.Client().Transport, route); err == nil || !isConnectionRefused(err) {
+		t.Errorf("expected the refusal from a current member to surface, got: %v", err)
+	}
+}
+
+// TestMemberProbeBackoff exercises the per-member negative result backoff: a
+// failed member is not re-probed until its backoff elapses, the wait grows
+// exponentially up to the cap, and success or leaving the endpoint set resets
+// the state.
+func TestMemberProbeBackoff(t *testing.T) {
+	now := time.Date(2019, time.June, 1, 12, 0, 0, 0, time.UTC)
+	c := &authOperator{clock: func() time.Time { return now }}
+	const ip = "10.0.0.1:6443"
+
+	c.recordMemberFailure(ip, reasonUnexpectedStatus, "boom", nil)
+	backoff, remaining, ok := c.memberInBackoff(ip)
+	if !ok || backoff.failures != 1 || remaining != probeMemberBackoffBase {
+		t.Fatalf("expected a first failure with the base backoff, got %#v remaining=%v ok=%v", backoff, remaining, ok)
+	}
+
+	// the window expires on its own
+	now = now.Add(probeMemberBackoffBase + time.Second)
+	if _, _, ok := c.memberInBackoff(ip); ok {
+		t.Error("expected the backoff to expire after the base interval")
+	}
+
+	// consecutive failures double the wait up to the cap
+	c.recordMemberFailure(ip, reasonUnexpectedStatus, "boom", nil)
+	if _, remaining, _ := c.memberInBackoff(ip); remaining != 2*probeMemberBackoffBase {
+		t.Errorf("expected the second failure to double the backoff, got %v", remaining)
+	}
+	for i := 0; i < 20; i++ {
+		c.recordMemberFailure(ip, reasonUnexpectedStatus, "boom", nil)
+	}
+	if _, remaining, _ := c.memberInBackoff(ip); remaining != probeMemberBackoffMax {
+		t.Errorf("expected the backoff to cap at %v, got %v", probeMemberBackoffMax, remaining)
+	}
+
+	// success resets the member completely
+	c.clearMemberBackoff(ip)
+	if _, _, ok := c.memberInBackoff(ip); ok {
+		t.Error("expected no backoff after a successful probe")
+	}
+
+	// members that left the endpoint set start with a clean slate
+	c.recordMemberFailure(ip, reasonUnexpectedStatus, "boom", nil)
+	c.pruneMemberBackoffs([]string{"10.0.0.2:6443"})
+	if _, _, ok := c.memberInBackoff(ip); ok {
+		t.Error("expected the backoff to be pruned with the member gone")
+	}
+}
+
+// TestMemberBackoffSkipsProbe asserts that a member inside its backoff window
+// is not contacted again and the stored failure is replayed with the
+// remaining backoff in the message.
+func TestMemberBackoffSkipsProbe(t *testing.T) {
+	now := time.Date(2019, time.June, 1, 12, 0, 0, 0, time.UTC)
+	route := testRoute("oauth-openshift.apps.example.com")
+
+	var probes int
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		probes++
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	c := &authOperator{clock: func() time.Time { return now }}
+	addr := server.Listener.Addr().String()
+
+	ready, reason, _, err := c.checkMembersWellknownReady([]string{addr}, server.Client().Transport, route)
+	if err != nil || ready || reason != reasonUnexpectedStatus {
+		t.Fatalf("expected a not-ready status failure, got ready=%v reason=%q err=%v", ready, reason, err)
+	}
+	if probes != 1 {
+		t.Fatalf("expected exactly one probe, got %d", probes)
+	}
+
+	// inside the backoff window the stored result is replayed verbatim
+	ready, reason, msg, err := c.checkMembersWellknownReady([]string{addr}, server.Client().Transport, route)
+	if err != nil || ready || reason != reasonUnexpectedStatus {
+		t.Fatalf("expected the replayed failure, got ready=%v reason=%q err=%v", ready, reason, err)
+	}
+	if !strings.Contains(msg, "probe backoff") || !strings.Contains(msg, "1 consecutive failures") {
+		t.Errorf("expected the message to carry the backoff context, got %q", msg)
+	}
+	if probes != 1 {
+		t.Errorf("expected the member in backoff to not be probed, got %d probes", probes)
+	}
+
+	// past the window the member is probed again
+	now = now.Add(probeMemberBackoffBase + time.Second)
+	if _, _, _, err := c.checkMembersWellknownReady([]string{addr}, server.Client().Transport, route); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if probes != 2 {
+		t.Errorf("expected a fresh probe after the backoff elapsed, got %d probes", probes)
+	}
+}
+
+func TestLastProbeResults(t *testing.T) {
+	c := &authOperator{}
+
+	// nothing recorded yet
+	passed, failed := c.LastProbeResults()
+	if len(passed) != 0 || len(failed) != 0 {
+		t.Errorf("expected empty results initially, got passed=%v failed=%v", passed, failed)
+	}
+
+	// a cycle that stopped at a bad member
+	c.recordProbeResults([]string{"10.0.0.1:6443"}, []string{"10.0.0.2:6443"})
+	passed, failed = c.LastProbeResults()
+	if len(passed) != 1 || passed[0] != "10.0.0.1:6443" || len(failed) != 1 || failed[0] != "10.0.0.2:6443" {
+		t.Errorf("unexpected results: passed=%v failed=%v", passed, failed)
+	}
+
+	// the accessor hands out copies, not the shared state
+	passed[0] = "mutated"
+	if again, _ := c.LastProbeResults(); again[0] != "10.0.0.1:6443" {
+		t.Error("mutating the returned slice must not affect the stored results")
+	}
+
+	// each cycle replaces the previous outcome wholesale
+	c.recordProbeResults([]string{"10.0.0.1:6443", "10.0.0.2:6443"}, nil)
+	passed, failed = c.LastProbeResults()
+	if len(passed) != 2 || len(failed) != 0 {
+		t.Errorf("expected the recovered cycle to replace the failure, got passed=%v failed=%v", passed, failed)
+	}
+
+	// a cycle without a member list clears both
+	c.recordProbeResults(nil, nil)
+	passed, failed = c.LastProbeResults()
+	if len(passed) != 0 || len(failed) != 0 {
+		t.Errorf("expected cleared results, got passed=%v failed=%v", passed, failed)
+	}
+}
+
+// TestProbeIntervalCaching asserts that the probe interval decouples the probe
+// cadence from the reconcile cadence - inside the interval syncs reuse the
+// last outcome, after it expires they probe again, and a zero interval keeps
+// the old probe-every-sync behavior.
+func TestProbeIntervalCaching(t *testing.T) {
+	now := time.Date(2019, time.June, 1, 12, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+
+	route := testRoute("oauth-openshift.apps.example.com")
+
+	// zero interval never caches
+	c := &authOperator{clock: clock}
+	c.storeWellknownResult(route, true, "", "")
+	if _, ok := c.cachedWellknownResult(route); ok {
+		t.Error("a zero probe interval must not cache results")
+	}
+
+	// inside the interval the stored outcome is reused verbatim
+	c = &authOperator{clock: clock, probeInterval: time.Minute}
+	c.storeWellknownResult(route, false, "Cached", "from the cache")
+	result, ok := c.cachedWellknownResult(route)
+	if !ok {
+		t.Fatal("expected a fresh result to be reused")
+	}
+	if result.ready || result.reason != "Cached" || result.message != "from the cache" {
+		t.Errorf("unexpected cached result: %+v", result)
+	}
+
+	// the full check consults the cache before touching the file system or
+	// the network
+	c.rootCAPath = "/nonexistent/ca.crt"
+	authConfig := &configv1.Authentication{
+		Spec: configv1.AuthenticationSpec{Type: configv1.AuthenticationTypeIntegratedOAuth},
+	}
+	ready, reason, message, err := c.checkWellknownEndpointsReady(authConfig, testRoute("oauth-openshift.apps.example.com"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ready || reason != "Cached" || message != "from the cache" {
+		t.Errorf("expected the cached outcome, got ready=%v reason=%q message=%q", ready, reason, message)
+	}
+
+	// once the interval elapses the cache misses and the next sync probes
+	now = now.Add(2 * time.Minute)
+	if _, ok := c.cachedWellknownResult(route); ok {
+		t.Error("expected the cached result to expire with the interval")
+	}
+}
+
+// TestNotInClusterProbeDisabled asserts that without the in-cluster SA
+// environment the readiness check reports ready and says so exactly once
+// instead of erroring on every sync, and that pointing rootCAPath at a bundle
+// re-enables probing.
+func TestNotInClusterProbeDisabled(t *testing.T) {
+	if len(os.Getenv("KUBERNETES_SERVICE_HOST")) != 0 {
+		t.Skip("running with an in-cluster environment present")
+	}
+	logged := captureKlog(t)
+
+	authConfig := &configv1.Authentication{
+		Spec: configv1.AuthenticationSpec{Type: configv1.AuthenticationTypeIntegratedOAuth},
+	}
+	route := testRoute("oauth-openshift.apps.example.com")
+
+	c := &authOperator{}
+	ready, _, _, err := c.checkWellknownEndpointsReady(authConfig, route)
+	if err != nil || !ready {
+		t.Fatalf("expected the probe to be skipped outside a cluster, got ready=%v err=%v", ready, err)
+	}
+	klog.Flush()
+	if !strings.Contains(logged.String(), "well-known probe is disabled") {
+		t.Errorf("expected a one-time warning about the disabled probe, got:\n%s", logged.String())
+	}
+
+	// the warning fires once, not per sync
+	logged.Reset()
+	if _, _, _, err := c.checkWellknownEndpointsReady(authConfig, route); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	klog.Flush()
+	if strings.Contains(logged.String(), "well-known probe is disabled") {
+		t.Error("expected the warning to be logged only once")
+	}
+
+	// an explicit CA path opts back into probing
+	c = &authOperator{rootCAPath: "/nonexistent/ca.crt"}
+	ready, reason, _, err := c.checkWellknownEndpointsReady(authConfig, route)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ready || reason != reasonCABundleError {
+		t.Errorf("expected the probe to run against the configured CA path, got ready=%v reason=%q", ready, reason)
+	}
+}
+
+// TestProbeCacheInvalidatedOnHostChange asserts that a route host change
+// bypasses the probe result cache immediately - the stored outcome was
+// computed against the old issuer and a stale "ready" must not outlive it.
+func TestProbeCacheInvalidatedOnHostChange(t *testing.T) {
+	now := time.Date(2019, time.June, 1, 12, 0, 0, 0, time.UTC)
+	c := &authOperator{clock: func() time.Time { return now }, probeInterval: time.Hour}
+	// point the CA read at a missing file so a fresh probe is observable as
+	// the CA error instead of tripping the not-in-cluster guard
+	c.rootCAPath = "/nonexistent/ca.crt"
+
+	route := testRoute("oauth-openshift.apps.example.com")
+	c.storeWellknownResult(route, true, "", "all good")
+	if _, ok := c.cachedWellknownResult(route); !ok {
+		t.Fatal("expected the fresh result to be reused for the same host")
+	}
+
+	// the same sync against a migrated host misses the cache even though the
+	// interval has not elapsed
+	migrated := testRoute("oauth-openshift.apps.new.example.com")
+	if _, ok := c.cachedWellknownResult(migrated); ok {
+		t.Error("expected a route host change to bypass the cached result")
+	}
+
+	// the full check probes fresh instead of serving the stale "ready" - with
+	// no CA bundle on disk in tests the probe reports the CA error, proving
+	// the cache was not consulted
+	authConfig := &configv1.Authentication{
+		Spec: configv1.AuthenticationSpec{Type: configv1.AuthenticationTypeIntegratedOAuth},
+	}
+	ready, reason, _, err := c.checkWellknownEndpointsReady(authConfig, migrated)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ready || reason != reasonCABundleError {
+		t.Errorf("expected a fresh probe for the new host, got ready=%v reason=%q", ready, reason)
+	}
+
+	// a fresh outcome stored for the new host is reused again
+	c.storeWellknownResult(migrated, true, "", "all good")
+	if _, ok := c.cachedWellknownResult(migrated); !ok {
+		t.Error("expected the result stored for the new host to be reused")
+	}
+}
+
+// TestWellknownProbeTransportCAClassification asserts that an empty CA bundle
+// is treated as a retryable progressing state while a malformed one is a hard
+// failure - an empty bundle is a publication race that resolves itself, a
+// bundle that cannot be parsed does not.
+func TestWellknownProbeTransportCAClassification(t *testing.T) {
+	c := &authOperator{}
+
+	rt, reason, message, err := c.wellknownProbeTransport(nil)
+	if err != nil {
+		t.Errorf("an empty CA bundle must be retryable, got a hard error: %v", err)
+	}
+	if rt != nil {
+		t.Error("an empty CA bundle must not yield a transport")
+	}
+	if reason != reasonCABundleError {
+		t.Errorf("reason = %q, want %q", reason, reasonCABundleError)
+	}
+	if !strings.Contains(message, "waiting") {
+		t.Errorf("expected a waiting message, got %q", message)
+	}
+
+	rt, reason, _, err = c.wellknownProbeTransport([]byte("this is not PEM data"))
+	if err == nil {
+		t.Error("a malformed CA bundle must be a hard failure")
+	}
+	if rt != nil || len(reason) != 0 {
+		t.Errorf("a malformed CA bundle must yield only an error, got rt=%v reason=%q", rt, reason)
+	}
+
+	now := time.Now()
+	ca := newTestCA(t, "test-ca", now.Add(-time.Hour), now.Add(time.Hour))
+	rt, reason, message, err = c.wellknownProbeTransport(ca.certPEM)
+	if err != nil || rt == nil || len(reason) != 0 || len(message) != 0 {
+		t.Errorf("expected a transport for a valid CA bundle, got rt=%v reason=%q message=%q err=%v", rt, reason, message, err)
+	}
+}
+
+func TestCheckWellknownEndpointReadyUserAgent(t *testing.T) {
+	route := testRoute("oauth-openshift.apps.example.com")
+
+	var gotUserAgent string
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotUserAgent = r.UserAgent()
+		w.Write([]byte(getMetadata(route)))
+	}))
+	defer server.Close()
+
+	c := &authOperator{}
+	if _, _, _, err := c.checkWellknownEndpointReady(server.Listener.Addr().String(), server.Client().Transport, route); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if gotUserAgent != defaultProbeUserAgent {
+		t.Errorf("probe User-Agent = %q, want %q", gotUserAgent, defaultProbeUserAgent)
+	}
+
+	c.userAgent = "custom-agent/1.0"
+	if _, _, _, err := c.checkWellknownEndpointReady(server.Listener.Addr().String(), server.Client().Transport, route); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if gotUserAgent != "custom-agent/1.0" {
+		t.Errorf("probe User-Agent = %q, want the customized agent", gotUserAgent)
+	}
+}
+
+func TestCheckWellknownEndpointReadyWeakTLS(t *testing.T) {
+	route := testRoute("oauth-openshift.apps.example.com")
+
+	// a server stuck below the cluster TLS policy
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(getMetadata(route)))
+	}))
+	server.TLS = &tls.Config{MaxVersion: tls.VersionTLS11}
+	server.StartTLS()
+	defer server.Close()
+
+	// a lenient round tripper that tolerates the weak handshake, standing in
+	// for an injected transport that does not enforce the minimum itself
+	rt := server.Client().Transport.(*http.Transport)
+	rt.TLSClientConfig.MinVersion = tls.VersionTLS10
+
+	c := &authOperator{}
+	ready, reason, msg, err := c.checkWellknownEndpointReady(server.Listener.Addr().String(), rt, route)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ready || reason != reasonWeakTLSVersion {
+		t.Errorf("expected a weak TLS version report, got ready=%v reason=%q", ready, reason)
+	}
+	if !strings.Contains(msg, "TLS 1.1") || !strings.Contains(msg, "TLS 1.2") {
+		t.Errorf("expected the message to name the negotiated and required versions, got %q", msg)
+	}
+}
+
+func TestCheckWellknownEndpointReadyStrictSNI(t *testing.T) {
+	route := testRoute("oauth-openshift.apps.example.com")
+
+	// the httptest serving cert has no kubernetes.default.svc SAN
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(getMetadata(route)))
+	}))
+	defer server.Close()
+
+	// lenient by default - verification off the loaded CA is enough
+	c := &authOperator{}
+	ready, _, msg, err := c.checkWellknownEndpointReady(server.Listener.Addr().String(), server.Client().Transport, route)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !ready {
+		t.Errorf("expected ready without the strict check, got: %q", msg)
+	}
+
+	// strict mode insists the SNI name is really in the SANs
+	c.strictSNICheck = true
+	ready, reason, msg, err := c.checkWellknownEndpointReady(server.Listener.Addr().String(), server.Client().Transport, route)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ready || reason != reasonServingCertMissingName {
+		t.Errorf("expected a missing-name report in strict mode, got ready=%v reason=%q", ready, reason)
+	}
+	if !strings.Contains(msg, kasServiceFullName) {
+		t.Errorf("expected the message to name the expected SAN, got %q", msg)
+	}
+
+	// a serving cert that carries the name passes the strict check
+	now := time.Now()
+	ca := newTestCA(t, "kas-ca", now.Add(-time.Hour), now.Add(time.Hour))
+	servingCert, servingKey := ca.issue(t, "kas", []string{"127.0.0.1", kasServiceFullName}, false, now.Add(-time.Hour), now.Add(time.Hour))
+	servingPair, err := tls.X509KeyPair(servingCert, servingKey)
+	if err != nil {
+		t.Fatalf("failed to load serving pair: %v", err)
+	}
+	strictServer := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(getMetadata(route)))
+	}))
+	strictServer.TLS = &tls.Config{Certificates: []tls.Certificate{servingPair}}
+	strictServer.StartTLS()
+	defer strictServer.Close()
+
+	rt, err := transportFor("127.0.0.1", ca.certPEM, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to build transport: %v", err)
+	}
+	ready, _, msg, err = c.checkWellknownEndpointReady(strictServer.Listener.Addr().String(), rt, route)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !ready {
+		t.Errorf("expected ready for a cert carrying the SNI name, got: %q", msg)
+	}
+}
+
+func TestCheckWellknownEndpointReadyGoldenFile(t *testing.T) {
+	route := testRoute("oauth-openshift.apps.example.com")
+
+	golden := filepath.Join(t.TempDir(), "expected.json")
+	if err := ioutil.WriteFile(golden, []byte(getMetadata(testRoute("oauth-openshift.apps.other.com"))), 0644); err != nil {
+		t.Fatalf("failed to write golden file: %v", err)
+	}
+	t.Setenv(wellKnownExpectedMetadataEnv, golden)
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(getMetadata(route)))
+	}))
+	defer server.Close()
+
+	c := &authOperator{}
+
+	// the served document matches the dynamic expectation but not the golden
+	// file - in golden mode the file wins and the diff names the broken keys
+	ready, reason, msg, err := c.checkWellknownEndpointReady(server.Listener.Addr().String(), server.Client().Transport, route)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ready || reason != reasonMetadataMismatch {
+		t.Errorf("expected a metadata mismatch against the golden file, got ready=%v reason=%q", ready, reason)
+	}
+	if !strings.Contains(msg, `key "issuer"`) {
+		t.Errorf("expected the diff to name the differing key, got: %q", msg)
+	}
+
+	// a golden file matching the served document passes
+	if err := ioutil.WriteFile(golden, []byte(getMetadata(route)), 0644); err != nil {
+		t.Fatalf("failed to rewrite golden file: %v", err)
+	}
+	ready, _, msg, err = c.checkWellknownEndpointReady(server.Listener.Addr().String(), server.Client().Transport, route)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !ready {
+		t.Errorf("expected ready with a matching golden file, got: %q", msg)
+	}
+}
+
+func TestCheckWellknownEndpointReadyAcceptableIssuers(t *testing.T) {
+	route := testRoute("oauth-openshift.apps.new.example.com")
+	oldIssuer := routeToIssuerURL(testRoute("oauth-openshift.apps.old.example.com"))
+
+	// a member still serving the old issuer's metadata mid-migration
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(getMetadata(testRoute("oauth-openshift.apps.old.example.com"))))
+	}))
+	defer server.Close()
+
+	// without the transitional set this is a plain mismatch
+	c := &authOperator{}
+	ready, reason, _, err := c.checkWellknownEndpointReady(server.Listener.Addr().String(), server.Client().Transport, route)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ready || reason != reasonMetadataMismatch {
+		t.Errorf("expected a metadata mismatch without acceptable issuers, got ready=%v reason=%q", ready, reason)
+	}
+
+	// with the old issuer configured as acceptable the probe passes
+	c.acceptableIssuers = []string{oldIssuer}
+	ready, _, msg, err := c.checkWellknownEndpointReady(server.Listener.Addr().String(), server.Client().Transport, route)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !ready {
+		t.Errorf("expected ready with the old issuer in the acceptable set, got: %q", msg)
+	}
+
+	// an unrelated acceptable issuer does not mask real mismatches
+	c.acceptableIssuers = []string{"https://oauth-openshift.apps.unrelated.example.com"}
+	ready, reason, _, err = c.checkWellknownEndpointReady(server.Listener.Addr().String(), server.Client().Transport, route)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ready || reason != reasonMetadataMismatch {
+		t.Errorf("expected a mismatch with an unrelated acceptable issuer, got ready=%v reason=%q", ready, reason)
+	}
+}
+
+// TestCheckDeclaredMetadataServed exercises the external-metadata comparison:
+// the document declared via the OAuthMetadata ConfigMap reference must match
+// what the well-known endpoint serves, and broken declarations are reported
+// with the declared-metadata reason instead of silently passing.
+func TestCheckDeclaredMetadataServed(t *testing.T) {
+	route := testRoute("oauth-openshift.apps.example.com")
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(getMetadata(route)))
+	}))
+	defer server.Close()
+
+	c := &authOperator{}
+
+	// the served document matching the declaration is ready
+	ready, _, _, err := c.checkDeclaredMetadataAgainst(getMetadataStruct(route), server.Listener.Addr().String(), server.Client().Transport)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !ready {
+		t.Error("expected ready for a declaration matching the served document")
+	}
+
+	// a diverging declaration names the broken key
+	declared := getMetadataStruct(route)
+	declared["issuer"] = "https://oauth-openshift.apps.declared.example.com"
+	ready, reason, msg, err := c.checkDeclaredMetadataAgainst(declared, server.Listener.Addr().String(), server.Client().Transport)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ready || reason != reasonDeclaredMetadataMismatch || !strings.Contains(msg, `"issuer"`) {
+		t.Errorf("expected a mismatch naming the issuer key, got ready=%v reason=%q msg=%q", ready, reason, msg)
+	}
+
+	// a missing or unparseable config map is reported, not skipped
+	c.configMaps = kubefake.NewSimpleClientset().CoreV1()
+	ready, reason, _, err = c.checkDeclaredMetadataServed("declared-metadata")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ready || reason != reasonDeclaredMetadataMismatch {
+		t.Errorf("expected a mismatch for a missing config map, got ready=%v reason=%q", ready, reason)
+	}
+
+	c.configMaps = kubefake.NewSimpleClientset(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "declared-metadata", Namespace: userConfigNamespace},
+		Data:       map[string]string{configv1.OAuthMetadataKey: "this is not JSON"},
+	}).CoreV1()
+	ready, reason, _, err = c.checkDeclaredMetadataServed("declared-metadata")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ready || reason != reasonDeclaredMetadataMismatch {
+		t.Errorf("expected a mismatch for an unparseable declaration, got ready=%v reason=%q", ready, reason)
+	}
+}
+
+func TestProbeCircuitBreaker(t *testing.T) {
+	route := testRoute("oauth-openshift.apps.example.com")
+	members := []string{"10.0.0.1:6443", "10.0.0.2:6443"}
+	kubeClient := kubefake.NewSimpleClientset(testKASService(), testKASEndpoints(6443, "10.0.0.1", "10.0.0.2"))
+
+	now := time.Date(2019, time.April, 1, 12, 0, 0, 0, time.UTC)
+	c := &authOperator{
+		services:              kubeClient.CoreV1(),
+		endpoints:             kubeClient.CoreV1(),
+		probeBreakerThreshold: 2,
+		clock:                 func() time.Time { return now },
+	}
+	operatorConfig := &operatorv1.Authentication{}
+	condition := func() *operatorv1.OperatorCondition {
+		c.handleProbeBreaker(operatorConfig)
+		return v1helpers.FindOperatorCondition(operatorConfig.Status.Conditions, c.wellKnownPrefix()+"ProbeBreakerOpen")
+	}
+
+	rt := newFakeTransport()
+	for _, member := range members {
+		rt.respond(member, 503, "")
+	}
+
+	// first fully failed cycle - breaker still closed
+	if ready, _, _, err := c.checkMembersWellknownReady(members, rt, route); ready || err != nil {
+		t.Fatalf("expected a clean failure, got ready=%v err=%v", ready, err)
+	}
+	if got := condition(); got == nil || got.Status != operatorv1.ConditionFalse {
+		t.Errorf("expected a closed breaker after one failed cycle, got %#v", got)
+	}
+
+	// the second cycle replays the first member's backoff - still a fully
+	// failed cycle, and it reaches the threshold
+	if ready, _, _, _ := c.checkMembersWellknownReady(members, rt, route); ready {
+		t.Fatal("expected the backoff replay to fail the cycle")
+	}
+	if got := condition(); got == nil || got.Status != operatorv1.ConditionTrue || got.Reason != "BreakerOpen" {
+		t.Errorf("expected an open breaker at the threshold, got %#v", got)
+	}
+
+	// open: exactly one member is probed per cycle and the message says why
+	before := len(rt.requestedHosts())
+	ready, reason, msg, err := c.checkMembersWellknownReady(members, rt, route)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ready || reason != reasonUnexpectedStatus || !strings.Contains(msg, "circuit breaker is open") {
+		t.Errorf("expected the open breaker to annotate the failure, got ready=%v reason=%q msg=%q", ready, reason, msg)
+	}
+	if got := len(rt.requestedHosts()); got != before+1 {
+		t.Errorf("expected a single probe while the breaker is open, got %d", got-before)
+	}
+
+	// half-open: the single probe succeeding closes the breaker and the full
+	// fan out resumes within the same cycle
+	for _, member := range members {
+		rt.respond(member, 200, getMetadata(route))
+	}
+	before = len(rt.requestedHosts())
+	if ready, _, msg, err := c.checkMembersWellknownReady(members, rt, route); !ready || err != nil {
+		t.Fatalf("expected recovery, got ready=%v msg=%q err=%v", ready, msg, err)
+	}
+	if got := len(rt.requestedHosts()); got != before+3 {
+		t.Errorf("expected the half-open probe plus the full fan out, got %d requests", got-before)
+	}
+	if got := condition(); got == nil || got.Status != operatorv1.ConditionFalse {
+		t.Errorf("expected a closed breaker after recovery, got %#v", got)
+	}
+}
+
+func TestHandleIssuerConsistency(t *testing.T) {
+	route := testRoute("oauth-openshift.apps.example.com")
+	operatorConfig := &operatorv1.Authentication{}
+	authConfig := &configv1.Authentication{}
+
+	condition := func() *operatorv1.OperatorCondition {
+		return v1helpers.FindOperatorCondition(operatorConfig.Status.Conditions, "OAuthIssuerDrift")
+	}
+	declaredConfigMap := func(issuer string) *corev1.ConfigMap {
+		return &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "declared-metadata", Namespace: userConfigNamespace},
+			Data:       map[string]string{configv1.OAuthMetadataKey: fmt.Sprintf(`{"issuer": %q}`, issuer)},
+		}
+	}
+
+	// before the first probe only the route leg exists - nothing to disagree
+	c := &authOperator{}
+	c.handleIssuerConsistency(operatorConfig, authConfig, route)
+	if got := condition(); got == nil || got.Status != operatorv1.ConditionFalse {
+		t.Errorf("expected a false condition with only the route leg, got %#v", got)
+	}
+
+	// a served document with the route's issuer keeps the condition clear
+	c.recordServedIssuer(getMetadataStruct(route))
+	c.handleIssuerConsistency(operatorConfig, authConfig, route)
+	if got := condition(); got == nil || got.Status != operatorv1.ConditionFalse {
+		t.Errorf("expected a false condition for a matching served issuer, got %#v", got)
+	}
+
+	// a stale served document names the disagreeing source
+	c.recordServedIssuer(metadataStructForIssuer("https://oauth-openshift.apps.stale.example.com"))
+	c.handleIssuerConsistency(operatorConfig, authConfig, route)
+	if got := condition(); got == nil || got.Status != operatorv1.ConditionTrue || got.Reason != "IssuerMismatch" {
+		t.Errorf("expected a true IssuerMismatch condition, got %#v", got)
+	}
+	if got := condition(); !strings.Contains(got.Message, "served well-known document") || !strings.Contains(got.Message, "apps.stale.example.com") {
+		t.Errorf("expected the message to name the served document and its issuer, got %q", got.Message)
+	}
+
+	// the ConfigMap leg only participates while the external-metadata mode is
+	// active - an unreferenced config map with a bogus issuer changes nothing
+	c.recordServedIssuer(getMetadataStruct(route))
+	c.configMaps = kubefake.NewSimpleClientset(declaredConfigMap("https://oauth-openshift.apps.declared.example.com")).CoreV1()
+	c.handleIssuerConsistency(operatorConfig, authConfig, route)
+	if got := condition(); got == nil || got.Status != operatorv1.ConditionFalse {
+		t.Errorf("expected a false condition while the config map is not referenced, got %#v", got)
+	}
+
+	// once referenced, a declared issuer diverging from the route is drift
+	authConfig.Spec.OAuthMetadata.Name = "declared-metadata"
+	c.handleIssuerConsistency(operatorConfig, authConfig, route)
+	if got := condition(); got == nil || got.Status != operatorv1.ConditionTrue || got.Reason != "IssuerMismatch" {
+		t.Errorf("expected a true IssuerMismatch condition for a diverging declaration, got %#v", got)
+	}
+	if got := condition(); !strings.Contains(got.Message, "declared metadata config map") || !strings.Contains(got.Message, "apps.declared.example.com") {
+		t.Errorf("expected the message to name the config map and its issuer, got %q", got.Message)
+	}
+
+	// an agreeing declaration clears it again
+	c.configMaps = kubefake.NewSimpleClientset(declaredConfigMap(routeToIssuerURL(route))).CoreV1()
+	c.handleIssuerConsistency(operatorConfig, authConfig, route)
+	if got := condition(); got == nil || got.Status != operatorv1.ConditionFalse {
+		t.Errorf("expected a false condition when all three sources agree, got %#v", got)
+	}
+
+	// a declaration without a readable issuer is its own reason
+	c.configMaps = kubefake.NewSimpleClientset().CoreV1()
+	c.handleIssuerConsistency(operatorConfig, authConfig, route)
+	if got := condition(); got == nil || got.Status != operatorv1.ConditionTrue || got.Reason != "DeclaredIssuerUnreadable" {
+		t.Errorf("expected a true DeclaredIssuerUnreadable condition, got %#v", got)
+	}
+}
+
+func TestCheckWellknownEndpointReadyReasons(t *testing.T) {
+	route := testRoute("oauth-openshift.apps.example.com")
+
+	tests := []struct {
+		name       string
+		handler    http.HandlerFunc
+		wantReady  bool
+		wantReason string
+	}{
+		{
+			name: "non-200 status",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			},
+			wantReady:  false,
+			wantReason: reasonUnexpectedStatus,
+		},
+		{
+			name: "401 means discovery requires authentication",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusUnauthorized)
+			},
+			wantReady:  false,
+			wantReason: reasonDiscoveryAuthRequired,
+		},
+		{
+			name: "403 means discovery requires authentication",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusForbidden)
+			},
+			wantReady:  false,
+			wantReason: reasonDiscoveryAuthRequired,
+		},
+		{
+			name: "empty 200 body",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			},
+			wantReady:  false,
+			wantReason: reasonEmptyMetadataBody,
+		},
+		{
+			name: "whitespace-only 200 body",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte("  \n\t"))
+			},
+			wantReady:  false,
+			wantReason: reasonEmptyMetadataBody,
+		},
+		{
+			name: "malformed JSON body",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte("<html>this is not JSON</html>"))
+			},
+			wantReady:  false,
+			wantReason: reasonMalformedMetadata,
+		},
+		{
+			name: "trailing garbage after valid JSON",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(getMetadata(route) + "\n<html>proxy error page</html>"))
+			},
+			wantReady:  false,
+			wantReason: reasonMalformedMetadata,
+		},
+		{
+			name: "missing token_endpoint field",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(strings.Replace(getMetadata(route), `"token_endpoint"`, `"renamed_endpoint"`, 1)))
+			},
+			wantReady:  false,
+			wantReason: reasonMissingMetadataField,
+		},
+		{
+			name: "empty authorization_endpoint field",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(strings.Replace(getMetadata(route), "https://oauth-openshift.apps.example.com/oauth/authorize", "", 1)))
+			},
+			wantReady:  false,
+			wantReason: reasonMissingMetadataField,
+		},
+		{
+			name: "metadata mismatch",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(getMetadata(testRoute("oauth-openshift.apps.other.com"))))
+			},
+			wantReady:  false,
+			wantReason: reasonMetadataMismatch,
+		},
+		{
+			name: "IP literal issuer",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(getMetadata(testRoute("10.0.0.1"))))
+			},
+			wantReady:  false,
+			wantReason: reasonIssuerIsIPAddress,
+		},
+		{
+			name: "connection closed mid-body",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				hijacker, ok := w.(http.Hijacker)
+				if !ok {
+					panic("test server does not support hijacking")
+				}
+				conn, _, err := hijacker.Hijack()
+				if err != nil {
+					panic(err)
+				}
+				// promise a longer body than we deliver and drop the connection
+				conn.Write([]byte("HTTP/1.1 200 OK\r\nContent-Length: 1000\r\n\r\n{"))
+				conn.Close()
+			},
+			wantReady:  false,
+			wantReason: reasonBodyReadFailed,
+		},
+		{
+			name: "extra scopes are tolerated",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(strings.Replace(getMetadata(route), `"user:full",`, `"user:full", "custom:scope",`, 1)))
+			},
+			wantReady:  true,
+			wantReason: "",
+		},
+		{
+			name: "missing required scope",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(strings.Replace(getMetadata(route), `"user:full",`, "", 1)))
+			},
+			wantReady:  false,
+			wantReason: reasonMetadataMismatch,
+		},
+		{
+			name: "happy path",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(getMetadata(route)))
+			},
+			wantReady:  true,
+			wantReason: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewTLSServer(tt.handler)
+			defer server.Close()
+
+			c := &authOperator{}
+			ready, reason, msg, err := c.checkWellknownEndpointReady(server.Listener.Addr().String(), server.Client().Transport, route)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if ready != tt.wantReady {
+				t.Errorf("ready = %v, want %v (message=%q)", ready, tt.wantReady, msg)
+			}
+			if reason != tt.wantReason {
+				t.Errorf("reason = %q, want %q", reason, tt.wantReason)
+			}
+			if !ready && len(msg) == 0 {
+				t.Error("expected a message for the not ready case")
+			}
+		})
+	}
+}
+
+func TestCheckTokenEndpointCORSAgainst(t *testing.T) {
+	const origin = "https://console-openshift-console.apps.example.com"
+
+	tests := []struct {
+		name        string
+		handler     http.HandlerFunc
+		wantOK      bool
+		wantReason  string
+		wantMessage string
+	}{
+		{
+			name: "wildcard origin",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != http.MethodOptions {
+					t.Errorf("expected an OPTIONS preflight, got %s", r.Method)
+				}
+				if got := r.Header.Get("Origin"); got != origin {
+					t.Errorf("Origin header = %q, want %q", got, origin)
+				}
+				if got := r.Header.Get("Access-Control-Request-Method"); got != http.MethodPost {
+					t.Errorf("Access-Control-Request-Method header = %q, want %q", got, http.MethodPost)
+				}
+				w.Header().Set("Access-Control-Allow-Origin", "*")
+			},
+			wantOK: true,
+		},
+		{
+			name: "exact origin with methods",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Access-Control-Allow-Origin", origin)
+				w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
+			},
+			wantOK: true,
+		},
+		{
+			name:        "no CORS headers at all",
+			handler:     func(w http.ResponseWriter, r *http.Request) {},
+			wantOK:      false,
+			wantReason:  "PreflightFailed",
+			wantMessage: "does not allow origin",
+		},
+		{
+			name: "foreign origin allowed instead",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Access-Control-Allow-Origin", "https://other.example.com")
+			},
+			wantOK:      false,
+			wantReason:  "PreflightFailed",
+			wantMessage: "does not allow origin",
+		},
+		{
+			name: "POST not among the allowed methods",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Access-Control-Allow-Origin", "*")
+				w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
+			},
+			wantOK:      false,
+			wantReason:  "PreflightFailed",
+			wantMessage: "does not allow POST",
+		},
+		{
+			name: "preflight rejected outright",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+			},
+			wantOK:      false,
+			wantReason:  "PreflightRejected",
+			wantMessage: "rejected the CORS preflight",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewTLSServer(tt.handler)
+			defer server.Close()
+
+			c := &authOperator{}
+			ok, reason, message := c.checkTokenEndpointCORSAgainst(server.URL+"/oauth/token", origin, server.Client().Transport)
+			if ok != tt.wantOK {
+				t.Errorf("ok = %v, want %v (message=%q)", ok, tt.wantOK, message)
+			}
+			if reason != tt.wantReason {
+				t.Errorf("reason = %q, want %q", reason, tt.wantReason)
+			}
+			if !strings.Contains(message, tt.wantMessage) {
+				t.Errorf("message = %q, want it to contain %q", message, tt.wantMessage)
+			}
+		})
+	}
+}
+
+func TestHandleTokenEndpointCORSOptOut(t *testing.T) {
+	operatorConfig := &operatorv1.Authentication{}
+
+	// the check never runs - and never sets its condition - unless opted in
+	c := &authOperator{}
+	c.handleTokenEndpointCORS(operatorConfig, testRoute("oauth-openshift.apps.example.com"), testRouterSecret("apps.example.com"), testIngressConfig("apps.example.com"))
+	if got := v1helpers.FindOperatorCondition(operatorConfig.Status.Conditions, "TokenEndpointCORS"); got != nil {
+		t.Errorf("expected no condition while the check is off, got %#v", got)
+	}
+}
+
+func TestProbeCycleBudget(t *testing.T) {
+	route := testRoute("oauth-openshift.apps.example.com")
+
+	// the server never answers within the budget but honors the request
+	// context, so the cut-short probe releases the handler promptly
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(10 * time.Second):
+		}
+	}))
+	defer server.Close()
+
+	c := &authOperator{probeCycleBudget: 100 * time.Millisecond}
+	ips := []string{server.Listener.Addr().String(), "10.255.0.1:6443", "10.255.0.2:6443"}
+
+	start := time.Now()
+	ready, reason, msg, err := c.checkMembersWellknownReady(ips, server.Client().Transport, route)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("the probe cycle took %s, expected the budget to cut it short", elapsed)
+	}
+	if ready || reason != reasonProbeDeadlineExceeded {
+		t.Errorf("ready = %v, reason = %q, want not ready with %q (message=%q)", ready, reason, reasonProbeDeadlineExceeded, msg)
+	}
+	if !strings.Contains(msg, "not probed within") {
+		t.Errorf("expected a not-probed-within-deadline message, got %q", msg)
+	}
+	for _, ip := range ips {
+		if !strings.Contains(msg, ip) {
+			t.Errorf("expected the message to name unprobed member %s, got %q", ip, msg)
+		}
+	}
+}
+
+func TestProbeCABundleFreshness(t *testing.T) {
+	now := time.Date(2019, time.April, 1, 12, 0, 0, 0, time.UTC)
+	expired := newTestCA(t, "expired-ca", now.Add(-48*time.Hour), now.Add(-24*time.Hour))
+	valid := newTestCA(t, "valid-ca", now.Add(-time.Hour), now.Add(365*24*time.Hour))
+	expiring := newTestCA(t, "expiring-ca", now.Add(-time.Hour), now.Add(7*24*time.Hour))
+
+	writeBundle := func(t *testing.T, pemData []byte) string {
+		dir, err := ioutil.TempDir("", "probe-ca")
+		if err != nil {
+			t.Fatalf("failed to create temp dir: %v", err)
+		}
+		t.Cleanup(func() { os.RemoveAll(dir) })
+		path := filepath.Join(dir, "ca.crt")
+		if err := ioutil.WriteFile(path, pemData, 0600); err != nil {
+			t.Fatalf("failed to write bundle: %v", err)
+		}
+		return path
+	}
+
+	// endpoints with no ready addresses keep the fresh-bundle leg below from
+	// probing anything over the network
+	kubeClient := kubefake.NewSimpleClientset(testKASService(), testKASEndpoints(6443))
+
+	// a bundle of nothing but expired CAs short-circuits the probe with the
+	// specific reason instead of whatever TLS error it would produce
+	c := &authOperator{
+		clock:      func() time.Time { return now },
+		services:   kubeClient.CoreV1(),
+		endpoints:  kubeClient.CoreV1(),
+		rootCAPath: writeBundle(t, expired.certPEM),
+	}
+	ready, reason, msg, err := c.probeWellknownEndpointsReady(testRoute("oauth-openshift.apps.example.com"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ready || reason != reasonCABundleExpired {
+		t.Errorf("ready = %v, reason = %q, want not ready with %q", ready, reason, reasonCABundleExpired)
+	}
+	if !strings.Contains(msg, "rotate the service account CA") {
+		t.Errorf("expected the message to name the remediation, got %q", msg)
+	}
+
+	// one still-valid CA in the bundle is enough to keep probing
+	mixed := append(append([]byte{}, expired.certPEM...), valid.certPEM...)
+	c.rootCAPath = writeBundle(t, mixed)
+	if _, reason, _, _ := c.probeWellknownEndpointsReady(testRoute("oauth-openshift.apps.example.com")); reason == reasonCABundleExpired {
+		t.Errorf("a bundle with a valid CA must not report %s", reasonCABundleExpired)
+	}
+
+	operatorConfig := &operatorv1.Authentication{}
+	condition := func() *operatorv1.OperatorCondition {
+		return v1helpers.FindOperatorCondition(operatorConfig.Status.Conditions, "ProbeCABundleExpiring")
+	}
+
+	// a far-future bundle keeps the expiry condition clear
+	c.rootCAPath = writeBundle(t, valid.certPEM)
+	c.handleProbeCAExpiry(operatorConfig)
+	if got := condition(); got == nil || got.Status != operatorv1.ConditionFalse {
+		t.Errorf("expected a false condition for a fresh bundle, got %#v", got)
+	}
+
+	// a bundle inside the warning window warns ahead of the failure
+	c.rootCAPath = writeBundle(t, expiring.certPEM)
+	c.handleProbeCAExpiry(operatorConfig)
+	if got := condition(); got == nil || got.Status != operatorv1.ConditionTrue || got.Reason != "CertificateExpiring" || !strings.Contains(got.Message, "expires in") {
+		t.Errorf("expected a true CertificateExpiring condition, got %#v", got)
+	}
+
+	// a fully expired bundle states how long ago it ran out
+	c.rootCAPath = writeBundle(t, expired.certPEM)
+	c.handleProbeCAExpiry(operatorConfig)
+	if got := condition(); got == nil || got.Status != operatorv1.ConditionTrue || !strings.Contains(got.Message, "expired") {
+		t.Errorf("expected a true condition naming the expiry, got %#v", got)
+	}
+}
+
+// TestWellknownRecoveryEvent asserts that readiness coming back after an
+// outage announces itself with a single event per transition - not per sync,
+// and not at all for an operator that was never not ready.
+func TestWellknownRecoveryEvent(t *testing.T) {
+	recorder := events.NewInMemoryRecorder("test")
+	c := &authOperator{recorder: recorder}
+	c.recordProbeResults([]string{"10.0.0.1:6443", "10.0.0.2:6443"}, nil)
+
+	recoveryEvents := func() []string {
+		var messages []string
+		for _, event := range recorder.Events() {
+			if event.Reason == "OAuthWellknownReady" {
+				messages = append(messages, event.Message)
+			}
+		}
+		return messages
+	}
+
+	// starting out ready is not a recovery
+	c.noteWellknownRecovery(true)
+	if got := recoveryEvents(); len(got) != 0 {
+		t.Fatalf("expected no event without a preceding outage, got %v", got)
+	}
+
+	// repeated not-ready outcomes arm the announcement once
+	c.noteWellknownRecovery(false)
+	c.noteWellknownRecovery(false)
+	c.noteWellknownRecovery(true)
+	messages := recoveryEvents()
+	if len(messages) != 1 {
+		t.Fatalf("expected exactly one recovery event, got %d: %v", len(messages), messages)
+	}
+	if !strings.Contains(messages[0], "2 kube api server members") {
+		t.Errorf("expected the event to state the verified member count, got %q", messages[0])
+	}
+
+	// staying ready stays silent
+	c.noteWellknownRecovery(true)
+	if got := recoveryEvents(); len(got) != 1 {
+		t.Fatalf("expected no new event while staying ready, got %v", got)
+	}
+
+	// a second outage rearms the announcement
+	c.noteWellknownRecovery(false)
+	c.noteWellknownRecovery(true)
+	if got := recoveryEvents(); len(got) != 2 {
+		t.Fatalf("expected a second recovery event, got %v", got)
+	}
+}
+
+// TestGetAPIServerIPsHeterogeneousPorts asserts that subsets resolving a named
+// target port to different numbers each contribute their addresses under their
+// own concrete port instead of one global number.
+func TestGetAPIServerIPsHeterogeneousPorts(t *testing.T) {
+	service := testKASService()
+	service.Spec.Ports[0].Name = "https"
+
+	endpoints := &corev1.Endpoints{
+		ObjectMeta: metav1.ObjectMeta{Name: kasServiceAndEndpointName, Namespace: corev1.NamespaceDefault},
+		Subsets: []corev1.EndpointSubset{
+			{
+				Addresses: []corev1.EndpointAddress{{IP: "10.0.0.1"}},
+				Ports:     []corev1.EndpointPort{{Name: "https", Port: 6443, Protocol: corev1.ProtocolTCP}},
+			},
+			{
+				Addresses: []corev1.EndpointAddress{{IP: "10.0.0.2"}},
+				Ports:     []corev1.EndpointPort{{Name: "https", Port: 6444, Protocol: corev1.ProtocolTCP}},
+			},
+		},
+	}
+
+	kubeClient := kubefake.NewSimpleClientset(service, endpoints)
+	c := &authOperator{services: kubeClient.CoreV1(), endpoints: kubeClient.CoreV1()}
+
+	ips, err := c.getAPIServerIPs()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := []string{"10.0.0.1:6443", "10.0.0.2:6444"}; !reflect.DeepEqual(ips, want) {
+		t.Errorf("getAPIServerIPs() = %v, want %v", ips, want)
+	}
+}
+
+func TestHandleOAuthServiceMetadata(t *testing.T) {
+	route := testRoute("oauth-openshift.apps.example.com")
+
+	condition := func(operatorConfig *operatorv1.Authentication) *operatorv1.OperatorCondition {
+		return v1helpers.FindOperatorCondition(operatorConfig.Status.Conditions, "OAuthServiceMetadata")
+	}
+
+	t.Run("disabled by default", func(t *testing.T) {
+		operatorConfig := &operatorv1.Authentication{}
+		c := &authOperator{}
+		c.handleOAuthServiceMetadata(operatorConfig, route)
+		if got := condition(operatorConfig); got != nil {
+			t.Errorf("expected no condition while the check is off, got %#v", got)
+		}
+	})
+
+	t.Run("missing service CA config map", func(t *testing.T) {
+		operatorConfig := &operatorv1.Authentication{}
+		kubeClient := kubefake.NewSimpleClientset()
+		c := &authOperator{checkOAuthServiceMetadata: true, configMaps: kubeClient.CoreV1()}
+		c.handleOAuthServiceMetadata(operatorConfig, route)
+		got := condition(operatorConfig)
+		if got == nil || got.Status != operatorv1.ConditionTrue || got.Reason != "TransportError" {
+			t.Fatalf("expected a TransportError condition, got %#v", got)
+		}
+	})
+
+	t.Run("service serves the expected metadata", func(t *testing.T) {
+		operatorConfig := &operatorv1.Authentication{}
+		rt := newFakeTransport()
+		rt.respond(oauthServiceFullName, 200, getMetadata(route))
+		c := &authOperator{checkOAuthServiceMetadata: true, oauthServiceRT: rt}
+		c.handleOAuthServiceMetadata(operatorConfig, route)
+		got := condition(operatorConfig)
+		if got == nil || got.Status != operatorv1.ConditionFalse || got.Reason != "AsExpected" {
+			t.Fatalf("expected the default healthy condition, got %#v", got)
+		}
+	})
+
+	t.Run("service serves stale metadata", func(t *testing.T) {
+		operatorConfig := &operatorv1.Authentication{}
+		rt := newFakeTransport()
+		rt.respond(oauthServiceFullName, 200, getMetadata(testRoute("oauth-openshift.apps.stale.example.com")))
+		c := &authOperator{checkOAuthServiceMetadata: true, oauthServiceRT: rt}
+		c.handleOAuthServiceMetadata(operatorConfig, route)
+		got := condition(operatorConfig)
+		if got == nil || got.Status != operatorv1.ConditionTrue || got.Reason != reasonMetadataMismatch {
+			t.Fatalf("expected a metadata mismatch condition, got %#v", got)
+		}
+		if !strings.Contains(got.Message, oauthServiceFullName) {
+			t.Errorf("expected the message to name the service, got %q", got.Message)
+		}
+		// the oauth service document must not masquerade as the KAS-served
+		// state the consistency handlers compare against
+		if issuer := c.servedIssuer(); len(issuer) != 0 {
+			t.Errorf("the oauth service probe polluted the served issuer cache with %q", issuer)
+		}
+		if doc := c.servedMetadataDoc(); doc != nil {
+			t.Errorf("the oauth service probe polluted the served metadata cache with %v", doc)
+		}
+	})
+
+	t.Run("probe fails at the transport level", func(t *testing.T) {
+		operatorConfig := &operatorv1.Authentication{}
+		rt := newFakeTransport()
+		rt.fail(oauthServiceFullName, fmt.Errorf("dial tcp: lookup %s: no such host", oauthServiceFullName))
+		c := &authOperator{checkOAuthServiceMetadata: true, oauthServiceRT: rt}
+		c.handleOAuthServiceMetadata(operatorConfig, route)
+		got := condition(operatorConfig)
+		if got == nil || got.Status != operatorv1.ConditionTrue || got.Reason != "ProbeFailed" {
+			t.Fatalf("expected a ProbeFailed condition, got %#v", got)
+		}
+	})
+}
+
+func TestNoteNegotiatedCipherSuite(t *testing.T) {
+	suiteName := func(suite uint16) string { return tls.CipherSuiteName(suite) }
+
+	t.Run("info metric tracks the latest connection", func(t *testing.T) {
+		c := &authOperator{}
+		c.noteNegotiatedCipherSuite(tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256)
+		if got := testutil.ToFloat64(probeCipherSuiteInfo.WithLabelValues(suiteName(tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256))); got != 1 {
+			t.Errorf("expected the negotiated suite series to be 1, got %v", got)
+		}
+
+		// a new negotiation replaces the previous series instead of piling up
+		c.noteNegotiatedCipherSuite(tls.TLS_AES_128_GCM_SHA256)
+		if got := testutil.ToFloat64(probeCipherSuiteInfo.WithLabelValues(suiteName(tls.TLS_AES_128_GCM_SHA256))); got != 1 {
+			t.Errorf("expected the new suite series to be 1, got %v", got)
+		}
+		if got := testutil.ToFloat64(probeCipherSuiteInfo.WithLabelValues(suiteName(tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256))); got != 0 {
+			t.Errorf("expected the old suite series to be dropped, got %v", got)
+		}
+	})
+
+	t.Run("negotiating outside the approved set warns once per change", func(t *testing.T) {
+		logged := captureKlog(t)
+
+		c := &authOperator{approvedProbeCiphers: []uint16{tls.TLS_AES_256_GCM_SHA384}}
+		c.noteNegotiatedCipherSuite(tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256)
+		klog.Flush()
+
+		output := logged.String()
+		if !strings.Contains(output, "not in the approved set") {
+			t.Fatalf("expected a warning about the unapproved suite, got:\n%s", output)
+		}
+		if !strings.Contains(output, suiteName(tls.TLS_AES_256_GCM_SHA384)) {
+			t.Errorf("expected the warning to enumerate the approved set, got:\n%s", output)
+		}
+
+		// re-negotiating the same suite stays quiet - klog duplicates a
+		// warning across severities, so compare counts rather than expect one
+		warnings := strings.Count(output, "not in the approved set")
+		c.noteNegotiatedCipherSuite(tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256)
+		klog.Flush()
+		if got := strings.Count(logged.String(), "not in the approved set"); got != warnings {
+			t.Errorf("expected no additional warning for the unchanged suite, got %d over %d", got, warnings)
+		}
+	})
+
+	t.Run("an approved suite does not warn", func(t *testing.T) {
+		logged := captureKlog(t)
+
+		c := &authOperator{approvedProbeCiphers: []uint16{tls.TLS_AES_256_GCM_SHA384}}
+		c.noteNegotiatedCipherSuite(tls.TLS_AES_256_GCM_SHA384)
+		klog.Flush()
+
+		if output := logged.String(); strings.Contains(output, "not in the approved set") {
+			t.Errorf("expected no warning for an approved suite, got:\n%s", output)
+		}
+	})
+}
+
+func TestWellknownNotApplicableForExternalAuth(t *testing.T) {
+	authConfig := &configv1.Authentication{
+		Spec: configv1.AuthenticationSpec{Type: configv1.AuthenticationTypeNone},
+	}
+
+	c := &authOperator{}
+	ready, reason, message, err := c.checkWellknownEndpointsReady(authConfig, testRoute("oauth-openshift.apps.example.com"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !ready || reason != reasonNotApplicable {
+		t.Errorf("expected ready with reason %s, got ready=%v reason=%q", reasonNotApplicable, ready, reason)
+	}
+	if !strings.Contains(message, "skipped") || !strings.Contains(message, string(configv1.AuthenticationTypeNone)) {
+		t.Errorf("expected the message to explain the skip and name the auth type, got %q", message)
+	}
+}
+
+func TestHandleIDPConsistency(t *testing.T) {
+	route := testRoute("oauth-openshift.apps.example.com")
+
+	oauthConfig := func(idps ...configv1.IdentityProvider) *configv1.OAuth {
+		return &configv1.OAuth{
+			ObjectMeta: metav1.ObjectMeta{Name: globalConfigName},
+			Spec:       configv1.OAuthSpec{IdentityProviders: idps},
+		}
+	}
+	htpasswd := configv1.IdentityProvider{Name: "htpasswd"}
+
+	condition := func(operatorConfig *operatorv1.Authentication) *operatorv1.OperatorCondition {
+		return v1helpers.FindOperatorCondition(operatorConfig.Status.Conditions, "IdentityProviderConsistency")
+	}
+
+	t.Run("disabled by default", func(t *testing.T) {
+		operatorConfig := &operatorv1.Authentication{}
+		(&authOperator{}).handleIDPConsistency(operatorConfig)
+		if got := condition(operatorConfig); got != nil {
+			t.Errorf("expected no condition while the check is off, got %#v", got)
+		}
+	})
+
+	t.Run("oauth config unreadable", func(t *testing.T) {
+		operatorConfig := &operatorv1.Authentication{}
+		c := &authOperator{checkIDPConsistency: true, oauth: configfake.NewSimpleClientset().ConfigV1().OAuths()}
+		c.handleIDPConsistency(operatorConfig)
+		got := condition(operatorConfig)
+		if got == nil || got.Status != operatorv1.ConditionTrue || got.Reason != "OAuthConfigError" {
+			t.Fatalf("expected an OAuthConfigError condition, got %#v", got)
+		}
+	})
+
+	t.Run("no served document yet", func(t *testing.T) {
+		operatorConfig := &operatorv1.Authentication{}
+		c := &authOperator{checkIDPConsistency: true, oauth: configfake.NewSimpleClientset(oauthConfig(htpasswd)).ConfigV1().OAuths()}
+		c.handleIDPConsistency(operatorConfig)
+		got := condition(operatorConfig)
+		if got == nil || got.Status != operatorv1.ConditionFalse {
+			t.Fatalf("expected the default condition before the first probe, got %#v", got)
+		}
+	})
+
+	t.Run("served document agrees with the configuration", func(t *testing.T) {
+		operatorConfig := &operatorv1.Authentication{}
+		c := &authOperator{checkIDPConsistency: true, oauth: configfake.NewSimpleClientset(oauthConfig(htpasswd)).ConfigV1().OAuths()}
+		c.recordServedIssuer(getMetadataStruct(route))
+		c.handleIDPConsistency(operatorConfig)
+		got := condition(operatorConfig)
+		if got == nil || got.Status != operatorv1.ConditionFalse || got.Reason != "AsExpected" {
+			t.Fatalf("expected the healthy condition, got %#v", got)
+		}
+	})
+
+	t.Run("authorization endpoint with no identity providers", func(t *testing.T) {
+		operatorConfig := &operatorv1.Authentication{}
+		c := &authOperator{checkIDPConsistency: true, oauth: configfake.NewSimpleClientset(oauthConfig()).ConfigV1().OAuths()}
+		c.recordServedIssuer(getMetadataStruct(route))
+		c.handleIDPConsistency(operatorConfig)
+		got := condition(operatorConfig)
+		if got == nil || got.Status != operatorv1.ConditionTrue || got.Reason != "InconsistentWithConfiguration" {
+			t.Fatalf("expected an inconsistency condition, got %#v", got)
+		}
+		if !strings.Contains(got.Message, "no identity providers") {
+			t.Errorf("expected the message to explain the missing providers, got %q", got.Message)
+		}
+	})
+
+	t.Run("endpoint on a foreign host", func(t *testing.T) {
+		operatorConfig := &operatorv1.Authentication{}
+		doc := getMetadataStruct(route)
+		doc["token_endpoint"] = "https://somewhere.else.example.net/oauth/token"
+		c := &authOperator{checkIDPConsistency: true, oauth: configfake.NewSimpleClientset(oauthConfig(htpasswd)).ConfigV1().OAuths()}
+		c.recordServedIssuer(doc)
+		c.handleIDPConsistency(operatorConfig)
+		got := condition(operatorConfig)
+		if got == nil || got.Status != operatorv1.ConditionTrue || got.Reason != "InconsistentWithConfiguration" {
+			t.Fatalf("expected an inconsistency condition, got %#v", got)
+		}
+		if !strings.Contains(got.Message, "token_endpoint") || !strings.Contains(got.Message, route.Spec.Host) {
+			t.Errorf("expected the message to name the endpoint and the issuer host, got %q", got.Message)
+		}
+	})
+}
+
+func TestProbeShutdownCancellation(t *testing.T) {
+	route := testRoute("oauth-openshift.apps.example.com")
+
+	t.Run("shutdown aborts an in-flight round-trip", func(t *testing.T) {
+		// the server answers only when the request context dies, so a prompt
+		// return proves shutdown cancelled the round-trip
+		server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(10 * time.Second):
+			}
+		}))
+		defer server.Close()
+
+		shutdown := make(chan struct{})
+		c := &authOperator{shutdown: shutdown}
+		go func() {
+			time.Sleep(50 * time.Millisecond)
+			close(shutdown)
+		}()
+
+		start := time.Now()
+		_, _, _, err := c.checkMembersWellknownReady([]string{server.Listener.Addr().String()}, server.Client().Transport, route)
+		if !isShuttingDownError(err) {
+			t.Fatalf("expected the shutdown error, got %v", err)
+		}
+		if elapsed := time.Since(start); elapsed > 5*time.Second {
+			t.Errorf("the probe returned after %s, expected shutdown to abort it promptly", elapsed)
+		}
+
+		// the aborted member earned no backoff - it did not fail, the process left
+		if _, _, ok := c.memberInBackoff(server.Listener.Addr().String()); ok {
+			t.Error("expected no member backoff from a shutdown-aborted probe")
+		}
+	})
+
+	t.Run("a closed channel stops the fan out before any request", func(t *testing.T) {
+		shutdown := make(chan struct{})
+		close(shutdown)
+
+		rt := newFakeTransport()
+		c := &authOperator{shutdown: shutdown}
+		_, _, _, err := c.checkMembersWellknownReady([]string{"10.0.0.1:6443", "10.0.0.2:6443"}, rt, route)
+		if !isShuttingDownError(err) {
+			t.Fatalf("expected the shutdown error, got %v", err)
+		}
+		if got := rt.requestedHosts(); len(got) != 0 {
+			t.Errorf("expected no members probed after shutdown, got %v", got)
+		}
+	})
+
+	t.Run("the error survives wrapping", func(t *testing.T) {
+		if !isShuttingDownError(fmt.Errorf("failed handling the route: %v", errShuttingDown)) {
+			t.Error("expected the wrapped shutdown error to still be recognized")
+		}
+		if isShuttingDownError(nil) || isShuttingDownError(fmt.Errorf("some other error")) {
+			t.Error("expected unrelated errors not to match")
+		}
+	})
+}
+
+func TestProbeMemberExclusions(t *testing.T) {
+	t.Run("parsing", func(t *testing.T) {
+		if got := parseProbeExclusions(""); len(got) != 0 {
+			t.Errorf("expected no exclusions from an empty value, got %v", got)
+		}
+		want := []string{"10.0.0.1", "10.0.0.2:6443"}
+		if got := parseProbeExclusions(" 10.0.0.2:6443, 10.0.0.1 ,,10.0.0.1"); !reflect.DeepEqual(got, want) {
+			t.Errorf("parseProbeExclusions() = %v, want %v", got, want)
+		}
+	})
+
+	t.Run("partitioning matches by member or by IP alone", func(t *testing.T) {
+		members := []string{"10.0.0.1:6443", "10.0.0.2:6443", "10.0.0.3:6443"}
+		probed, excluded := partitionExcludedMembers(members, []string{"10.0.0.1", "10.0.0.3:6443"})
+		if want := []string{"10.0.0.2:6443"}; !reflect.DeepEqual(probed, want) {
+			t.Errorf("probed = %v, want %v", probed, want)
+		}
+		if want := []string{"10.0.0.1:6443", "10.0.0.3:6443"}; !reflect.DeepEqual(excluded, want) {
+			t.Errorf("excluded = %v, want %v", excluded, want)
+		}
+	})
+
+	t.Run("the condition keeps exclusions visible", func(t *testing.T) {
+		operatorConfig := &operatorv1.Authentication{
+			ObjectMeta: metav1.ObjectMeta{
+				Annotations: map[string]string{excludeProbeMembersAnnotation: "10.0.0.1"},
+			},
+		}
+		c := &authOperator{}
+		c.handleProbeExclusions(operatorConfig)
+		got := v1helpers.FindOperatorCondition(operatorConfig.Status.Conditions, "ProbeMembersExcluded")
+		if got == nil || got.Status != operatorv1.ConditionTrue || got.Reason != "AdminExcluded" {
+			t.Fatalf("expected an AdminExcluded condition, got %#v", got)
+		}
+		if !strings.Contains(got.Message, "10.0.0.1") || !strings.Contains(got.Message, excludeProbeMembersAnnotation) {
+			t.Errorf("expected the message to name the member and the annotation, got %q", got.Message)
+		}
+
+		// removing the annotation clears both the field and the condition
+		operatorConfig.Annotations = nil
+		c.handleProbeExclusions(operatorConfig)
+		if len(c.excludedProbeMembers) != 0 {
+			t.Errorf("expected the exclusions to be dropped, got %v", c.excludedProbeMembers)
+		}
+		if got := v1helpers.FindOperatorCondition(operatorConfig.Status.Conditions, "ProbeMembersExcluded"); got == nil || got.Status != operatorv1.ConditionFalse {
+			t.Errorf("expected the condition to return to the default, got %#v", got)
+		}
+	})
+
+	t.Run("excluding every member leaves nothing verified", func(t *testing.T) {
+		now := time.Date(2019, time.April, 1, 12, 0, 0, 0, time.UTC)
+		ca := newTestCA(t, "probe-ca", now.Add(-time.Hour), now.Add(365*24*time.Hour))
+		dir, err := ioutil.TempDir("", "probe-exclusions")
+		if err != nil {
+			t.Fatalf("failed to create temp dir: %v", err)
+		}
+		t.Cleanup(func() { os.RemoveAll(dir) })
+		caPath := filepath.Join(dir, "ca.crt")
+		if err := ioutil.WriteFile(caPath, ca.certPEM, 0600); err != nil {
+			t.Fatalf("failed to write bundle: %v", err)
+		}
+
+		kubeClient := kubefake.NewSimpleClientset(testKASService(), testKASEndpoints(6443, "10.0.0.1", "10.0.0.2"))
+		c := &authOperator{
+			clock:                func() time.Time { return now },
+			services:             kubeClient.CoreV1(),
+			endpoints:            kubeClient.CoreV1(),
+			rootCAPath:           caPath,
+			excludedProbeMembers: []string{"10.0.0.1", "10.0.0.2"},
+		}
+		ready, reason, msg, err := c.probeWellknownEndpointsReady(testRoute("oauth-openshift.apps.example.com"))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if ready || reason != reasonAllMembersExcluded {
+			t.Errorf("ready = %v, reason = %q, want not ready with %q", ready, reason, reasonAllMembersExcluded)
+		}
+		for _, want := range []string{"10.0.0.1:6443", "10.0.0.2:6443", excludeProbeMembersAnnotation} {
+			if !strings.Contains(msg, want) {
+				t.Errorf("expected the message to contain %q, got %q", want, msg)
+			}
+		}
+	})
+}